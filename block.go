@@ -4,6 +4,7 @@ package bc
 import (
 	"encoding/hex"
 	"errors"
+	"fmt"
 
 	"github.com/libsv/go-bt/v2"
 )
@@ -59,32 +60,46 @@ func NewBlockFromStr(blockStr string) (*Block, error) {
 }
 
 // NewBlockFromBytes will encode a block header byte slice
-// into the bitcoin block header structure.
+// into the bitcoin block header structure. An error is returned if the bytes do not
+// hold exactly one block.
 //
 // See https://btcinformation.org/en/developer-reference#serialized-blocks
 func NewBlockFromBytes(b []byte) (*Block, error) {
 	if len(b) == 0 {
 		return nil, errors.New("block cannot be empty")
 	}
+	d := NewDecoder(b, nil)
 
-	var offset int
-	bh, err := NewBlockHeaderFromBytes(b[:80])
+	header, err := d.Bytes("block header", 80)
+	if err != nil {
+		return nil, err
+	}
+	bh, err := NewBlockHeaderFromBytes(header)
 	if err != nil {
 		return nil, err
 	}
-	offset += 80
 
-	txCount, size := bt.NewVarIntFromBytes(b[offset:])
-	offset += size
+	// the smallest tx, without inputs or outputs, is 10 bytes.
+	txCount, err := d.Count("block tx count", 10)
+	if err != nil {
+		return nil, err
+	}
 
-	var txs []*bt.Tx
-	for i := 0; i < int(txCount); i++ {
-		tx, size, err := bt.NewTxFromStream(b[offset:])
+	txs := make([]*bt.Tx, 0, txCount)
+	for i := uint64(0); i < txCount; i++ {
+		offset := d.Offset()
+		raw, err := d.Tx()
+		if err != nil {
+			return nil, fmt.Errorf("block tx %d: %w", i, err)
+		}
+		tx, err := bt.NewTxFromBytes(raw)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("block tx %d at offset %d: %w", i, offset, err)
 		}
 		txs = append(txs, tx)
-		offset += size
+	}
+	if err := d.End(); err != nil {
+		return nil, err
 	}
 
 	return &Block{
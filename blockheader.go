@@ -4,11 +4,6 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"math/big"
-
-	"github.com/libsv/go-bk/crypto"
-	"github.com/libsv/go-bt/v2"
 )
 
 /*
@@ -66,30 +61,27 @@ func (bh *BlockHeader) String() string {
 //
 // See https://en.bitcoin.it/wiki/Block_hashing_algorithm
 func (bh *BlockHeader) Bytes() []byte {
-	bytes := []byte{}
-	bytes = append(bytes, UInt32ToBytes(bh.Version)...)
-	bytes = append(bytes, bt.ReverseBytes(bh.HashPrevBlock)...)
-	bytes = append(bytes, bt.ReverseBytes(bh.HashMerkleRoot)...)
-	bytes = append(bytes, UInt32ToBytes(bh.Time)...)
-	bytes = append(bytes, bt.ReverseBytes(bh.Bits)...)
-	bytes = append(bytes, UInt32ToBytes(bh.Nonce)...)
+	prev, root := 4+len(bh.HashPrevBlock), 4+len(bh.HashPrevBlock)+len(bh.HashMerkleRoot)
+	bytes := make([]byte, root+8+len(bh.Bits))
+	binary.LittleEndian.PutUint32(bytes, bh.Version)
+	putReversed(bytes[4:prev], bh.HashPrevBlock)
+	putReversed(bytes[prev:root], bh.HashMerkleRoot)
+	binary.LittleEndian.PutUint32(bytes[root:], bh.Time)
+	putReversed(bytes[root+4:root+4+len(bh.Bits)], bh.Bits)
+	binary.LittleEndian.PutUint32(bytes[root+4+len(bh.Bits):], bh.Nonce)
 	return bytes
 }
 
 // Valid checks whether a blockheader satisfies the proof-of-work claimed
 // in Bits. Wwe check whether its Hash256 read as a little endian number
-// is less than the Bits written in expanded form.
+// is less than the Bits written in expanded form. As in the node, negative
+// and overflowing Bits are never valid.
 func (bh *BlockHeader) Valid() bool {
-	target, err := ExpandTargetFromAsInt(hex.EncodeToString(bh.Bits))
-	if err != nil {
+	if len(bh.HashPrevBlock) != 32 || len(bh.HashMerkleRoot) != 32 || len(bh.Bits) != 4 {
 		return false
 	}
-
-	digest := bt.ReverseBytes(crypto.Sha256d(bh.Bytes()))
-	var bn = big.NewInt(0)
-	bn.SetBytes(digest)
-
-	return bn.Cmp(target) < 0
+	h := bh.Header()
+	return h.Valid()
 }
 
 // NewBlockHeaderFromStr will encode a block header hash
@@ -98,7 +90,7 @@ func (bh *BlockHeader) Valid() bool {
 // See https://en.bitcoin.it/wiki/Block_hashing_algorithm
 func NewBlockHeaderFromStr(headerStr string) (*BlockHeader, error) {
 	if len(headerStr) != 160 {
-		return nil, errors.New("block header should be 80 bytes long")
+		return nil, ErrHeaderLength
 	}
 
 	headerBytes, err := hex.DecodeString(headerStr)
@@ -115,15 +107,22 @@ func NewBlockHeaderFromStr(headerStr string) (*BlockHeader, error) {
 // See https://en.bitcoin.it/wiki/Block_hashing_algorithm
 func NewBlockHeaderFromBytes(headerBytes []byte) (*BlockHeader, error) {
 	if len(headerBytes) != 80 {
-		return nil, errors.New("block header should be 80 bytes long")
+		return nil, ErrHeaderLength
 	}
 
+	// the hashes and bits share one allocation, capped so appending to one cannot
+	// overwrite another.
+	buf := make([]byte, 68)
+	putReversed(buf[0:32], headerBytes[4:36])
+	putReversed(buf[32:64], headerBytes[36:68])
+	putReversed(buf[64:68], headerBytes[72:76])
+
 	return &BlockHeader{
 		Version:        binary.LittleEndian.Uint32(headerBytes[:4]),
-		HashPrevBlock:  bt.ReverseBytes(headerBytes[4:36]),
-		HashMerkleRoot: bt.ReverseBytes(headerBytes[36:68]),
+		HashPrevBlock:  buf[0:32:32],
+		HashMerkleRoot: buf[32:64:64],
 		Time:           binary.LittleEndian.Uint32(headerBytes[68:72]),
-		Bits:           bt.ReverseBytes(headerBytes[72:76]),
+		Bits:           buf[64:68:68],
 		Nonce:          binary.LittleEndian.Uint32(headerBytes[76:]),
 	}, nil
 }
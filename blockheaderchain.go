@@ -3,6 +3,8 @@ package bc
 import (
 	"context"
 	"errors"
+	"sort"
+	"time"
 )
 
 var (
@@ -10,6 +12,9 @@ var (
 	ErrHeaderNotFound = errors.New("header with not found")
 	// ErrNotOnLongestChain indicates the blockhash is present but isn't on the longest current chain.
 	ErrNotOnLongestChain = errors.New("header exists but is not on the longest chain")
+	// ErrNoHeightIndex is returned when a height aware lookup is attempted on a BlockHeaderChain
+	// which does not implement HeightIndexedBlockHeaderChain.
+	ErrNoHeightIndex = errors.New("block header chain does not support height lookups")
 )
 
 // A BlockHeaderChain is a generic interface used to map things in the block header chain
@@ -20,4 +25,177 @@ var (
 // ErrHeaderNotFound & ErrNotOnLongestChain sentinel errors when implementing the interface.
 type BlockHeaderChain interface {
 	BlockHeader(ctx context.Context, blockHash string) (*BlockHeader, error)
-}
\ No newline at end of file
+}
+
+// A HeightIndexedBlockHeaderChain is a BlockHeaderChain which also knows the height of the
+// headers on the longest chain. It is an optional extension of BlockHeaderChain which
+// implementations can adopt when they are able to, functions needing it will check for it
+// and return ErrNoHeightIndex when it is not available.
+//
+// ChainTip returns the height and hash of the tip of the longest chain, BlockHeaderByHeight
+// returns the header at a height on the longest chain and BlockHeight returns the height of
+// a header on the longest chain. ErrHeaderNotFound & ErrNotOnLongestChain should be used as
+// they are for BlockHeaderChain.
+type HeightIndexedBlockHeaderChain interface {
+	BlockHeaderChain
+	ChainTip(ctx context.Context) (uint64, string, error)
+	BlockHeaderByHeight(ctx context.Context, height uint64) (*BlockHeader, error)
+	BlockHeight(ctx context.Context, blockHash string) (uint64, error)
+}
+
+// A TimeIndexedBlockHeaderChain can find the first block on the longest chain whose median
+// time past is at or after a time, returning the header and its height. Block timestamps are
+// not strictly increasing, but each must be after the median time past of the blocks before
+// it, so the median time past never decreases. It is also the time the node checks time based
+// lock times against.
+//
+// Implementing it is optional, BlockHeaderAtTime falls back to searching a
+// HeightIndexedBlockHeaderChain when it is not available.
+type TimeIndexedBlockHeaderChain interface {
+	BlockHeaderAtTime(ctx context.Context, t time.Time) (*BlockHeader, uint64, error)
+}
+
+// Confirmations returns the number of confirmations the block with the hash provided has,
+// a block at the tip of the longest chain has one confirmation.
+//
+// The bhc must implement HeightIndexedBlockHeaderChain, otherwise ErrNoHeightIndex is returned.
+func Confirmations(ctx context.Context, bhc BlockHeaderChain, blockHash string) (uint64, error) {
+	chain, ok := bhc.(HeightIndexedBlockHeaderChain)
+	if !ok {
+		return 0, ErrNoHeightIndex
+	}
+
+	height, err := chain.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	tip, _, err := chain.ChainTip(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if height > tip {
+		return 0, ErrNotOnLongestChain
+	}
+
+	return tip - height + 1, nil
+}
+
+// Ancestor returns the header depth blocks below the block with the hash provided, so a
+// depth of 0 returns the block itself and a depth of 1 its parent.
+//
+// The bhc must implement HeightIndexedBlockHeaderChain, otherwise ErrNoHeightIndex is returned.
+func Ancestor(ctx context.Context, bhc BlockHeaderChain, blockHash string, depth uint64) (*BlockHeader, error) {
+	chain, ok := bhc.(HeightIndexedBlockHeaderChain)
+	if !ok {
+		return nil, ErrNoHeightIndex
+	}
+
+	height, err := chain.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if depth > height {
+		return nil, ErrHeaderNotFound
+	}
+
+	return chain.BlockHeaderByHeight(ctx, height-depth)
+}
+
+// BlockHeaderAtTime returns the header and height of the first block on the longest chain whose
+// median time past, as given by MedianTimePastAtHeight, is at or after t.
+//
+// If bhc implements TimeIndexedBlockHeaderChain it is used, otherwise bhc must implement
+// HeightIndexedBlockHeaderChain and the chain is binary searched by height. Each header is
+// fetched at most once a search, and each height searched only fetches as many of the headers
+// before it as are needed to tell which side of t its median time past is.
+func BlockHeaderAtTime(ctx context.Context, bhc BlockHeaderChain, t time.Time) (*BlockHeader, uint64, error) {
+	if chain, ok := bhc.(TimeIndexedBlockHeaderChain); ok {
+		return chain.BlockHeaderAtTime(ctx, t)
+	}
+	chain, ok := bhc.(HeightIndexedBlockHeaderChain)
+	if !ok {
+		return nil, 0, ErrNoHeightIndex
+	}
+
+	tip, _, err := chain.ChainTip(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	target := t.Unix()
+	// headers holds those fetched, nil for those the chain does not hold, as the windows of
+	// the heights searched overlap as the search narrows.
+	headers := make(map[uint64]*BlockHeader)
+	header := func(height uint64) (*BlockHeader, error) {
+		if bh, ok := headers[height]; ok {
+			return bh, nil
+		}
+		bh, err := chain.BlockHeaderByHeight(ctx, height)
+		if errors.Is(err, ErrHeaderNotFound) {
+			// the chain may not hold headers this far back.
+			err = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		headers[height] = bh
+		return bh, nil
+	}
+
+	var searchErr error
+	reached := func(height uint64) bool {
+		n := uint64(medianTimeBlocks)
+		if height+1 < n {
+			n = height + 1
+		}
+		// the median, the upper one of an even number, is at or after t once more than half
+		// the times are, and before it once at least half are before it.
+		var after, before uint64
+		for i := uint64(0); i < n; i++ {
+			bh, err := header(height - i)
+			if err != nil {
+				searchErr = err
+				return true
+			}
+			if bh == nil {
+				if i == 0 {
+					return false
+				}
+				n = i
+				break
+			}
+			if int64(bh.Time) >= target {
+				after++
+			} else {
+				before++
+			}
+			if after >= n-n/2 {
+				return true
+			}
+			if before > n/2 {
+				return false
+			}
+		}
+		return after >= n-n/2
+	}
+
+	height := uint64(sort.Search(int(tip)+1, func(i int) bool {
+		return reached(uint64(i))
+	}))
+	if searchErr != nil {
+		return nil, 0, searchErr
+	}
+	if height > tip {
+		return nil, 0, ErrHeaderNotFound
+	}
+
+	bh, err := header(height)
+	if err != nil {
+		return nil, 0, err
+	}
+	if bh == nil {
+		return nil, 0, ErrHeaderNotFound
+	}
+
+	return bh, height, nil
+}
@@ -0,0 +1,310 @@
+package bc_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/libsv/go-bk/crypto"
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// indexedChain is an in memory bc.HeightIndexedBlockHeaderChain, its first header is at
+// the height base.
+type indexedChain struct {
+	base    uint64
+	headers []*bc.BlockHeader
+	heights map[string]uint64
+}
+
+// mainnetHeaders loads the first n mainnet headers from the test data.
+func mainnetHeaders(t *testing.T, n int) []*bc.BlockHeader {
+	t.Helper()
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+	require.LessOrEqual(t, n*80, len(b))
+
+	hh := make([]*bc.BlockHeader, n)
+	for i := range hh {
+		hh[i], err = bc.NewBlockHeaderFromBytes(b[i*80 : (i+1)*80])
+		require.NoError(t, err)
+	}
+	return hh
+}
+
+func newIndexedChain(t *testing.T, n int) *indexedChain {
+	t.Helper()
+	return indexedChainOf(mainnetHeaders(t, n))
+}
+
+func indexedChainOf(hh []*bc.BlockHeader) *indexedChain {
+	return indexedChainFrom(0, hh)
+}
+
+// indexedChainFrom returns a chain of the headers hh, the first being at the height base.
+func indexedChainFrom(base uint64, hh []*bc.BlockHeader) *indexedChain {
+	c := &indexedChain{base: base, heights: make(map[string]uint64, len(hh))}
+	for i, bh := range hh {
+		c.headers = append(c.headers, bh)
+		c.heights[hex.EncodeToString(bt.ReverseBytes(crypto.Sha256d(bh.Bytes())))] = base + uint64(i)
+	}
+	return c
+}
+
+func (c *indexedChain) BlockHeader(_ context.Context, blockHash string) (*bc.BlockHeader, error) {
+	h, ok := c.heights[blockHash]
+	if !ok {
+		return nil, bc.ErrHeaderNotFound
+	}
+	return c.headers[h-c.base], nil
+}
+
+func (c *indexedChain) ChainTip(_ context.Context) (uint64, string, error) {
+	tip := c.headers[len(c.headers)-1]
+	return c.base + uint64(len(c.headers)-1), hex.EncodeToString(bt.ReverseBytes(crypto.Sha256d(tip.Bytes()))), nil
+}
+
+func (c *indexedChain) BlockHeaderByHeight(_ context.Context, height uint64) (*bc.BlockHeader, error) {
+	if height < c.base || height-c.base >= uint64(len(c.headers)) {
+		return nil, bc.ErrHeaderNotFound
+	}
+	return c.headers[height-c.base], nil
+}
+
+func (c *indexedChain) BlockHeight(_ context.Context, blockHash string) (uint64, error) {
+	h, ok := c.heights[blockHash]
+	if !ok {
+		return 0, bc.ErrHeaderNotFound
+	}
+	return h, nil
+}
+
+// fetchCountingChain counts the headers fetched from an indexedChain by height.
+type fetchCountingChain struct {
+	*indexedChain
+	fetched map[uint64]int
+}
+
+func (c *fetchCountingChain) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	c.fetched[height]++
+	return c.indexedChain.BlockHeaderByHeight(ctx, height)
+}
+
+// plainChain only implements bc.BlockHeaderChain.
+type plainChain struct {
+	bc.BlockHeaderChain
+}
+
+const block100 = "000000007bc154e0fa7ea32218a72fe2c1bb9f86cf8c9ebf9a715ed27fdb229a"
+
+func TestConfirmations(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 150)
+
+	tests := map[string]struct {
+		chain     bc.BlockHeaderChain
+		blockHash string
+		exp       uint64
+		expErr    error
+	}{
+		"block below the tip": {
+			chain:     chain,
+			blockHash: block100,
+			exp:       50,
+		},
+		"genesis block": {
+			chain:     chain,
+			blockHash: "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			exp:       150,
+		},
+		"unknown block errors": {
+			chain:     chain,
+			blockHash: "00000000a141216a896c54f211301c436e557a8d55900637bbdce14c6c7bddef",
+			expErr:    bc.ErrHeaderNotFound,
+		},
+		"chain without a height index errors": {
+			chain:     plainChain{chain},
+			blockHash: block100,
+			expErr:    bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			confs, err := bc.Confirmations(ctx, test.chain, test.blockHash)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, confs)
+		})
+	}
+}
+
+func TestAncestor(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 150)
+
+	tests := map[string]struct {
+		chain  bc.BlockHeaderChain
+		depth  uint64
+		exp    *bc.BlockHeader
+		expErr error
+	}{
+		"depth of zero returns the block": {
+			chain: chain,
+			depth: 0,
+			exp:   chain.headers[100],
+		},
+		"depth of one returns the parent": {
+			chain: chain,
+			depth: 1,
+			exp:   chain.headers[99],
+		},
+		"depth to genesis": {
+			chain: chain,
+			depth: 100,
+			exp:   chain.headers[0],
+		},
+		"depth below genesis errors": {
+			chain:  chain,
+			depth:  101,
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"chain without a height index errors": {
+			chain:  plainChain{chain},
+			expErr: bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bh, err := bc.Ancestor(ctx, test.chain, block100, test.depth)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, bh)
+		})
+	}
+}
+
+// firstAtMedianTime returns the height of the first block of chain whose median time past is
+// at or after t, by checking every block in turn.
+func firstAtMedianTime(t *testing.T, chain bc.HeightIndexedBlockHeaderChain, at time.Time) (uint64, bool) {
+	t.Helper()
+	ctx := context.Background()
+	tip, _, err := chain.ChainTip(ctx)
+	require.NoError(t, err)
+	for height := uint64(0); height <= tip; height++ {
+		mtp, err := bc.MedianTimePastAtHeight(ctx, chain, height)
+		if errors.Is(err, bc.ErrHeaderNotFound) {
+			continue
+		}
+		require.NoError(t, err)
+		if !mtp.Before(at) {
+			return height, true
+		}
+	}
+	return 0, false
+}
+
+func TestBlockHeaderAtTime(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 150)
+	// block 120 carries a timestamp ahead of the blocks after it.
+	chain.headers[120] = &bc.BlockHeader{
+		Version:        chain.headers[120].Version,
+		Time:           chain.headers[130].Time,
+		Nonce:          chain.headers[120].Nonce,
+		HashPrevBlock:  chain.headers[120].HashPrevBlock,
+		HashMerkleRoot: chain.headers[120].HashMerkleRoot,
+		Bits:           chain.headers[120].Bits,
+	}
+	mtp := func(height uint64) time.Time {
+		mtp, err := bc.MedianTimePastAtHeight(ctx, chain, height)
+		require.NoError(t, err)
+		return mtp
+	}
+
+	tests := map[string]struct {
+		chain     bc.BlockHeaderChain
+		time      time.Time
+		expHeight uint64
+		expErr    error
+	}{
+		"median time past of a block": {
+			chain:     chain,
+			time:      mtp(60),
+			expHeight: 60,
+		},
+		"time between median times returns the later block": {
+			chain:     chain,
+			time:      mtp(60).Add(time.Second),
+			expHeight: 61,
+		},
+		"time before genesis returns genesis": {
+			chain:     chain,
+			time:      time.Unix(0, 0),
+			expHeight: 0,
+		},
+		"block ahead of its successors is not found before its median time": {
+			chain:     chain,
+			time:      time.Unix(int64(chain.headers[120].Time), 0),
+			expHeight: 135,
+		},
+		"time after the tip errors": {
+			chain:  chain,
+			time:   mtp(149).Add(time.Second),
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"chain without a height index errors": {
+			chain:  plainChain{chain},
+			expErr: bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bh, height, err := bc.BlockHeaderAtTime(ctx, test.chain, test.time)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expHeight, height)
+			require.Equal(t, chain.headers[test.expHeight], bh)
+		})
+	}
+
+	// each header is fetched at most once a search.
+	counted := &fetchCountingChain{indexedChain: chain, fetched: map[uint64]int{}}
+	_, _, err := bc.BlockHeaderAtTime(ctx, counted, mtp(100))
+	require.NoError(t, err)
+	for height, n := range counted.fetched {
+		require.Equal(t, 1, n, "height %d", height)
+	}
+	require.Less(t, len(counted.fetched), 11*8)
+
+	// the search agrees with checking every block, either side of each block time.
+	for _, bh := range chain.headers {
+		for _, at := range []time.Time{time.Unix(int64(bh.Time)-1, 0), time.Unix(int64(bh.Time), 0), time.Unix(int64(bh.Time)+1, 0)} {
+			exp, ok := firstAtMedianTime(t, chain, at)
+			_, height, err := bc.BlockHeaderAtTime(ctx, chain, at)
+			if !ok {
+				require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+				continue
+			}
+			require.NoError(t, err)
+			require.Equal(t, exp, height, "time %d", at.Unix())
+		}
+	}
+}
@@ -36,49 +36,62 @@ func NewBUMPFromStream(bytes []byte) (*BUMP, int, error) {
 		return nil, 0, errors.New("BUMP bytes do not contain enough data to be valid")
 	}
 	bump := &BUMP{}
+	d := NewDecoder(bytes, nil)
 
 	// first bytes are the block height.
-	var skip int
-	index, size := bt.NewVarIntFromBytes(bytes[skip:])
-	skip += size
-	bump.BlockHeight = uint64(index)
+	blockHeight, err := d.VarInt("BUMP block height")
+	if err != nil {
+		return nil, 0, err
+	}
+	bump.BlockHeight = blockHeight
 
 	// Next byte is the tree height.
-	treeHeight := uint(bytes[skip])
-	skip++
+	treeHeight, err := d.Byte("BUMP tree height")
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// We expect tree height levels.
 	bump.Path = make([][]leaf, treeHeight)
 
-	for lv := uint(0); lv < treeHeight; lv++ {
-		// For each level we parse a bunch of nLeaves.
-		n, size := bt.NewVarIntFromBytes(bytes[skip:])
-		skip += size
-		nLeavesAtThisHeight := uint64(n)
+	for lv := 0; lv < int(treeHeight); lv++ {
+		// For each level we parse a bunch of nLeaves, a leaf being at least an offset and flags.
+		offset := d.Offset()
+		nLeavesAtThisHeight, err := d.Count("BUMP leaf count", 2)
+		if err != nil {
+			return nil, 0, err
+		}
 		if nLeavesAtThisHeight == 0 {
-			return nil, 0, errors.New("There are no leaves at height: " + fmt.Sprint(lv) + " which makes this invalid")
+			return nil, 0, fmt.Errorf("There are no leaves at height: %d, offset %d, which makes this invalid", lv, offset)
 		}
 		bump.Path[lv] = make([]leaf, nLeavesAtThisHeight)
 		for lf := uint64(0); lf < nLeavesAtThisHeight; lf++ {
 			// For each leaf we parse the offset, hash, txid and duplicate.
-			offset, size := bt.NewVarIntFromBytes(bytes[skip:])
-			skip += size
+			o, err := d.VarInt("BUMP leaf offset")
+			if err != nil {
+				return nil, 0, err
+			}
 			var l leaf
-			o := uint64(offset)
 			l.Offset = &o
-			flags := bytes[skip]
-			skip++
+			flags, err := d.Byte("BUMP leaf flags")
+			if err != nil {
+				return nil, 0, err
+			}
+			// a duplicate has no hash, so cannot be a txid.
+			if flags > 2 {
+				return nil, 0, fmt.Errorf("invalid BUMP leaf flags %d at offset %d", flags, d.Offset()-1)
+			}
 			dup := flags&1 > 0
 			txid := flags&2 > 0
 			if dup {
 				l.Duplicate = &dup
 			} else {
-				if len(bytes) < skip+32 {
-					return nil, 0, errors.New("BUMP bytes do not contain enough data to be valid")
+				hash, err := d.Bytes("BUMP leaf hash", 32)
+				if err != nil {
+					return nil, 0, err
 				}
-				h := StringFromBytesReverse(bytes[skip : skip+32])
+				h := StringFromBytesReverse(hash)
 				l.Hash = &h
-				skip += 32
 			}
 			if txid {
 				l.Txid = &txid
@@ -89,20 +102,24 @@ func NewBUMPFromStream(bytes []byte) (*BUMP, int, error) {
 
 	// Sort each of the levels by the offset for consistency.
 	for _, level := range bump.Path {
-		sort.Slice(level, func(i, j int) bool {
+		sort.SliceStable(level, func(i, j int) bool {
 			return *level[i].Offset < *level[j].Offset
 		})
 	}
 
-	return bump, skip, nil
+	return bump, d.Offset(), nil
 }
 
-// NewBUMPFromBytes creates a new BUMP from a byte slice.
+// NewBUMPFromBytes creates a new BUMP from a byte slice. An error is returned if the bytes
+// do not hold exactly one BUMP.
 func NewBUMPFromBytes(bytes []byte) (*BUMP, error) {
-	bump, _, err := NewBUMPFromStream(bytes)
+	bump, used, err := NewBUMPFromStream(bytes)
 	if err != nil {
 		return nil, err
 	}
+	if used != len(bytes) {
+		return nil, fmt.Errorf("%w: %d bytes at offset %d", ErrTrailingBytes, len(bytes)-used, used)
+	}
 	return bump, nil
 }
 
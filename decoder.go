@@ -0,0 +1,227 @@
+package bc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/libsv/go-bt/v2"
+)
+
+var (
+	// ErrTruncated is returned when decoding bytes which end part way through a field.
+	ErrTruncated = errors.New("unexpected end of data")
+	// ErrTrailingBytes is returned when decoding bytes which continue after what was decoded.
+	ErrTrailingBytes = errors.New("unexpected bytes after data")
+	// ErrNonCanonicalVarInt is returned when decoding a varint which is not in its shortest
+	// form, so would not encode back to the same bytes.
+	ErrNonCanonicalVarInt = errors.New("varint is not canonically encoded")
+)
+
+// Decoder reads the fields of a binary encoding, returning an error giving the field and
+// its offset, rather than panicking, when the bytes do not hold it.
+type Decoder struct {
+	b   []byte
+	off int
+	// errTruncated is wrapped by the errors returned when the bytes end part way through a field.
+	errTruncated error
+}
+
+// NewDecoder returns a Decoder reading b from its start. The errors returned when b ends part
+// way through a field wrap errTruncated, or ErrTruncated if it is nil.
+func NewDecoder(b []byte, errTruncated error) *Decoder {
+	if errTruncated == nil {
+		errTruncated = ErrTruncated
+	}
+	return &Decoder{b: b, errTruncated: errTruncated}
+}
+
+// Offset returns the offset of the next byte to read.
+func (d *Decoder) Offset() int {
+	return d.off
+}
+
+// Remaining returns the number of bytes left to read.
+func (d *Decoder) Remaining() int {
+	return len(d.b) - d.off
+}
+
+// Truncated returns the error of a field of n bytes which runs past the end of the bytes.
+func (d *Decoder) Truncated(field string, n uint64) error {
+	return fmt.Errorf("%w: %s of %d bytes at offset %d, %d bytes remain", d.errTruncated, field, n, d.off, d.Remaining())
+}
+
+// Peek returns the next byte without reading it.
+func (d *Decoder) Peek(field string) (byte, error) {
+	if d.Remaining() < 1 {
+		return 0, d.Truncated(field, 1)
+	}
+	return d.b[d.off], nil
+}
+
+// Byte reads the next byte.
+func (d *Decoder) Byte(field string) (byte, error) {
+	c, err := d.Peek(field)
+	if err != nil {
+		return 0, err
+	}
+	d.off++
+	return c, nil
+}
+
+// Bytes reads the next n bytes, n is compared to what remains so a length read from the
+// input cannot cause an allocation, or overflow.
+func (d *Decoder) Bytes(field string, n uint64) ([]byte, error) {
+	if n > uint64(d.Remaining()) {
+		return nil, d.Truncated(field, n)
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+// VarInt reads a varint, ErrNonCanonicalVarInt is returned if it is not in its shortest form.
+func (d *Decoder) VarInt(field string) (uint64, error) {
+	off := d.off
+	prefix, err := d.Byte(field)
+	if err != nil {
+		return 0, err
+	}
+	var v, least uint64
+	switch prefix {
+	case 0xff:
+		b, err := d.Bytes(field, 8)
+		if err != nil {
+			return 0, err
+		}
+		v, least = binary.LittleEndian.Uint64(b), 0x100000000
+	case 0xfe:
+		b, err := d.Bytes(field, 4)
+		if err != nil {
+			return 0, err
+		}
+		v, least = uint64(binary.LittleEndian.Uint32(b)), 0x10000
+	case 0xfd:
+		b, err := d.Bytes(field, 2)
+		if err != nil {
+			return 0, err
+		}
+		v, least = uint64(binary.LittleEndian.Uint16(b)), 0xfd
+	default:
+		return uint64(prefix), nil
+	}
+	if v < least {
+		return 0, fmt.Errorf("%w: %s of %d at offset %d", ErrNonCanonicalVarInt, field, v, off)
+	}
+	return v, nil
+}
+
+// VarBytes reads a varint length followed by that many bytes.
+func (d *Decoder) VarBytes(field string) ([]byte, error) {
+	l, err := d.VarInt(field + " length")
+	if err != nil {
+		return nil, err
+	}
+	return d.Bytes(field, l)
+}
+
+// Count reads a varint count of items, each at least size bytes long, rejecting a count of
+// more items than the bytes remaining could hold so it can be allocated safely.
+func (d *Decoder) Count(field string, size int) (uint64, error) {
+	off := d.off
+	n, err := d.VarInt(field)
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(d.Remaining()/size) {
+		return 0, fmt.Errorf("%w: %s of %d at offset %d needs at least %d bytes each, %d bytes remain",
+			d.errTruncated, field, n, off, size, d.Remaining())
+	}
+	return n, nil
+}
+
+// End returns ErrTrailingBytes if any bytes have not been read.
+func (d *Decoder) End() error {
+	if d.Remaining() > 0 {
+		return fmt.Errorf("%w: %d bytes at offset %d", ErrTrailingBytes, d.Remaining(), d.off)
+	}
+	return nil
+}
+
+// Tx returns the bytes of the next transaction, having checked every length in it fits within
+// the bytes remaining, as bt.NewTxFromBytes allocates the lengths it reads before finding
+// they run past the end. Transactions in the extended format are not accepted.
+func (d *Decoder) Tx() ([]byte, error) {
+	return d.tx(false)
+}
+
+// tx returns the bytes of the next transaction, accepting one in the extended format if
+// extended is set.
+func (d *Decoder) tx(extended bool) ([]byte, error) {
+	start := d.off
+	if _, err := d.Bytes("tx version", 4); err != nil {
+		return nil, err
+	}
+	isExtended := d.Remaining() >= 6 && string(d.b[d.off:d.off+6]) == "\x00\x00\x00\x00\x00\xef"
+	if isExtended {
+		if !extended {
+			return nil, fmt.Errorf("extended format tx at offset %d", start)
+		}
+		d.off += 6
+	}
+	// an input is at least 41 bytes, an output 9.
+	inputs, err := d.Count("tx input count", 41)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < inputs; i++ {
+		if _, err := d.Bytes("tx input outpoint", 36); err != nil {
+			return nil, err
+		}
+		if _, err := d.VarBytes("tx input script"); err != nil {
+			return nil, err
+		}
+		if _, err := d.Bytes("tx input sequence", 4); err != nil {
+			return nil, err
+		}
+		if !isExtended {
+			continue
+		}
+		if _, err := d.Bytes("tx input previous satoshis", 8); err != nil {
+			return nil, err
+		}
+		if _, err := d.VarBytes("tx input previous locking script"); err != nil {
+			return nil, err
+		}
+	}
+	outputs, err := d.Count("tx output count", 9)
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < outputs; i++ {
+		if _, err := d.Bytes("tx output satoshis", 8); err != nil {
+			return nil, err
+		}
+		if _, err := d.VarBytes("tx output script"); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := d.Bytes("tx lock time", 4); err != nil {
+		return nil, err
+	}
+	return d.b[start:d.off], nil
+}
+
+// NewTxFromBytes parses a transaction, in the standard or extended format, first checking
+// every length in it fits within b, as bt.NewTxFromBytes allocates the lengths it reads before
+// finding they run past the end. An error is returned if b holds more than the transaction.
+func NewTxFromBytes(b []byte) (*bt.Tx, error) {
+	d := NewDecoder(b, nil)
+	if _, err := d.tx(true); err != nil {
+		return nil, err
+	}
+	if err := d.End(); err != nil {
+		return nil, err
+	}
+	return bt.NewTxFromBytes(b)
+}
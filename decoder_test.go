@@ -0,0 +1,235 @@
+package bc_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+const (
+	testBlockHeader = "0000002043453154ad6d8209030ada359e07d2ce354cbed1f6169db497a5f2726e0bb51df5bc41a43429c7469dbb3501a186bf1f9238f9e886f84da057e7571c3472d12af33a1561ffff7f2001000000"
+	testCoinbaseTx  = "02000000010000000000000000000000000000000000000000000000000000000000000000ffffffff05024c0b0101ffffffff0106270000000000002321033ac208f182e7fe982b1c25027ada05e6fc44590e3f862b0a8422eda03ea5951bac00000000"
+	testMerklePath  = "0c02" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002"
+	// testExtendedTx is testCoinbaseTx in the extended format, its input spending an output of
+	// no satoshis with an empty locking script.
+	testExtendedTx = "02000000" + "0000000000ef" + "01" + "0000000000000000000000000000000000000000000000000000000000000000ffffffff" +
+		"05024c0b0101" + "ffffffff" + "0000000000000000" + "00" +
+		"0106270000000000002321033ac208f182e7fe982b1c25027ada05e6fc44590e3f862b0a8422eda03ea5951bac00000000"
+	testBUMP = "0101010102912f77eefdd311e24f96850ed8e701381fc4943327f9cf73f9c4dec0d93a056d"
+)
+
+func TestDecoders_Malformed(t *testing.T) {
+	t.Parallel()
+	decoders := map[string]func([]byte) error{
+		"block": func(b []byte) error {
+			_, err := bc.NewBlockFromBytes(b)
+			return err
+		},
+		"bump": func(b []byte) error {
+			_, err := bc.NewBUMPFromBytes(b)
+			return err
+		},
+		"merkle path": func(b []byte) error {
+			_, err := bc.NewMerklePathFromBytes(b)
+			return err
+		},
+		"tx": func(b []byte) error {
+			_, err := bc.NewTxFromBytes(b)
+			return err
+		},
+	}
+
+	tests := map[string]struct {
+		decoder string
+		hex     string
+		expErr  error
+		errMsg  string
+	}{
+		"valid block": {
+			decoder: "block",
+			hex:     testBlockHeader + "01" + testCoinbaseTx,
+		},
+		"short block header": {
+			decoder: "block",
+			hex:     testBlockHeader[:100],
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: block header of 80 bytes at offset 0, 50 bytes remain",
+		},
+		"block missing tx count": {
+			decoder: "block",
+			hex:     testBlockHeader,
+			expErr:  bc.ErrTruncated,
+		},
+		"block tx count too large": {
+			decoder: "block",
+			hex:     testBlockHeader + "ffffffffffffffff7f" + testCoinbaseTx,
+			expErr:  bc.ErrTruncated,
+			errMsg: "unexpected end of data: block tx count of 9223372036854775807 at offset 80 needs at least 10 bytes each, " +
+				"100 bytes remain",
+		},
+		"block tx script length too large": {
+			decoder: "block",
+			hex: testBlockHeader + "01" + "01000000" + "01" + strings.Repeat("00", 36) +
+				"ffffffffffffffff7f" + "ffffffff" + "00" + "00000000",
+			expErr: bc.ErrTruncated,
+			errMsg: "block tx 0: unexpected end of data: tx input script of 9223372036854775807 bytes at offset 131, " +
+				"9 bytes remain",
+		},
+		"block truncated tx": {
+			decoder: "block",
+			hex:     testBlockHeader + "01" + testCoinbaseTx[:len(testCoinbaseTx)-2],
+			expErr:  bc.ErrTruncated,
+		},
+		"block with fewer txs than its count": {
+			decoder: "block",
+			hex:     testBlockHeader + "02" + testCoinbaseTx,
+			expErr:  bc.ErrTruncated,
+		},
+		"block trailing bytes": {
+			decoder: "block",
+			hex:     testBlockHeader + "01" + testCoinbaseTx + "00",
+			expErr:  bc.ErrTrailingBytes,
+			errMsg:  "unexpected bytes after data: 1 bytes at offset 181",
+		},
+		"block non canonical tx count": {
+			decoder: "block",
+			hex:     testBlockHeader + "fd0100" + testCoinbaseTx,
+			expErr:  bc.ErrNonCanonicalVarInt,
+			errMsg:  "varint is not canonically encoded: block tx count of 1 at offset 80",
+		},
+		"valid bump": {
+			decoder: "bump",
+			hex:     testBUMP,
+		},
+		"bump too short": {
+			decoder: "bump",
+			hex:     testBUMP[:len(testBUMP)-2],
+			errMsg:  "BUMP bytes do not contain enough data to be valid",
+		},
+		"bump truncated hash": {
+			decoder: "bump",
+			hex:     "0102" + "010000" + strings.Repeat("11", 32) + "010000" + strings.Repeat("22", 10),
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: BUMP leaf hash of 32 bytes at offset 40, 10 bytes remain",
+		},
+		"bump leaf count too large": {
+			decoder: "bump",
+			hex:     "0101" + "feffffff00" + strings.Repeat("00", 34),
+			expErr:  bc.ErrTruncated,
+		},
+		"bump invalid flags": {
+			decoder: "bump",
+			hex:     "0101010103912f77eefdd311e24f96850ed8e701381fc4943327f9cf73f9c4dec0d93a056d",
+			errMsg:  "invalid BUMP leaf flags 3 at offset 4",
+		},
+		"bump trailing bytes": {
+			decoder: "bump",
+			hex:     testBUMP + "00",
+			expErr:  bc.ErrTrailingBytes,
+			errMsg:  "unexpected bytes after data: 1 bytes at offset 37",
+		},
+		"bump non canonical block height": {
+			decoder: "bump",
+			hex:     "fd0100" + testBUMP[2:],
+			expErr:  bc.ErrNonCanonicalVarInt,
+		},
+		"valid merkle path": {
+			decoder: "merkle path",
+			hex:     testMerklePath,
+		},
+		"empty merkle path": {
+			decoder: "merkle path",
+			hex:     "",
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: merkle path index of 1 bytes at offset 0, 0 bytes remain",
+		},
+		"merkle path missing leaf bytes": {
+			decoder: "merkle path",
+			hex:     testMerklePath[:len(testMerklePath)-2],
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: merkle path leaf count of 2 at offset 1 needs at least 32 bytes each, 63 bytes remain",
+		},
+		"merkle path leaf count too large": {
+			decoder: "merkle path",
+			hex:     "0cffffffffffffffffff",
+			expErr:  bc.ErrTruncated,
+		},
+		"merkle path trailing bytes": {
+			decoder: "merkle path",
+			hex:     testMerklePath + "0000",
+			expErr:  bc.ErrTrailingBytes,
+			errMsg:  "unexpected bytes after data: 2 bytes at offset 66",
+		},
+		"merkle path non canonical index": {
+			decoder: "merkle path",
+			hex:     "fe0c000000" + testMerklePath[2:],
+			expErr:  bc.ErrNonCanonicalVarInt,
+			errMsg:  "varint is not canonically encoded: merkle path index of 12 at offset 0",
+		},
+		"valid tx": {
+			decoder: "tx",
+			hex:     testCoinbaseTx,
+		},
+		"valid extended tx": {
+			decoder: "tx",
+			hex:     testExtendedTx,
+		},
+		"empty tx": {
+			decoder: "tx",
+			hex:     "",
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: tx version of 4 bytes at offset 0, 0 bytes remain",
+		},
+		"tx script length too large": {
+			decoder: "tx",
+			hex:     "01000000" + "01" + strings.Repeat("00", 36) + "feffffffff" + "ffffffff" + "00" + "00000000",
+			expErr:  bc.ErrTruncated,
+			errMsg:  "unexpected end of data: tx input script of 4294967295 bytes at offset 46, 9 bytes remain",
+		},
+		"extended tx previous locking script length too large": {
+			decoder: "tx",
+			hex:     testExtendedTx[:130] + "fe" + testExtendedTx[132:],
+			expErr:  bc.ErrTruncated,
+		},
+		"tx trailing bytes": {
+			decoder: "tx",
+			hex:     testCoinbaseTx + "00",
+			expErr:  bc.ErrTrailingBytes,
+			errMsg:  "unexpected bytes after data: 1 bytes at offset 100",
+		},
+		"tx non canonical input count": {
+			decoder: "tx",
+			hex:     "02000000" + "fd0100" + testCoinbaseTx[10:],
+			expErr:  bc.ErrNonCanonicalVarInt,
+			errMsg:  "varint is not canonically encoded: tx input count of 1 at offset 4",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			b, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+
+			err = decoders[test.decoder](b)
+			if test.expErr == nil && test.errMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+			}
+			if test.errMsg != "" {
+				require.EqualError(t, err, test.errMsg)
+			}
+		})
+	}
+}
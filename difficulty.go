@@ -3,38 +3,9 @@ package bc
 import (
 	"encoding/binary"
 	"encoding/hex"
-	"log"
-	"math"
 	"math/big"
-	"strconv"
 )
 
-var (
-	genesisDiff float64
-	regDiff     float64
-)
-
-func init() {
-	bn, err := ExpandTargetFromAsInt("1d00ffff")
-	if err != nil {
-		log.Printf("Error: %+v", err)
-	}
-
-	bf := big.NewFloat(0)
-	bf.SetInt(bn)
-	genesisDiff, _ = bf.Float64()
-	genesisDiff = math.Pow(2, 256) / genesisDiff
-
-	bn, err = ExpandTargetFromAsInt("207fffff")
-	if err != nil {
-		log.Printf("Error: %+v", err)
-	}
-
-	bf.SetInt(bn)
-	regDiff, _ = bf.Float64()
-	regDiff = math.Pow(2, 256) / regDiff
-}
-
 // ExpandTargetFrom comment.
 func ExpandTargetFrom(bits string) (string, error) {
 	bn, err := ExpandTargetFromAsInt(bits)
@@ -86,33 +57,23 @@ func ExpandTargetFromAsInt(bits string) (*big.Int, error) {
 
 // DifficultyToHashrate takes a specific coin ticker, it's difficulty, and target
 // and computes the estimated hashrate on that specific coin (or chain).
+//
+// Deprecated: use NetworkParams.DifficultyToHashrate. Tickers starting with R are treated as
+// regtest and all others as mainnet.
 func DifficultyToHashrate(coin string, diff uint64, targetSeconds float64) float64 {
-	genesis := genesisDiff
-	if coin[0] == 'R' {
-		genesis = regDiff
+	params := MainNet
+	if len(coin) > 0 && coin[0] == 'R' {
+		params = RegTest
 	}
 
-	return float64(diff) * genesis / targetSeconds
+	return params.DifficultyToHashrate(float64(diff), targetSeconds)
 }
 
 // DifficultyFromBits returns the mining difficulty from the nBits field in the block header.
 func DifficultyFromBits(bits []byte) (float64, error) {
-	ib := binary.BigEndian.Uint32(bits)
-	return targetToDifficulty(toCompactSize(ib))
-}
-
-func toCompactSize(bits uint32) *big.Int {
-	t := big.NewInt(int64(bits % 0x01000000))
-	t.Mul(t, big.NewInt(2).Exp(big.NewInt(2), big.NewInt(8*(int64(bits/0x01000000)-3)), nil))
-
-	return t
-}
-
-func targetToDifficulty(target *big.Int) (float64, error) {
-	a := float64(0xFFFF0000000000000000000000000000000000000000000000000000) // genesis difficulty
-	b, err := strconv.ParseFloat(target.String(), 64)
+	target, err := NewTargetFromBits(bits)
 	if err != nil {
-		return 0.0, err
+		return 0, err
 	}
-	return a / b, nil
+	return target.Difficulty(), nil
 }
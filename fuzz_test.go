@@ -0,0 +1,70 @@
+//go:build go1.18
+// +build go1.18
+
+package bc_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+func fuzzSeeds(f *testing.F, ss ...string) {
+	for _, s := range ss {
+		b, err := hex.DecodeString(s)
+		require.NoError(f, err)
+		f.Add(b)
+	}
+}
+
+func FuzzNewMerklePathFromBytes(f *testing.F) {
+	fuzzSeeds(f, testMerklePath, "88040c82025f47b31054e9ad52109ef25b00fd9aaae7153564619bab031d4112f56c3b6ea708d7b84a078179b53cf2cb2f0636162ffd60a96f81815564bbc6c073cdefac0f077fca2a10730400da62ebaebaba852bd5fc3fb7770e090a1919d9c8b41e81e396da7f63e3989a8bc9bdbefddf95c75da1eb3936944b6a55cf82d87034")
+	f.Fuzz(func(t *testing.T, b []byte) {
+		mp, err := bc.NewMerklePathFromBytes(b)
+		if err != nil {
+			return
+		}
+		bb, err := mp.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, b, bb)
+	})
+}
+
+func FuzzNewBUMPFromBytes(f *testing.F) {
+	fuzzSeeds(f, testBUMP,
+		"fd0d020102000209d1fab394d14fc4d5819dffe7af320744b51469569fde7bd76c57c917d91ce10102b98551d22cd578c430374bde629322f72b72c9f64f5425ac307b679cfacd4525",
+		"fe636d0c0007021400fe507c0c7aa754cef1f7889d5fd395cf1f785dd7de98eed895dbedfe4e5bc70d1502ac4e164f5bc16746bb0868404292ac8318bbac3800e4aad13a014da427adce3e010b00bc4ff395efd11719b277694cface5aa50d085a0bb81f613f70313acd28cf4557010400574b2d9142b8d28b61d88e3b2c3f44d858411356b49a28a4643b6d1a6a092a5201030051a05fc84d531b5d250c23f4f886f6812f9fe3f402d61607f977b4ecd2701c19010000fd781529d58fc2523cf396a7f25440b409857e7e221766c57214b1d38c7b481f01010062f542f45ea3660f86c013ced80534cb5fd4c19d66c56e7e8c5d4bf2d40acc5e010100b121e91836fd7cd5102b654e9f72f3cf6fdbfd0b161c53a9c54b12c841126331",
+	)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		bump, err := bc.NewBUMPFromBytes(b)
+		if err != nil {
+			return
+		}
+		// leaves are sorted by offset when decoded, so only the re-encoding is stable.
+		bb, err := bump.Bytes()
+		require.NoError(t, err)
+		again, err := bc.NewBUMPFromBytes(bb)
+		require.NoError(t, err)
+		require.Equal(t, bump, again)
+		bbb, err := again.Bytes()
+		require.NoError(t, err)
+		require.Equal(t, bb, bbb)
+	})
+}
+
+func FuzzNewBlockFromBytes(f *testing.F) {
+	fuzzSeeds(f, testBlockHeader+"01"+testCoinbaseTx, testBlockHeader+"00")
+	f.Fuzz(func(t *testing.T, b []byte) {
+		block, err := bc.NewBlockFromBytes(b)
+		if err != nil {
+			return
+		}
+		require.Equal(t, b, block.Bytes())
+		again, err := bc.NewBlockFromBytes(block.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, block, again)
+	})
+}
@@ -0,0 +1,114 @@
+package bc
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	// ErrNoHashrate is returned when a hashrate cannot be estimated because the blocks in
+	// the window all have the same time.
+	ErrNoHashrate = errors.New("no hashrate, blocks in the window have the same time")
+
+	// ErrEmptyWindow is returned when a hashrate is requested over zero blocks.
+	ErrEmptyWindow = errors.New("hashrate window must contain at least one block")
+)
+
+// NetworkHashrate estimates the hashrate of the network, in hashes per second, over the last
+// blocks headers of chain, which can be formatted with HumanHash.
+//
+// As in the node's getnetworkhashps, it is the work done by the blocks divided by the time
+// between the earliest and latest timestamps in the window, so it follows the actual rate
+// blocks were found rather than the target block time.
+func NetworkHashrate(ctx context.Context, chain HeightIndexedBlockHeaderChain, blocks uint64) (float64, error) {
+	height, _, err := chain.ChainTip(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return NetworkHashrateAt(ctx, chain, height, blocks)
+}
+
+// NetworkHashrateAt estimates the hashrate of the network over the blocks headers up to and
+// including the block at height. The window is shortened to the blocks available when it
+// reaches past the genesis block, and the hashrate at the genesis block is zero.
+func NetworkHashrateAt(ctx context.Context, chain HeightIndexedBlockHeaderChain, height,
+	blocks uint64) (float64, error) {
+	if blocks == 0 {
+		return 0, ErrEmptyWindow
+	}
+	if height == 0 {
+		return 0, nil
+	}
+	if blocks > height {
+		blocks = height
+	}
+
+	// the block before the window is included for its timestamp, but not its work.
+	var work ChainWork
+	var minTime, maxTime int64
+	for h := height - blocks; h <= height; h++ {
+		bh, err := chain.BlockHeaderByHeight(ctx, h)
+		if err != nil {
+			return 0, err
+		}
+		t := int64(bh.Time)
+		if h == height-blocks || t < minTime {
+			minTime = t
+		}
+		if t > maxTime {
+			maxTime = t
+		}
+		if h == height-blocks {
+			continue
+		}
+
+		target, err := NewTargetFromBits(bh.Bits)
+		if err != nil {
+			return 0, err
+		}
+		work = work.Add(target.Work())
+	}
+	if maxTime == minTime {
+		return 0, ErrNoHashrate
+	}
+
+	hashrate, _ := new(big.Rat).SetFrac(work.Int(), big.NewInt(maxTime-minTime)).Float64()
+	return hashrate, nil
+}
+
+// PredictNextBits returns the bits the network's difficulty rules will require of the next
+// block after the tip of chain, if it is mined at the time provided.
+func PredictNextBits(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	at time.Time) ([]byte, error) {
+	return NextWorkRequired(ctx, chain, params, &BlockHeader{Time: uint32(at.Unix())})
+}
+
+// ExpectedTimeToNextBlock estimates how long the network will take to find the next block after
+// the tip of chain, from the time provided. It is the work required by the predicted bits of the
+// next block divided by the hashrate over the last blocks headers.
+//
+// Finding a block does not get more likely the longer it has been since the last one, so the
+// estimate does not depend on when the tip was found, only on the difficulty at the time given.
+func ExpectedTimeToNextBlock(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	blocks uint64, at time.Time) (time.Duration, error) {
+	hashrate, err := NetworkHashrate(ctx, chain, blocks)
+	if err != nil {
+		return 0, err
+	}
+	if hashrate == 0 {
+		return 0, ErrNoHashrate
+	}
+	bits, err := PredictNextBits(ctx, chain, params, at)
+	if err != nil {
+		return 0, err
+	}
+	target, err := NewTargetFromBits(bits)
+	if err != nil {
+		return 0, err
+	}
+
+	work, _ := new(big.Float).SetInt(target.Work().Int()).Float64()
+	return time.Duration(work / hashrate * float64(time.Second)), nil
+}
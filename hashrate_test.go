@@ -0,0 +1,115 @@
+package bc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestNetworkHashrateAt(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 4033)
+
+	tests := map[string]struct {
+		height   uint64
+		blocks   uint64
+		hashrate float64
+		human    string
+		expErr   error
+	}{
+		"last 120 blocks": {
+			height:   4032,
+			blocks:   120,
+			hashrate: 5878104.285486189,
+			human:    "5.88 MH/s",
+		},
+		"first retarget period": {
+			height:   2015,
+			blocks:   2015,
+			hashrate: 4210425.22613575,
+			human:    "4.21 MH/s",
+		},
+		"window past genesis is shortened": {
+			height:   4032,
+			blocks:   10000,
+			hashrate: 5005534.42948014,
+			human:    "5.01 MH/s",
+		},
+		"genesis": {
+			height: 0,
+			blocks: 120,
+			human:  "0.00 H/s",
+		},
+		"empty window": {
+			height: 4032,
+			blocks: 0,
+			expErr: bc.ErrEmptyWindow,
+		},
+		"height not in chain": {
+			height: 5000,
+			blocks: 120,
+			expErr: bc.ErrHeaderNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hashrate, err := bc.NetworkHashrateAt(ctx, chain, test.height, test.blocks)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.InDelta(t, test.hashrate, hashrate, 1e-6)
+			require.Equal(t, test.human, bc.HumanHash(hashrate))
+		})
+	}
+}
+
+func TestNetworkHashrate(t *testing.T) {
+	ctx := context.Background()
+
+	hashrate, err := bc.NetworkHashrate(ctx, newIndexedChain(t, 4033), 120)
+	require.NoError(t, err)
+	require.InDelta(t, 5878104.285486189, hashrate, 1e-6)
+
+	// blocks which all have the same time give no rate.
+	_, err = bc.NetworkHashrate(ctx, indexedChainOf(syntheticChain(10, 0x1d00ffff, spaced(0))), 5)
+	require.ErrorIs(t, err, bc.ErrNoHashrate)
+}
+
+func TestPredictNextBits(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+
+	// the first retarget.
+	bits, err := bc.PredictNextBits(ctx, indexedChainOf(hh[:2016]), bc.MainNet, time.Unix(int64(hh[2016].Time), 0))
+	require.NoError(t, err)
+	require.Equal(t, hh[2016].Bits, bits)
+
+	// testnet allows a minimum difficulty block 20 minutes after the last.
+	chain := indexedChainOf(syntheticChain(200, 0x1c0fffff, spaced(600)))
+	tip := time.Unix(int64(spaced(600)(199)), 0)
+	bits, err = bc.PredictNextBits(ctx, chain, bc.TestNet, tip.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, bitsOf(0x1c0fffff), bits)
+	bits, err = bc.PredictNextBits(ctx, chain, bc.TestNet, tip.Add(21*time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, bitsOf(0x1d00ffff), bits)
+}
+
+func TestExpectedTimeToNextBlock(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 4033)
+
+	d, err := bc.ExpectedTimeToNextBlock(ctx, chain, bc.MainNet, 120, time.Unix(int64(chain.headers[4032].Time), 0))
+	require.NoError(t, err)
+	require.InDelta(t, 730.6833333333333, d.Seconds(), 1e-6)
+
+	_, err = bc.ExpectedTimeToNextBlock(ctx, chain, bc.MainNet, 0, time.Now())
+	require.ErrorIs(t, err, bc.ErrEmptyWindow)
+}
@@ -0,0 +1,218 @@
+package bc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+)
+
+// BlockHeaderSize is the size of a serialised block header in bytes.
+const BlockHeaderSize = 80
+
+// ErrHeaderLength is returned when a serialised block header is not BlockHeaderSize bytes long.
+var ErrHeaderLength = errors.New("block header should be 80 bytes long")
+
+// Header is a block header held in its fixed size, serialised form, with the hash of the
+// header computed once when it is decoded. Unlike BlockHeader, decoding, encoding and
+// reading its fields does not allocate, so it suits code handling many headers, such as
+// header sync.
+//
+// A Header is read only, use BlockHeader to build or modify a header. The zero value is
+// a header of zeroes.
+type Header struct {
+	raw    [BlockHeaderSize]byte
+	hash   chainhash.Hash
+	hashed bool
+}
+
+// NewHeaderFromBytes returns the header serialised in b, which must be BlockHeaderSize
+// bytes long.
+func NewHeaderFromBytes(b []byte) (Header, error) {
+	var h Header
+	err := h.UnmarshalBinary(b)
+	return h, err
+}
+
+// UnmarshalBinary decodes a serialised header into h.
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) != BlockHeaderSize {
+		return ErrHeaderLength
+	}
+	copy(h.raw[:], b)
+	h.hash = chainhash.DoubleHashH(h.raw[:])
+	h.hashed = true
+	return nil
+}
+
+// MarshalBinary returns the serialised header.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	b := make([]byte, BlockHeaderSize)
+	copy(b, h.raw[:])
+	return b, nil
+}
+
+// Bytes returns the serialised header.
+func (h *Header) Bytes() [BlockHeaderSize]byte {
+	return h.raw
+}
+
+// String returns the serialised header as a hex string.
+func (h *Header) String() string {
+	return hex.EncodeToString(h.raw[:])
+}
+
+// Hash returns the hash of the header, the double sha256 of the serialised header.
+func (h *Header) Hash() chainhash.Hash {
+	if h.hashed {
+		return h.hash
+	}
+	return chainhash.DoubleHashH(h.raw[:])
+}
+
+// Version returns the block version.
+func (h *Header) Version() uint32 {
+	return binary.LittleEndian.Uint32(h.raw[0:4])
+}
+
+// PrevBlock returns the hash of the previous block header.
+func (h *Header) PrevBlock() chainhash.Hash {
+	var hash chainhash.Hash
+	copy(hash[:], h.raw[4:36])
+	return hash
+}
+
+// MerkleRoot returns the merkle root of the transactions in the block.
+func (h *Header) MerkleRoot() chainhash.Hash {
+	var hash chainhash.Hash
+	copy(hash[:], h.raw[36:68])
+	return hash
+}
+
+// Time returns the block timestamp, as seconds since 1970-01-01T00:00 UTC.
+func (h *Header) Time() uint32 {
+	return binary.LittleEndian.Uint32(h.raw[68:72])
+}
+
+// Bits returns the target of the block in compact form.
+func (h *Header) Bits() uint32 {
+	return binary.LittleEndian.Uint32(h.raw[72:76])
+}
+
+// Nonce returns the nonce of the block.
+func (h *Header) Nonce() uint32 {
+	return binary.LittleEndian.Uint32(h.raw[76:80])
+}
+
+// Valid checks whether the header satisfies the proof-of-work claimed in its bits, as
+// BlockHeader.Valid does, without allocating.
+func (h *Header) Valid() bool {
+	hash := h.Hash()
+	return hashBelowCompact(&hash, h.Bits())
+}
+
+// BlockHeader returns the header as a BlockHeader.
+func (h *Header) BlockHeader() *BlockHeader {
+	bh, _ := NewBlockHeaderFromBytes(h.raw[:])
+	return bh
+}
+
+// MarshalJSON marshals the header into the same JSON as BlockHeader.
+func (h *Header) MarshalJSON() ([]byte, error) {
+	return json.Marshal(h.BlockHeader())
+}
+
+// UnmarshalJSON unmarshals the JSON of a BlockHeader into the header.
+func (h *Header) UnmarshalJSON(b []byte) error {
+	var bh BlockHeader
+	if err := json.Unmarshal(b, &bh); err != nil {
+		return err
+	}
+	if len(bh.HashPrevBlock) != chainhash.HashSize || len(bh.HashMerkleRoot) != chainhash.HashSize || len(bh.Bits) != 4 {
+		return fmt.Errorf("block header has a %d byte previous block hash, %d byte merkle root and %d byte bits",
+			len(bh.HashPrevBlock), len(bh.HashMerkleRoot), len(bh.Bits))
+	}
+	*h = bh.Header()
+	return nil
+}
+
+// Header returns the block header as a Header. HashPrevBlock and HashMerkleRoot should be
+// 32 bytes and Bits 4 bytes long, longer fields are truncated and shorter fields zero padded.
+func (bh *BlockHeader) Header() Header {
+	var h Header
+	binary.LittleEndian.PutUint32(h.raw[0:4], bh.Version)
+	putReversed(h.raw[4:36], bh.HashPrevBlock)
+	putReversed(h.raw[36:68], bh.HashMerkleRoot)
+	binary.LittleEndian.PutUint32(h.raw[68:72], bh.Time)
+	putReversed(h.raw[72:76], bh.Bits)
+	binary.LittleEndian.PutUint32(h.raw[76:80], bh.Nonce)
+	h.hash = chainhash.DoubleHashH(h.raw[:])
+	h.hashed = true
+	return h
+}
+
+// Hash returns the hash of the block header. It is computed on each call as the fields of
+// a BlockHeader can change, use Header to hash a header once.
+func (bh *BlockHeader) Hash() chainhash.Hash {
+	h := bh.Header()
+	return h.Hash()
+}
+
+// PrevBlockHash returns the hash of the previous block header.
+func (bh *BlockHeader) PrevBlockHash() chainhash.Hash {
+	var hash chainhash.Hash
+	putReversed(hash[:], bh.HashPrevBlock)
+	return hash
+}
+
+// MerkleRootHash returns the merkle root of the transactions in the block.
+func (bh *BlockHeader) MerkleRootHash() chainhash.Hash {
+	var hash chainhash.Hash
+	putReversed(hash[:], bh.HashMerkleRoot)
+	return hash
+}
+
+// putReversed copies src into dst in reverse byte order.
+func putReversed(dst, src []byte) {
+	for i := 0; i < len(dst) && i < len(src); i++ {
+		dst[i] = src[len(src)-1-i]
+	}
+}
+
+// hashBelowCompact returns true if the hash, read as a little endian number, is below the
+// target represented by compact bits. As in the node, negative and overflowing bits are
+// never met.
+func hashBelowCompact(hash *chainhash.Hash, compact uint32) bool {
+	size := int(compact >> 24)
+	word := compact & 0x007fffff
+	if word != 0 && compact&0x00800000 != 0 {
+		return false
+	}
+	if word != 0 && (size > 34 || (word > 0xff && size > 33) || (word > 0xffff && size > 32)) {
+		return false
+	}
+
+	// expand the target into 32 little endian bytes, the checks above ensure the non zero
+	// bytes of the mantissa fit.
+	var target [sha256.Size]byte
+	if size <= 3 {
+		word >>= 8 * uint(3-size)
+		size = 3
+	}
+	for i, b := range [3]byte{byte(word), byte(word >> 8), byte(word >> 16)} {
+		if p := size - 3 + i; p < len(target) {
+			target[p] = b
+		}
+	}
+
+	for i := len(target) - 1; i >= 0; i-- {
+		if hash[i] != target[i] {
+			return hash[i] < target[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package bc_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+func TestNewHeaderFromBytes(t *testing.T) {
+	tests := map[string]struct {
+		header     string
+		expHash    string
+		expPrev    string
+		expRoot    string
+		expTime    uint32
+		expBits    uint32
+		expNonce   uint32
+		expErr     error
+		expVersion uint32
+	}{
+		"genesis": {
+			header:     "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c",
+			expHash:    "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			expPrev:    "0000000000000000000000000000000000000000000000000000000000000000",
+			expRoot:    "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+			expTime:    1231006505,
+			expBits:    0x1d00ffff,
+			expNonce:   2083236893,
+			expVersion: 1,
+		},
+		"block 1": {
+			header:     "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e36299",
+			expHash:    "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+			expPrev:    "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+			expRoot:    "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",
+			expTime:    1231469665,
+			expBits:    0x1d00ffff,
+			expNonce:   2573394689,
+			expVersion: 1,
+		},
+		"too short": {
+			header: "0100000000000000",
+			expErr: bc.ErrHeaderLength,
+		},
+		"too long": {
+			header: "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c00",
+			expErr: bc.ErrHeaderLength,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := hex.DecodeString(test.header)
+			require.NoError(t, err)
+
+			h, err := bc.NewHeaderFromBytes(b)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			hash := h.Hash()
+			prev := h.PrevBlock()
+			root := h.MerkleRoot()
+			require.Equal(t, test.expHash, hash.String())
+			require.Equal(t, test.expPrev, prev.String())
+			require.Equal(t, test.expRoot, root.String())
+			require.Equal(t, test.expVersion, h.Version())
+			require.Equal(t, test.expTime, h.Time())
+			require.Equal(t, test.expBits, h.Bits())
+			require.Equal(t, test.expNonce, h.Nonce())
+			require.Equal(t, test.header, h.String())
+			require.True(t, h.Valid())
+
+			raw := h.Bytes()
+			require.Equal(t, b, raw[:])
+			mb, err := h.MarshalBinary()
+			require.NoError(t, err)
+			require.Equal(t, b, mb)
+
+			// the block header agrees with the header.
+			bh := h.BlockHeader()
+			require.Equal(t, test.header, bh.String())
+			require.Equal(t, hash, bh.Hash())
+			require.Equal(t, prev, bh.PrevBlockHash())
+			require.Equal(t, root, bh.MerkleRootHash())
+			require.Equal(t, h, bh.Header())
+		})
+	}
+}
+
+func TestHeader_MatchesBlockHeader(t *testing.T) {
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+
+	for i := 0; i+bc.BlockHeaderSize <= len(b); i += bc.BlockHeaderSize {
+		raw := b[i : i+bc.BlockHeaderSize]
+		h, err := bc.NewHeaderFromBytes(raw)
+		require.NoError(t, err)
+		bh, err := bc.NewBlockHeaderFromBytes(raw)
+		require.NoError(t, err)
+
+		require.Equal(t, raw, bh.Bytes())
+		require.Equal(t, bh.Hash(), h.Hash())
+		require.True(t, h.Valid())
+		require.True(t, bh.Valid())
+	}
+}
+
+func TestHeader_Valid(t *testing.T) {
+	// the hash of the genesis block, read as a little endian number, is
+	// 0x000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f.
+	genesis := bc.MainNet.GenesisHeader
+
+	tests := map[string]struct {
+		bits uint32
+	}{
+		"genesis bits": {
+			bits: 0x1d00ffff,
+		},
+		"just above the hash": {
+			bits: 0x1b19d669,
+		},
+		"just below the hash": {
+			bits: 0x1b19d668,
+		},
+		"equal to the leading bytes of the hash": {
+			bits: 0x1a19d668,
+		},
+		"easiest regtest bits": {
+			bits: 0x207fffff,
+		},
+		"largest target": {
+			bits: 0x2100ffff,
+		},
+		"small size": {
+			bits: 0x02008000,
+		},
+		"zero": {
+			bits: 0,
+		},
+		"negative": {
+			bits: 0x1d80ffff,
+		},
+		"overflow": {
+			bits: 0x2200ffff,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bh := *genesis
+			bh.Bits = []byte{byte(test.bits >> 24), byte(test.bits >> 16), byte(test.bits >> 8), byte(test.bits)}
+			h := bh.Header()
+			hash := h.Hash()
+
+			// compare with the target expanded as a big.Int, whatever the nonce.
+			exp := false
+			if target, err := bc.NewTargetFromCompact(test.bits); err == nil {
+				digest, err := hex.DecodeString(hash.String())
+				require.NoError(t, err)
+				exp = new(big.Int).SetBytes(digest).Cmp(target.Int()) < 0
+			}
+			require.Equal(t, exp, h.Valid())
+			require.Equal(t, exp, bh.Valid())
+		})
+	}
+}
+
+func TestHeader_Allocations(t *testing.T) {
+	genesis := bc.MainNet.GenesisHeader.Header()
+	raw := genesis.Bytes()
+
+	var h bc.Header
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := h.UnmarshalBinary(raw[:]); err != nil {
+			t.Fatal(err)
+		}
+		_ = h.Hash()
+		_ = h.PrevBlock()
+		_ = h.MerkleRoot()
+		_ = h.Valid()
+		_ = h.Bytes()
+	})
+	require.Zero(t, allocs)
+
+	bh := bc.MainNet.GenesisHeader
+	allocs = testing.AllocsPerRun(100, func() {
+		_ = bh.Hash()
+		_ = bh.Valid()
+		_ = bh.PrevBlockHash()
+	})
+	require.Zero(t, allocs)
+
+	allocs = testing.AllocsPerRun(100, func() {
+		_ = bh.Bytes()
+	})
+	require.Equal(t, float64(1), allocs)
+}
+
+func TestHeader_JSON(t *testing.T) {
+	bh, err := bc.NewBlockHeaderFromStr("00000020332bca82fa601bcee3cc00a703988b7126079a75a03ea87654d6b544df73156ae73339a84a36cc3d1c5dc452a6b6bc2ee33c3f583cab9bcf9542b199458fea4844183661ffff7f2000000000")
+	require.NoError(t, err)
+	h := bh.Header()
+
+	// a header marshals to the same JSON as a block header, and each unmarshals the other.
+	hj, err := json.Marshal(&h)
+	require.NoError(t, err)
+	bhj, err := json.Marshal(bh)
+	require.NoError(t, err)
+	require.JSONEq(t, string(bhj), string(hj))
+
+	var fromBlockHeader bc.Header
+	require.NoError(t, json.Unmarshal(bhj, &fromBlockHeader))
+	require.Equal(t, h, fromBlockHeader)
+	var fromHeader bc.BlockHeader
+	require.NoError(t, json.Unmarshal(hj, &fromHeader))
+	require.Equal(t, bh, &fromHeader)
+
+	tests := map[string]struct {
+		json string
+	}{
+		"short hash": {
+			json: `{"version": 1, "hashPrevBlock": "00", "merkleRoot": "48ea8f4599b14295cf9bab3c583f3ce32ebcb6a652c45d1c3dcc364aa83933e7", "bits": "207fffff"}`,
+		},
+		"missing bits": {
+			json: `{"version": 1, "hashPrevBlock": "6a1573df44b5d65476a83ea0759a0726718b9803a700cce3ce1b60fa82ca2b33", "merkleRoot": "48ea8f4599b14295cf9bab3c583f3ce32ebcb6a652c45d1c3dcc364aa83933e7"}`,
+		},
+		"invalid hex": {
+			json: `{"version": 1, "hashPrevBlock": "zz", "merkleRoot": "", "bits": ""}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var h bc.Header
+			require.Error(t, json.Unmarshal([]byte(test.json), &h))
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package headers
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/libsv/go-bk/bec"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+/*
+Bundle layout
+
+Field 						Purpose 															Size (Bytes)
+----------------------------------------------------------------------------------------------------
+snapshot 					a snapshot of the headers, as written by Export 					24 + 80 * count + 32
+signature length 			length of the signature 											1
+signature 					DER encoded signature of the snapshot checksum 						signature length
+*/
+
+var (
+	// ErrInvalidBundle is returned when a header bundle is malformed.
+	ErrInvalidBundle = errors.New("invalid header bundle")
+
+	// ErrUntrustedBundle is returned when a header bundle is not signed by a trusted key and
+	// does not end at a checkpoint.
+	ErrUntrustedBundle = errors.New("header bundle is not signed by a trusted key or anchored to a checkpoint")
+)
+
+type bundleOptions struct {
+	keys        []*bec.PublicKey
+	checkpoints []bc.Checkpoint
+}
+
+// BundleOpt defines a functional option that is used to modify the behaviour of ImportBundle.
+type BundleOpt func(*bundleOptions)
+
+// WithTrustedKeys sets the keys a bundle can be signed by.
+func WithTrustedKeys(keys ...*bec.PublicKey) BundleOpt {
+	return func(o *bundleOptions) {
+		o.keys = keys
+	}
+}
+
+// WithBundleCheckpoints sets the checkpoints the headers of a bundle must match.
+func WithBundleCheckpoints(checkpoints ...bc.Checkpoint) BundleOpt {
+	return func(o *bundleOptions) {
+		o.checkpoints = checkpoints
+	}
+}
+
+// ExportBundle writes a bundle of the stored headers between the from and to heights, inclusive,
+// to w, signed by key. It can be loaded into another store with ImportBundle.
+func (s *FileStore) ExportBundle(ctx context.Context, w io.Writer, from, to uint64, key *bec.PrivateKey) error {
+	var snapshot bytes.Buffer
+	if err := s.Export(ctx, &snapshot, from, to); err != nil {
+		return err
+	}
+	sig, err := key.Sign(snapshot.Bytes()[snapshot.Len()-32:])
+	if err != nil {
+		return errors.Wrap(err, "failed to sign bundle")
+	}
+	der := sig.Serialise()
+
+	if _, err = w.Write(snapshot.Bytes()); err != nil {
+		return errors.Wrap(err, "failed to write bundle")
+	}
+	_, err = w.Write(append([]byte{byte(len(der))}, der...))
+
+	return errors.Wrap(err, "failed to write bundle")
+}
+
+// ImportBundle reads a bundle written by ExportBundle and adds its headers to the store, as
+// Import does, so a store can start from a recent checkpoint rather than the genesis block.
+// The headers are trusted without their proof of work or difficulty being checked, headers
+// after the bundle should be added with a Syncer which fully validates them. The bundle must
+// hold enough headers for the difficulty rules to be applied to the next header, 2016 for the
+// legacy retarget and 147 for the CW-144 difficulty adjustment.
+//
+// The store can then be given to spv.NewPaymentVerifier without the full header history, so
+// long as the proofs it verifies are for blocks at or above the start of the bundle.
+//
+// A bundle is trusted when it is signed by one of the keys given by WithTrustedKeys, or when
+// its last header is one of the checkpoints given by WithBundleCheckpoints, as a checkpoint
+// authenticates every header linked below it. ErrUntrustedBundle is returned otherwise. Any
+// checkpoint within the bundle must match, or ErrCheckpointMismatch is returned.
+func (s *FileStore) ImportBundle(_ context.Context, r io.Reader, opts ...BundleOpt) error {
+	o := &bundleOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	start, raw, sum, err := readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	count := uint64(len(raw) / HeaderSize)
+	if count == 0 {
+		return errors.Wrap(ErrInvalidBundle, "no headers")
+	}
+	length := make([]byte, 1)
+	if _, err = io.ReadFull(r, length); err != nil {
+		return errors.Wrap(ErrInvalidBundle, "missing signature")
+	}
+	der := make([]byte, length[0])
+	if _, err = io.ReadFull(r, der); err != nil {
+		return errors.Wrap(ErrInvalidBundle, "truncated signature")
+	}
+	if n, _ := r.Read(make([]byte, 1)); n != 0 {
+		return errors.Wrap(ErrInvalidBundle, "trailing bytes")
+	}
+
+	trusted := false
+	if sig, err := bec.ParseDERSignature(der, bec.S256()); err == nil {
+		for _, key := range o.keys {
+			if sig.Verify(sum, key) {
+				trusted = true
+				break
+			}
+		}
+	}
+
+	last := start + count - 1
+	for _, cp := range o.checkpoints {
+		if cp.Height < start || cp.Height > last {
+			continue
+		}
+		i := cp.Height - start
+		if hash := blockHash(raw[i*HeaderSize : (i+1)*HeaderSize]); hash.String() != cp.Hash {
+			return errors.Wrapf(ErrCheckpointMismatch, "bundle has %s at height %d, expected %s", hash, cp.Height, cp.Hash)
+		}
+		if cp.Height == last {
+			trusted = true
+		}
+	}
+	if !trusted {
+		return ErrUntrustedBundle
+	}
+
+	return s.importHeaders(start, raw)
+}
@@ -0,0 +1,203 @@
+package headers_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/libsv/go-bk/bec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headers"
+)
+
+func TestFileStore_ImportBundle(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+
+	src := openStore(t, filepath.Join(t.TempDir(), "src.dat"))
+	require.NoError(t, src.Append(ctx, hh...))
+
+	key, err := bec.NewPrivateKey(bec.S256())
+	require.NoError(t, err)
+	other, err := bec.NewPrivateKey(bec.S256())
+	require.NoError(t, err)
+
+	var bundle bytes.Buffer
+	require.NoError(t, src.ExportBundle(ctx, &bundle, 2016, 3000, key))
+
+	tests := map[string]struct {
+		bundle []byte
+		opts   []headers.BundleOpt
+		expErr error
+	}{
+		"signed by a trusted key": {
+			bundle: bundle.Bytes(),
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(other.PubKey(), key.PubKey())},
+		},
+		"anchored to a checkpoint": {
+			bundle: bundle.Bytes(),
+			opts: []headers.BundleOpt{headers.WithBundleCheckpoints(
+				bc.Checkpoint{Height: 3000, Hash: hashOf(hh[3000])},
+			)},
+		},
+		"signed by a trusted key and matching a checkpoint": {
+			bundle: bundle.Bytes(),
+			opts: []headers.BundleOpt{
+				headers.WithTrustedKeys(key.PubKey()),
+				headers.WithBundleCheckpoints(bc.Checkpoint{Height: 2500, Hash: hashOf(hh[2500])}),
+			},
+		},
+		"checkpoints outside the bundle are ignored": {
+			bundle: bundle.Bytes(),
+			opts: []headers.BundleOpt{
+				headers.WithTrustedKeys(key.PubKey()),
+				headers.WithBundleCheckpoints(bc.Checkpoint{Height: 11111, Hash: strings.Repeat("0", 64)}),
+			},
+		},
+		"signed by an untrusted key": {
+			bundle: bundle.Bytes(),
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(other.PubKey())},
+			expErr: headers.ErrUntrustedBundle,
+		},
+		"checkpoint before the last header does not anchor it": {
+			bundle: bundle.Bytes(),
+			opts: []headers.BundleOpt{headers.WithBundleCheckpoints(
+				bc.Checkpoint{Height: 2999, Hash: hashOf(hh[2999])},
+			)},
+			expErr: headers.ErrUntrustedBundle,
+		},
+		"checkpoint mismatch": {
+			bundle: bundle.Bytes(),
+			opts: []headers.BundleOpt{
+				headers.WithTrustedKeys(key.PubKey()),
+				headers.WithBundleCheckpoints(bc.Checkpoint{Height: 2500, Hash: hashOf(hh[2501])}),
+			},
+			expErr: headers.ErrCheckpointMismatch,
+		},
+		"missing signature": {
+			bundle: bundle.Bytes()[:24+985*80+32],
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(key.PubKey())},
+			expErr: headers.ErrInvalidBundle,
+		},
+		"truncated signature": {
+			bundle: bundle.Bytes()[:bundle.Len()-1],
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(key.PubKey())},
+			expErr: headers.ErrInvalidBundle,
+		},
+		"trailing bytes": {
+			bundle: append(append([]byte{}, bundle.Bytes()...), 0),
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(key.PubKey())},
+			expErr: headers.ErrInvalidBundle,
+		},
+		"corrupt snapshot": {
+			bundle: append([]byte("XXXX"), bundle.Bytes()[4:]...),
+			opts:   []headers.BundleOpt{headers.WithTrustedKeys(key.PubKey())},
+			expErr: headers.ErrInvalidSnapshot,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+			err := dst.ImportBundle(ctx, bytes.NewReader(test.bundle), test.opts...)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				_, _, err = dst.ChainTip(ctx)
+				require.ErrorIs(t, err, headers.ErrEmptyStore)
+				return
+			}
+			require.NoError(t, err)
+
+			height, hash, err := dst.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(3000), height)
+			require.Equal(t, hashOf(hh[3000]), hash)
+			_, err = dst.BlockHeaderByHeight(ctx, 2015)
+			require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+		})
+	}
+}
+
+func TestSyncer_FromBundle(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+
+	src := openStore(t, filepath.Join(t.TempDir(), "src.dat"))
+	require.NoError(t, src.Append(ctx, hh[:3001]...))
+	key, err := bec.NewPrivateKey(bec.S256())
+	require.NoError(t, err)
+	var bundle bytes.Buffer
+	require.NoError(t, src.ExportBundle(ctx, &bundle, 2016, 3000, key))
+
+	// a store started from a bundle syncs and fully validates the headers after it.
+	s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+	require.NoError(t, s.ImportBundle(ctx, &bundle, headers.WithTrustedKeys(key.PubKey())))
+	syncer, err := headers.NewSyncer(s, headers.WithNetwork(bc.MainNet))
+	require.NoError(t, err)
+
+	n, err := syncer.ProcessHeaders(ctx, hh[3001:]...)
+	require.NoError(t, err)
+	require.Equal(t, 1032, n)
+
+	// headers below the bundle cannot be added.
+	_, err = syncer.ProcessHeaders(ctx, hh[1:100]...)
+	require.ErrorIs(t, err, headers.ErrHeaderDoesNotConnect)
+}
+
+func TestReadCheckpoints(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		exp    []bc.Checkpoint
+		expErr error
+	}{
+		"sorted by height": {
+			config: `[
+				{"height": 33333, "hash": "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"},
+				{"height": 11111, "hash": "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"}
+			]`,
+			exp: []bc.Checkpoint{
+				{Height: 11111, Hash: "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"},
+				{Height: 33333, Hash: "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"},
+			},
+		},
+		"empty": {
+			config: `[]`,
+			exp:    []bc.Checkpoint{},
+		},
+		"short hash": {
+			config: `[{"height": 11111, "hash": "69e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"}]`,
+			expErr: headers.ErrInvalidCheckpoint,
+		},
+		"invalid hash": {
+			config: `[{"height": 11111, "hash": "zz00000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"}]`,
+			expErr: headers.ErrInvalidCheckpoint,
+		},
+		"duplicate height": {
+			config: `[
+				{"height": 11111, "hash": "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"},
+				{"height": 11111, "hash": "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"}
+			]`,
+			expErr: headers.ErrInvalidCheckpoint,
+		},
+		"not json": {
+			config: `11111=0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d`,
+			expErr: headers.ErrInvalidCheckpoint,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkpoints, err := headers.ReadCheckpoints(strings.NewReader(test.config))
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, checkpoints)
+		})
+	}
+}
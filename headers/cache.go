@@ -0,0 +1,410 @@
+package headers
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+type cacheOptions struct {
+	size        int
+	negativeTTL time.Duration
+	now         func() time.Time
+}
+
+// CacheOpt defines a functional option that is used to modify the behaviour of a Cache.
+type CacheOpt func(*cacheOptions)
+
+// WithCacheSize sets the number of headers the cache holds, it defaults to 10000.
+func WithCacheSize(n int) CacheOpt {
+	return func(o *cacheOptions) {
+		o.size = n
+	}
+}
+
+// WithNegativeTTL sets how long a header which is not found, or not on the longest chain, is
+// remembered for, it defaults to 10 seconds. A zero duration disables negative caching.
+func WithNegativeTTL(d time.Duration) CacheOpt {
+	return func(o *cacheOptions) {
+		o.negativeTTL = d
+	}
+}
+
+// WithCacheClock sets the clock negative cache entries expire against, it defaults to time.Now.
+func WithCacheClock(now func() time.Time) CacheOpt {
+	return func(o *cacheOptions) {
+		o.now = now
+	}
+}
+
+// A Cache is a bc.HeightIndexedBlockHeaderChain which caches the longest chain headers of
+// another bc.BlockHeaderChain, such as a client of a remote node, so it can be given to
+// spv.NewPaymentVerifier in place of the chain it wraps.
+//
+// The most recently used headers are kept, concurrent lookups of the same header are made
+// once, and headers which are not found or not on the longest chain are remembered for a
+// short time. The chain tip is never cached.
+//
+// The cache must be told of reorgs, by Reorg, so it drops headers which may no longer be on
+// the longest chain, a Syncer can do this with WithReorgHandler. Height lookups return
+// bc.ErrNoHeightIndex if the wrapped chain is not a bc.HeightIndexedBlockHeaderChain.
+//
+// Headers returned by the cache are shared and must not be modified.
+type Cache struct {
+	chain bc.BlockHeaderChain
+	opts  *cacheOptions
+
+	mu      sync.Mutex
+	lru     *list.List
+	hashes  map[string]*list.Element
+	heights map[uint64]*list.Element
+	misses  map[string]cacheMiss
+	// gen changes whenever the cache is invalidated, so lookups which started before an
+	// invalidation do not add what they find.
+	gen    uint64
+	flight flightGroup
+}
+
+type cacheEntry struct {
+	hash      string
+	header    *bc.BlockHeader
+	height    uint64
+	hasHeight bool
+}
+
+type cacheMiss struct {
+	err     error
+	expires time.Time
+}
+
+// NewCache returns a Cache of the chain provided.
+func NewCache(chain bc.BlockHeaderChain, opts ...CacheOpt) (*Cache, error) {
+	if chain == nil {
+		return nil, errors.New("a block header chain must be provided")
+	}
+	o := &cacheOptions{
+		size:        10000,
+		negativeTTL: 10 * time.Second,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.size < 1 {
+		return nil, errors.Errorf("cache size must be positive, got %d", o.size)
+	}
+
+	c := &Cache{chain: chain, opts: o}
+	c.reset()
+	return c, nil
+}
+
+// BlockHeader returns the header with the hash provided from the cache, or the wrapped chain.
+func (c *Cache) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	c.mu.Lock()
+	if e, ok := c.hashes[blockHash]; ok && e.Value.(*cacheEntry).header != nil {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).header, nil
+	}
+	if err := c.missed(blockHash); err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	v, err := c.flight.do(ctx, "header:"+blockHash, func(ctx context.Context) (interface{}, error) {
+		return c.chain.BlockHeader(ctx, blockHash)
+	})
+	if err != nil {
+		c.miss(gen, blockHash, err)
+		return nil, err
+	}
+	bh := v.(*bc.BlockHeader)
+	c.add(gen, &cacheEntry{hash: blockHash, header: bh})
+	return bh, nil
+}
+
+// BlockHeight returns the height of the header with the hash provided, on the longest chain,
+// from the cache or the wrapped chain.
+func (c *Cache) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	chain, ok := c.chain.(bc.HeightIndexedBlockHeaderChain)
+	if !ok {
+		return 0, bc.ErrNoHeightIndex
+	}
+
+	c.mu.Lock()
+	if e, ok := c.hashes[blockHash]; ok && e.Value.(*cacheEntry).hasHeight {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).height, nil
+	}
+	if err := c.missed(blockHash); err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	v, err := c.flight.do(ctx, "height:"+blockHash, func(ctx context.Context) (interface{}, error) {
+		return chain.BlockHeight(ctx, blockHash)
+	})
+	if err != nil {
+		c.miss(gen, blockHash, err)
+		return 0, err
+	}
+	height := v.(uint64)
+	c.add(gen, &cacheEntry{hash: blockHash, height: height, hasHeight: true})
+	return height, nil
+}
+
+// BlockHeaderByHeight returns the header at the height provided on the longest chain, from the
+// cache or the wrapped chain.
+func (c *Cache) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	chain, ok := c.chain.(bc.HeightIndexedBlockHeaderChain)
+	if !ok {
+		return nil, bc.ErrNoHeightIndex
+	}
+
+	c.mu.Lock()
+	if e, ok := c.heights[height]; ok && e.Value.(*cacheEntry).header != nil {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).header, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	v, err := c.flight.do(ctx, fmt.Sprintf("byheight:%d", height), func(ctx context.Context) (interface{}, error) {
+		return chain.BlockHeaderByHeight(ctx, height)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bh := v.(*bc.BlockHeader)
+	c.add(gen, &cacheEntry{hash: bh.Hash().String(), header: bh, height: height, hasHeight: true})
+	return bh, nil
+}
+
+// ChainTip returns the tip of the wrapped chain, it is not cached.
+func (c *Cache) ChainTip(ctx context.Context) (uint64, string, error) {
+	chain, ok := c.chain.(bc.HeightIndexedBlockHeaderChain)
+	if !ok {
+		return 0, "", bc.ErrNoHeightIndex
+	}
+	return chain.ChainTip(ctx)
+}
+
+// Reorg drops the headers which may no longer be on the longest chain after a reorg which
+// forked from the header at height, those above it and those whose height is not known.
+// Every negative entry is dropped, as a header which was not on the longest chain may now be.
+func (c *Cache) Reorg(height uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.misses = map[string]cacheMiss{}
+	for e := c.lru.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*cacheEntry); !entry.hasHeight || entry.height > height {
+			c.remove(e)
+		}
+		e = next
+	}
+}
+
+// Invalidate drops the headers with the hashes provided from the cache.
+func (c *Cache) Invalidate(blockHashes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for _, hash := range blockHashes {
+		delete(c.misses, hash)
+		if e, ok := c.hashes[hash]; ok {
+			c.remove(e)
+		}
+	}
+}
+
+// Purge drops every header from the cache.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.reset()
+}
+
+// Len returns the number of headers held by the cache.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) reset() {
+	c.lru = list.New()
+	c.hashes = map[string]*list.Element{}
+	c.heights = map[uint64]*list.Element{}
+	c.misses = map[string]cacheMiss{}
+}
+
+// missed returns the error remembered for a hash which was not found, if it has not expired.
+// The lock must be held.
+func (c *Cache) missed(blockHash string) error {
+	m, ok := c.misses[blockHash]
+	if !ok {
+		return nil
+	}
+	if !c.opts.now().Before(m.expires) {
+		delete(c.misses, blockHash)
+		return nil
+	}
+	return m.err
+}
+
+// miss remembers a hash which was not found, or not on the longest chain.
+func (c *Cache) miss(gen uint64, blockHash string, err error) {
+	if c.opts.negativeTTL <= 0 || !(errors.Is(err, bc.ErrHeaderNotFound) || errors.Is(err, bc.ErrNotOnLongestChain)) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	c.misses[blockHash] = cacheMiss{err: err, expires: c.opts.now().Add(c.opts.negativeTTL)}
+}
+
+// add adds what is known of a header to the cache, evicting the least recently used headers
+// when it is full.
+func (c *Cache) add(gen uint64, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	delete(c.misses, entry.hash)
+
+	e, ok := c.hashes[entry.hash]
+	if !ok {
+		e = c.lru.PushFront(entry)
+		c.hashes[entry.hash] = e
+	} else {
+		c.lru.MoveToFront(e)
+		existing := e.Value.(*cacheEntry)
+		if entry.header != nil {
+			existing.header = entry.header
+		}
+		if entry.hasHeight {
+			existing.height, existing.hasHeight = entry.height, true
+		}
+		entry = existing
+	}
+	if entry.hasHeight {
+		if other, ok := c.heights[entry.height]; ok && other != e {
+			c.remove(other)
+		}
+		c.heights[entry.height] = e
+	}
+
+	for c.lru.Len() > c.opts.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes an entry from the cache. The lock must be held.
+func (c *Cache) remove(e *list.Element) {
+	entry := e.Value.(*cacheEntry)
+	c.lru.Remove(e)
+	delete(c.hashes, entry.hash)
+	if entry.hasHeight && c.heights[entry.height] == e {
+		delete(c.heights, entry.height)
+	}
+}
+
+// flightGroup makes concurrent calls with the same key once, sharing the result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	// waiters is the number of callers waiting for the call, it is cancelled once none are.
+	waiters int
+	cancel  context.CancelFunc
+	val     interface{}
+	err     error
+}
+
+// do calls fn, unless a call with the same key is in progress, in which case it waits for
+// that call and returns its result. The call is made with the values of ctx, but is only
+// cancelled once every caller waiting for it has gone, so one caller giving up does not fail
+// the others. A caller whose ctx is done before the call finishes returns the error of ctx.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flight{}
+	}
+	f, ok := g.calls[key]
+	if !ok {
+		fctx, cancel := context.WithCancel(detachedContext{ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = f
+		go func() {
+			f.val, f.err = fn(fctx)
+			g.mu.Lock()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+			g.mu.Unlock()
+			cancel()
+			close(f.done)
+		}()
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// a later caller makes a new call rather than waiting for this cancelled one.
+			f.cancel()
+			if g.calls[key] == f {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// detachedContext has the values of the context it wraps, but is not cancelled with it.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
@@ -0,0 +1,386 @@
+package headers_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headers"
+	"github.com/libsv/go-bc/spv"
+)
+
+var _ bc.HeightIndexedBlockHeaderChain = (*headers.Cache)(nil)
+
+// countingChain counts the lookups made of a store, optionally holding each until release
+// is closed or its context is done.
+type countingChain struct {
+	headers.Store
+	calls   int64
+	release chan struct{}
+}
+
+func (c *countingChain) wait(ctx context.Context) error {
+	atomic.AddInt64(&c.calls, 1)
+	if c.release == nil {
+		return nil
+	}
+	select {
+	case <-c.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *countingChain) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.Store.BlockHeader(ctx, blockHash)
+}
+
+func (c *countingChain) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	if err := c.wait(ctx); err != nil {
+		return 0, err
+	}
+	return c.Store.BlockHeight(ctx, blockHash)
+}
+
+func (c *countingChain) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.Store.BlockHeaderByHeight(ctx, height)
+}
+
+func (c *countingChain) count() int64 {
+	return atomic.LoadInt64(&c.calls)
+}
+
+// headersOnly hides the height index of a chain.
+type headersOnly struct {
+	chain bc.BlockHeaderChain
+}
+
+func (h headersOnly) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	return h.chain.BlockHeader(ctx, blockHash)
+}
+
+func newCountingChain(t *testing.T, hh []*bc.BlockHeader) *countingChain {
+	s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+	require.NoError(t, s.Append(context.Background(), hh...))
+	return &countingChain{Store: s}
+}
+
+func TestCache_Lookups(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 20)
+	chain := newCountingChain(t, hh)
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		bh, err := c.BlockHeader(ctx, hashOf(hh[5]))
+		require.NoError(t, err)
+		require.Equal(t, hh[5].String(), bh.String())
+	}
+	require.Equal(t, int64(1), chain.count())
+
+	// the height is not known from a lookup by hash.
+	height, err := c.BlockHeight(ctx, hashOf(hh[5]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), height)
+	_, err = c.BlockHeight(ctx, hashOf(hh[5]))
+	require.NoError(t, err)
+	require.Equal(t, int64(2), chain.count())
+
+	// a lookup by height caches the header by hash and height.
+	bh, err := c.BlockHeaderByHeight(ctx, 9)
+	require.NoError(t, err)
+	require.Equal(t, hh[9].String(), bh.String())
+	_, err = c.BlockHeaderByHeight(ctx, 9)
+	require.NoError(t, err)
+	_, err = c.BlockHeader(ctx, hashOf(hh[9]))
+	require.NoError(t, err)
+	height, err = c.BlockHeight(ctx, hashOf(hh[9]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), height)
+	require.Equal(t, int64(3), chain.count())
+	require.Equal(t, 2, c.Len())
+
+	confs, err := bc.Confirmations(ctx, c, hashOf(hh[9]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(11), confs)
+}
+
+func TestCache_NoHeightIndex(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	c, err := headers.NewCache(headersOnly{chain: newCountingChain(t, hh)})
+	require.NoError(t, err)
+
+	_, err = c.BlockHeader(ctx, hashOf(hh[2]))
+	require.NoError(t, err)
+	_, err = c.BlockHeight(ctx, hashOf(hh[2]))
+	require.ErrorIs(t, err, bc.ErrNoHeightIndex)
+	_, err = c.BlockHeaderByHeight(ctx, 2)
+	require.ErrorIs(t, err, bc.ErrNoHeightIndex)
+	_, _, err = c.ChainTip(ctx)
+	require.ErrorIs(t, err, bc.ErrNoHeightIndex)
+
+	_, err = headers.NewCache(nil)
+	require.Error(t, err)
+	_, err = headers.NewCache(headersOnly{}, headers.WithCacheSize(0))
+	require.Error(t, err)
+}
+
+func TestCache_ConcurrentLookups(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	chain := newCountingChain(t, hh)
+	chain.release = make(chan struct{})
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.BlockHeader(ctx, hashOf(hh[3]))
+			errs <- err
+		}()
+	}
+	// wait for the first lookup to reach the chain before letting it through.
+	require.Eventually(t, func() bool { return chain.count() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(chain.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, int64(1), chain.count())
+}
+
+func TestCache_CancelledLookup(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	chain := newCountingChain(t, hh)
+	chain.release = make(chan struct{})
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+
+	// the first caller gives up while the lookup it started is in progress.
+	cctx, cancel := context.WithCancel(ctx)
+	first := make(chan error, 1)
+	go func() {
+		_, err := c.BlockHeader(cctx, hashOf(hh[3]))
+		first <- err
+	}()
+	require.Eventually(t, func() bool { return chain.count() == 1 }, time.Second, time.Millisecond)
+
+	type result struct {
+		bh  *bc.BlockHeader
+		err error
+	}
+	second := make(chan result, 1)
+	go func() {
+		bh, err := c.BlockHeader(ctx, hashOf(hh[3]))
+		second <- result{bh: bh, err: err}
+	}()
+	// wait for the second caller to join the lookup.
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-first, context.Canceled)
+
+	// the lookup carries on for the second caller.
+	close(chain.release)
+	r := <-second
+	require.NoError(t, r.err)
+	require.Equal(t, hh[3].String(), r.bh.String())
+	require.Equal(t, int64(1), chain.count())
+}
+
+func TestCache_NegativeEntries(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	chain := newCountingChain(t, hh[:3])
+	now := time.Unix(1600000000, 0)
+	c, err := headers.NewCache(chain,
+		headers.WithNegativeTTL(time.Minute),
+		headers.WithCacheClock(func() time.Time { return now }))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = c.BlockHeader(ctx, hashOf(hh[4]))
+		require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+	}
+	require.Equal(t, int64(1), chain.count())
+
+	// a header which appears is not found until the negative entry expires.
+	require.NoError(t, chain.Append(ctx, hh[3:]...))
+	_, err = c.BlockHeader(ctx, hashOf(hh[4]))
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+	now = now.Add(time.Minute)
+	bh, err := c.BlockHeader(ctx, hashOf(hh[4]))
+	require.NoError(t, err)
+	require.Equal(t, hh[4].String(), bh.String())
+	require.Equal(t, int64(2), chain.count())
+
+	// failures other than a missing header are not cached.
+	failing := &failingChain{err: context.Canceled}
+	c, err = headers.NewCache(failing)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = c.BlockHeader(ctx, hashOf(hh[1]))
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	require.Equal(t, 2, failing.calls)
+
+	// nor is a lookup whose context is done before it is made.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.BlockHeader(cctx, hashOf(hh[1]))
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 2, failing.calls)
+
+	// negative caching can be disabled.
+	chain = newCountingChain(t, hh[:3])
+	c, err = headers.NewCache(chain, headers.WithNegativeTTL(0))
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = c.BlockHeader(ctx, hashOf(hh[4]))
+		require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+	}
+	require.Equal(t, int64(2), chain.count())
+}
+
+type failingChain struct {
+	err   error
+	calls int
+}
+
+func (f *failingChain) BlockHeader(context.Context, string) (*bc.BlockHeader, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func TestCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	chain := newCountingChain(t, hh)
+	c, err := headers.NewCache(chain, headers.WithCacheSize(2))
+	require.NoError(t, err)
+
+	lookup := func(i int) {
+		_, err := c.BlockHeader(ctx, hashOf(hh[i]))
+		require.NoError(t, err)
+	}
+	lookup(0)
+	lookup(1)
+	lookup(0)
+	lookup(2) // evicts 1, the least recently used.
+	require.Equal(t, 2, c.Len())
+	require.Equal(t, int64(3), chain.count())
+
+	lookup(0)
+	lookup(2)
+	require.Equal(t, int64(3), chain.count())
+	lookup(1)
+	require.Equal(t, int64(4), chain.count())
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 10)
+	chain := newCountingChain(t, hh)
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		_, err = c.BlockHeaderByHeight(ctx, uint64(i))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 10, c.Len())
+
+	c.Invalidate(hashOf(hh[0]), hashOf(hh[1]))
+	require.Equal(t, 8, c.Len())
+
+	c.Reorg(6)
+	require.Equal(t, 5, c.Len())
+	_, err = c.BlockHeaderByHeight(ctx, 6)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), chain.count())
+	_, err = c.BlockHeaderByHeight(ctx, 7)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), chain.count())
+
+	c.Purge()
+	require.Equal(t, 0, c.Len())
+}
+
+func TestCache_SyncerReorg(t *testing.T) {
+	ctx := context.Background()
+	genesis := easyGenesis()
+	stored := mineChain(genesis, 10, 600)
+	fork := mineChain(stored[4], 7, 601)
+
+	chain := newCountingChain(t, append([]*bc.BlockHeader{genesis}, stored...))
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+	syncer, err := headers.NewSyncer(chain.Store, headers.WithReorgHandler(c.Reorg))
+	require.NoError(t, err)
+
+	for i := 0; i <= 10; i++ {
+		_, err = c.BlockHeaderByHeight(ctx, uint64(i))
+		require.NoError(t, err)
+	}
+	_, err = c.BlockHeader(ctx, hashOf(fork[0]))
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+	n, err := syncer.ProcessHeaders(ctx, fork...)
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+
+	// headers replaced by the fork are gone, and the fork is found at once.
+	bh, err := c.BlockHeaderByHeight(ctx, 6)
+	require.NoError(t, err)
+	require.Equal(t, fork[0].String(), bh.String())
+	_, err = c.BlockHeader(ctx, hashOf(stored[5]))
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+	_, err = c.BlockHeader(ctx, hashOf(fork[1]))
+	require.NoError(t, err)
+	height, err := c.BlockHeight(ctx, hashOf(stored[4]))
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), height)
+}
+
+func TestCache_MerkleProofVerifier(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	chain := newCountingChain(t, hh)
+	c, err := headers.NewCache(chain)
+	require.NoError(t, err)
+
+	// block 1 holds only its coinbase, so its txid is the merkle root.
+	v, err := spv.NewMerkleProofVerifier(c)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		valid, _, err := v.VerifyMerkleProofJSON(ctx, &bc.MerkleProof{
+			TxOrID: hh[1].HashMerkleRootStr(),
+			Target: hashOf(hh[1]),
+		})
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+	require.Equal(t, int64(1), chain.count())
+}
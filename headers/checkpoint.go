@@ -0,0 +1,41 @@
+package headers
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// ErrInvalidCheckpoint is returned when a checkpoint read from config is malformed.
+var ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+
+// ReadCheckpoints reads checkpoints from a JSON array of height and hash pairs, such as
+// [{"height": 11111, "hash": "0000000069e2..."}], so they can be loaded from config and
+// given to WithCheckpoints or WithBundleCheckpoints. They are returned in height order.
+func ReadCheckpoints(r io.Reader) ([]bc.Checkpoint, error) {
+	var checkpoints []bc.Checkpoint
+	if err := json.NewDecoder(r).Decode(&checkpoints); err != nil {
+		return nil, errors.Wrap(ErrInvalidCheckpoint, err.Error())
+	}
+
+	heights := make(map[uint64]struct{}, len(checkpoints))
+	for _, cp := range checkpoints {
+		if _, err := chainhash.NewHashFromStr(cp.Hash); err != nil || len(cp.Hash) != chainhash.MaxHashStringSize {
+			return nil, errors.Wrapf(ErrInvalidCheckpoint, "height %d has an invalid hash %q", cp.Height, cp.Hash)
+		}
+		if _, ok := heights[cp.Height]; ok {
+			return nil, errors.Wrapf(ErrInvalidCheckpoint, "height %d is duplicated", cp.Height)
+		}
+		heights[cp.Height] = struct{}{}
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Height < checkpoints[j].Height
+	})
+
+	return checkpoints, nil
+}
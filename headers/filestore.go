@@ -0,0 +1,441 @@
+package headers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+/*
+File layout
+
+Field 						Purpose 															Size (Bytes)
+----------------------------------------------------------------------------------------------------
+magic 						"BCHD" 																4
+version 					file format version, little endian 									4
+base height 				height of the first stored header, little endian 					8
+headers 					serialised 80 byte block headers, one after another 				80 * n
+*/
+
+const (
+	fileMagic           = "BCHD"
+	fileVersion  uint32 = 1
+	preambleSize        = 16
+	// medianTimeBlocks is the number of headers whose median is the median time past.
+	medianTimeBlocks = 11
+)
+
+// FileStore is an append-only, file backed Store.
+//
+// Headers are written one after another as 80 byte records so the height of a header
+// is given by its position in the file. The hash and height indexes are held in memory
+// and are rebuilt by bulk loading the file when it is opened, alongside the median time
+// past at each height which is used to find headers by time.
+//
+// A crash part way through an append can leave a torn record at the end of the file,
+// this, and any trailing records which do not link to the header before them, are
+// discarded when the file is next opened.
+type FileStore struct {
+	mu     sync.RWMutex
+	path   string
+	f      *os.File
+	base   uint64
+	raw    []byte
+	hashes []chainhash.Hash
+	times  []uint32
+	index  map[chainhash.Hash]uint64
+}
+
+var (
+	_ Store                          = (*FileStore)(nil)
+	_ bc.TimeIndexedBlockHeaderChain = (*FileStore)(nil)
+)
+
+// OpenFileStore opens the header store at the path provided, creating it if it does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err = writePreamble(path, 0); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to stat header store %s", path)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0o600) //nolint:gosec // path is supplied by the caller
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open header store %s", path)
+	}
+
+	s := &FileStore{path: path, f: f}
+	if err = s.load(); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Close closes the underlying file, the store cannot be used after it has been closed.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+// BlockHeader returns the header with the hash provided, or bc.ErrHeaderNotFound if
+// it is not part of the stored chain.
+func (s *FileStore) BlockHeader(_ context.Context, blockHash string) (*bc.BlockHeader, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid block hash %s", blockHash)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[*hash]
+	if !ok {
+		return nil, bc.ErrHeaderNotFound
+	}
+
+	return s.header(i)
+}
+
+// BlockHeaderByHeight returns the stored header at the height provided, or bc.ErrHeaderNotFound
+// if the height is not held by the store.
+func (s *FileStore) BlockHeaderByHeight(_ context.Context, height uint64) (*bc.BlockHeader, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if height < s.base || height-s.base >= uint64(len(s.hashes)) {
+		return nil, bc.ErrHeaderNotFound
+	}
+
+	return s.header(height - s.base)
+}
+
+// BlockHeight returns the height of the header with the hash provided, or bc.ErrHeaderNotFound
+// if it is not part of the stored chain.
+func (s *FileStore) BlockHeight(_ context.Context, blockHash string) (uint64, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid block hash %s", blockHash)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[*hash]
+	if !ok {
+		return 0, bc.ErrHeaderNotFound
+	}
+
+	return s.base + i, nil
+}
+
+// BlockHeaderAtTime returns the first stored header whose median time past is at or after t,
+// along with its height. bc.ErrHeaderNotFound is returned if no stored header has reached t.
+func (s *FileStore) BlockHeaderAtTime(_ context.Context, t time.Time) (*bc.BlockHeader, uint64, error) {
+	target := t.Unix()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := sort.Search(len(s.times), func(i int) bool {
+		return int64(s.times[i]) >= target
+	})
+	if i == len(s.times) {
+		return nil, 0, bc.ErrHeaderNotFound
+	}
+
+	bh, err := s.header(uint64(i))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return bh, s.base + uint64(i), nil
+}
+
+// ChainTip returns the height and hash of the last stored header.
+func (s *FileStore) ChainTip(_ context.Context) (uint64, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.hashes) == 0 {
+		return 0, "", ErrEmptyStore
+	}
+
+	return s.base + uint64(len(s.hashes)) - 1, s.hashes[len(s.hashes)-1].String(), nil
+}
+
+// Append adds the headers to the tip of the store. Each header must reference the one
+// before it, and the first must reference the current tip, otherwise ErrHeaderDoesNotConnect
+// is returned and nothing is written.
+//
+// When the store is empty the first header is accepted as the base of the chain.
+func (s *FileStore) Append(_ context.Context, headers ...*bc.BlockHeader) error {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	buf := make([]byte, 0, len(headers)*HeaderSize)
+	for _, h := range headers {
+		raw := h.Bytes()
+		if len(raw) != HeaderSize {
+			return errors.Errorf("block header should be %d bytes long, got %d", HeaderSize, len(raw))
+		}
+		buf = append(buf, raw...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrStoreClosed
+	}
+
+	return s.append(buf)
+}
+
+// Truncate removes every header above the height provided, leaving the header at
+// height as the new tip. It is used to roll the store back to a fork point on reorg.
+func (s *FileStore) Truncate(_ context.Context, height uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrStoreClosed
+	}
+	if height < s.base {
+		return ErrHeightOutOfRange
+	}
+
+	return s.truncate(height - s.base + 1)
+}
+
+// header returns the header stored at index i, the caller must hold the lock.
+func (s *FileStore) header(i uint64) (*bc.BlockHeader, error) {
+	return bc.NewBlockHeaderFromBytes(s.raw[i*HeaderSize : (i+1)*HeaderSize])
+}
+
+// append writes a buffer of serialised headers to the tip of the store, the caller
+// must hold the write lock.
+func (s *FileStore) append(buf []byte) error {
+	n := len(buf) / HeaderSize
+	hashes := hashHeaders(buf)
+	for i := 0; i < n; i++ {
+		prev := buf[i*HeaderSize+4 : i*HeaderSize+36]
+		switch {
+		case i > 0:
+			if !bytes.Equal(prev, hashes[i-1][:]) {
+				return errors.Wrapf(ErrHeaderDoesNotConnect, "header %s", hashes[i])
+			}
+		case len(s.hashes) > 0:
+			if !bytes.Equal(prev, s.hashes[len(s.hashes)-1][:]) {
+				return errors.Wrapf(ErrHeaderDoesNotConnect, "header %s", hashes[i])
+			}
+		}
+	}
+
+	size := len(s.hashes)
+	if _, err := s.f.WriteAt(buf, int64(preambleSize+size*HeaderSize)); err != nil {
+		// best effort to remove a partial write, it would otherwise be discarded on the next open.
+		_ = s.f.Truncate(int64(preambleSize + size*HeaderSize))
+		return errors.Wrap(err, "failed to write headers")
+	}
+	if err := s.f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync headers")
+	}
+
+	s.raw = append(s.raw, buf...)
+	for i := range hashes {
+		s.index[hashes[i]] = uint64(size + i)
+	}
+	s.hashes = append(s.hashes, hashes...)
+	s.times = appendTimes(s.times, s.raw)
+
+	return nil
+}
+
+// truncate shrinks the store to n headers, the caller must hold the write lock.
+func (s *FileStore) truncate(n uint64) error {
+	if n >= uint64(len(s.hashes)) {
+		return nil
+	}
+	if err := s.f.Truncate(int64(preambleSize + n*HeaderSize)); err != nil {
+		return errors.Wrap(err, "failed to truncate header store")
+	}
+	if err := s.f.Sync(); err != nil {
+		return errors.Wrap(err, "failed to sync header store")
+	}
+
+	for _, h := range s.hashes[n:] {
+		delete(s.index, h)
+	}
+	s.hashes = s.hashes[:n]
+	s.times = s.times[:n]
+	s.raw = s.raw[:n*HeaderSize]
+
+	return nil
+}
+
+// reset empties the store and sets the height of the next header to be appended,
+// the caller must hold the write lock.
+func (s *FileStore) reset(base uint64) error {
+	if err := s.f.Close(); err != nil {
+		return errors.Wrap(err, "failed to close header store")
+	}
+	s.f = nil
+	if err := writePreamble(s.path, base); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0o600)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open header store %s", s.path)
+	}
+
+	s.f = f
+	s.base = base
+	s.raw = nil
+	s.hashes = nil
+	s.times = nil
+	s.index = make(map[chainhash.Hash]uint64)
+
+	return nil
+}
+
+// load bulk reads the file, discarding any torn or unlinked records at the end of it,
+// and builds the in memory indexes.
+func (s *FileStore) load() error {
+	b, err := ioutil.ReadAll(s.f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read header store %s", s.path)
+	}
+	if len(b) < preambleSize || string(b[:4]) != fileMagic {
+		return errors.Errorf("%s is not a header store", s.path)
+	}
+	if v := binary.LittleEndian.Uint32(b[4:8]); v != fileVersion {
+		return errors.Errorf("unsupported header store version %d", v)
+	}
+	s.base = binary.LittleEndian.Uint64(b[8:16])
+
+	raw := b[preambleSize:]
+	n := len(raw) / HeaderSize
+	hashes := hashHeaders(raw[:n*HeaderSize])
+	for i := 1; i < n; i++ {
+		if !bytes.Equal(raw[i*HeaderSize+4:i*HeaderSize+36], hashes[i-1][:]) {
+			n = i
+			break
+		}
+	}
+
+	if len(raw) != n*HeaderSize {
+		if err = s.f.Truncate(int64(preambleSize + n*HeaderSize)); err != nil {
+			return errors.Wrapf(err, "failed to recover header store %s", s.path)
+		}
+		if err = s.f.Sync(); err != nil {
+			return errors.Wrapf(err, "failed to recover header store %s", s.path)
+		}
+	}
+
+	s.raw = raw[:n*HeaderSize]
+	s.hashes = hashes[:n]
+	s.times = appendTimes(make([]uint32, 0, n), s.raw)
+	s.index = make(map[chainhash.Hash]uint64, n)
+	for i, h := range s.hashes {
+		s.index[h] = uint64(i)
+	}
+
+	return nil
+}
+
+// appendTimes appends the median time past of each of the serialised headers in raw after
+// those times already holds, the headers before them in raw making up their windows. Each
+// is kept at least the one before it, as it is on a valid chain, so times can be binary
+// searched.
+func appendTimes(times []uint32, raw []byte) []uint32 {
+	window := make([]uint32, 0, medianTimeBlocks)
+	for i := len(times); i < len(raw)/HeaderSize; i++ {
+		window = window[:0]
+		for j := i; j >= 0 && j > i-medianTimeBlocks; j-- {
+			window = append(window, binary.LittleEndian.Uint32(raw[j*HeaderSize+68:]))
+		}
+		sort.Slice(window, func(a, b int) bool { return window[a] < window[b] })
+		mtp := window[len(window)/2]
+		if len(times) > 0 && times[len(times)-1] > mtp {
+			mtp = times[len(times)-1]
+		}
+		times = append(times, mtp)
+	}
+	return times
+}
+
+// writePreamble atomically creates an empty store file at path.
+func writePreamble(path string, base uint64) error {
+	b := make([]byte, preambleSize)
+	copy(b, fileMagic)
+	binary.LittleEndian.PutUint32(b[4:8], fileVersion)
+	binary.LittleEndian.PutUint64(b[8:16], base)
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create header store")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "failed to create header store")
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "failed to create header store")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to create header store")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), path), "failed to create header store")
+}
+
+// hashHeaders hashes each of the serialised headers in b, splitting the work across
+// the available CPUs as hashing dominates the time taken to load a large store.
+func hashHeaders(b []byte) []chainhash.Hash {
+	n := len(b) / HeaderSize
+	hashes := make([]chainhash.Hash, n)
+
+	workers := runtime.NumCPU()
+	per := (n + workers - 1) / workers
+	if per < 1024 {
+		per = 1024
+	}
+
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += per {
+		end := start + per
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				hashes[i] = blockHash(b[i*HeaderSize : (i+1)*HeaderSize])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return hashes
+}
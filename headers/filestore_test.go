@@ -0,0 +1,401 @@
+package headers_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libsv/go-bk/crypto"
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headers"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// mainnetHeaders loads the first n mainnet headers from the test data.
+func mainnetHeaders(t *testing.T, n int) []*bc.BlockHeader {
+	t.Helper()
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+	require.LessOrEqual(t, n*headers.HeaderSize, len(b))
+
+	hh := make([]*bc.BlockHeader, n)
+	for i := range hh {
+		hh[i], err = bc.NewBlockHeaderFromBytes(b[i*headers.HeaderSize : (i+1)*headers.HeaderSize])
+		require.NoError(t, err)
+	}
+	return hh
+}
+
+// fakeChain builds n headers which link on from parent, they carry no proof of work.
+func fakeChain(parent *bc.BlockHeader, n int) []*bc.BlockHeader {
+	hh := make([]*bc.BlockHeader, n)
+	for i := range hh {
+		hh[i] = &bc.BlockHeader{
+			Version:        parent.Version,
+			Time:           parent.Time + 600,
+			Nonce:          uint32(i),
+			HashPrevBlock:  bt.ReverseBytes(crypto.Sha256d(parent.Bytes())),
+			HashMerkleRoot: parent.HashMerkleRoot,
+			Bits:           parent.Bits,
+		}
+		parent = hh[i]
+	}
+	return hh
+}
+
+func openStore(t *testing.T, path string) *headers.FileStore {
+	t.Helper()
+	s, err := headers.OpenFileStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+	return s
+}
+
+func TestFileStore_AppendAndLookup(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+	hh := mainnetHeaders(t, 2017)
+
+	s := openStore(t, path)
+	_, _, err := s.ChainTip(ctx)
+	require.ErrorIs(t, err, headers.ErrEmptyStore)
+
+	require.NoError(t, s.Append(ctx, hh[:1000]...))
+	require.NoError(t, s.Append(ctx, hh[1000:]...))
+
+	height, hash, err := s.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2016), height)
+	require.Equal(t, "00000000a141216a896c54f211301c436e557a8d55900637bbdce14c6c7bddef", hash)
+
+	bh, err := s.BlockHeader(ctx, "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f")
+	require.NoError(t, err)
+	require.Equal(t, hh[0], bh)
+
+	bh, err = s.BlockHeaderByHeight(ctx, 1500)
+	require.NoError(t, err)
+	require.Equal(t, hh[1500], bh)
+
+	_, err = s.BlockHeaderByHeight(ctx, 2017)
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+	height, err = s.BlockHeight(ctx, "00000000a141216a896c54f211301c436e557a8d55900637bbdce14c6c7bddef")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2016), height)
+
+	_, err = s.BlockHeader(ctx, "00000000ca4b69045a03d7b20624def97a5366418648d5005e82fd3b345d20d0")
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+	// reopening the store should bulk load the same chain.
+	require.NoError(t, s.Close())
+	s = openStore(t, path)
+	height, hash, err = s.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2016), height)
+	require.Equal(t, "00000000a141216a896c54f211301c436e557a8d55900637bbdce14c6c7bddef", hash)
+
+	bh, err = s.BlockHeaderByHeight(ctx, 2016)
+	require.NoError(t, err)
+	require.Equal(t, hh[2016], bh)
+}
+
+func TestFileStore_AppendDoesNotConnect(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 10)
+
+	tests := map[string]struct {
+		existing []*bc.BlockHeader
+		append   []*bc.BlockHeader
+	}{
+		"header skipping the tip errors": {
+			existing: hh[:5],
+			append:   hh[6:],
+		},
+		"batch with a gap errors": {
+			existing: hh[:5],
+			append:   []*bc.BlockHeader{hh[5], hh[7]},
+		},
+		"re-appending the tip errors": {
+			existing: hh[:5],
+			append:   hh[4:5],
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+			require.NoError(t, s.Append(ctx, test.existing...))
+
+			err := s.Append(ctx, test.append...)
+			require.ErrorIs(t, err, headers.ErrHeaderDoesNotConnect)
+
+			// nothing from the failed batch should have been written.
+			height, _, err := s.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(len(test.existing)-1), height)
+		})
+	}
+}
+
+func TestFileStore_Recovery(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 20)
+
+	tests := map[string]struct {
+		trailing []byte
+	}{
+		"torn final record is discarded": {
+			trailing: hh[10].Bytes()[:37],
+		},
+		"zero filled final record is discarded": {
+			trailing: make([]byte, headers.HeaderSize),
+		},
+		"unlinked trailing records are discarded": {
+			trailing: append(hh[12].Bytes(), hh[13].Bytes()...),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "headers.dat")
+			s := openStore(t, path)
+			require.NoError(t, s.Append(ctx, hh[:10]...))
+			require.NoError(t, s.Close())
+
+			f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+			require.NoError(t, err)
+			_, err = f.Write(test.trailing)
+			require.NoError(t, err)
+			require.NoError(t, f.Close())
+
+			s = openStore(t, path)
+			height, _, err := s.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(9), height)
+
+			// the store should carry on appending after the recovered tip.
+			require.NoError(t, s.Append(ctx, hh[10:]...))
+			height, _, err = s.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(19), height)
+		})
+	}
+}
+
+func TestFileStore_Truncate(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 20)
+
+	s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+	require.NoError(t, s.Append(ctx, hh...))
+
+	require.NoError(t, s.Truncate(ctx, 14))
+	height, hash, err := s.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(14), height)
+	require.Equal(t, hh[15].HashPrevBlockStr(), hash)
+
+	_, err = s.BlockHeader(ctx, hh[16].HashPrevBlockStr())
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+	require.NoError(t, s.Append(ctx, hh[15:]...))
+	height, _, err = s.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(19), height)
+}
+
+func TestFileStore_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+
+	src := openStore(t, filepath.Join(t.TempDir(), "src.dat"))
+	require.NoError(t, src.Append(ctx, hh...))
+
+	snapshot := func(from, to uint64) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, src.Export(ctx, &buf, from, to))
+		return buf.Bytes()
+	}
+
+	t.Run("import into an empty store", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		require.NoError(t, dst.Import(ctx, bytes.NewReader(snapshot(0, 4032))))
+
+		height, hash, err := dst.ChainTip(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(4032), height)
+		require.Equal(t, "00000000ca4b69045a03d7b20624def97a5366418648d5005e82fd3b345d20d0", hash)
+	})
+
+	t.Run("import from a later height sets the base of an empty store", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dst.dat")
+		dst := openStore(t, path)
+		require.NoError(t, dst.Import(ctx, bytes.NewReader(snapshot(2016, 4032))))
+
+		_, err := dst.BlockHeaderByHeight(ctx, 2015)
+		require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+		require.NoError(t, dst.Close())
+		dst = openStore(t, path)
+		bh, err := dst.BlockHeaderByHeight(ctx, 2016)
+		require.NoError(t, err)
+		require.Equal(t, hh[2016], bh)
+
+		height, _, err := dst.ChainTip(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(4032), height)
+	})
+
+	t.Run("overlapping import extends the store", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		require.NoError(t, dst.Append(ctx, hh[:3000]...))
+		require.NoError(t, dst.Import(ctx, bytes.NewReader(snapshot(2016, 4032))))
+
+		height, _, err := dst.ChainTip(ctx)
+		require.NoError(t, err)
+		require.Equal(t, uint64(4032), height)
+	})
+
+	t.Run("import leaving a gap errors", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		require.NoError(t, dst.Append(ctx, hh[:100]...))
+		err := dst.Import(ctx, bytes.NewReader(snapshot(2016, 4032)))
+		require.ErrorIs(t, err, headers.ErrHeightOutOfRange)
+	})
+
+	t.Run("conflicting import errors", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		require.NoError(t, dst.Import(ctx, bytes.NewReader(snapshot(0, 100))))
+
+		// a snapshot of a chain which forks from the stored chain at height 50.
+		other := openStore(t, filepath.Join(t.TempDir(), "other.dat"))
+		require.NoError(t, other.Append(ctx, hh[:51]...))
+		require.NoError(t, other.Append(ctx, fakeChain(hh[50], 50)...))
+		var buf bytes.Buffer
+		require.NoError(t, other.Export(ctx, &buf, 0, 100))
+
+		err := dst.Import(ctx, bytes.NewReader(buf.Bytes()))
+		require.ErrorIs(t, err, headers.ErrSnapshotConflict)
+	})
+
+	t.Run("corrupt snapshot errors", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		b := snapshot(0, 100)
+		b[100] ^= 0xff
+
+		err := dst.Import(ctx, bytes.NewReader(b))
+		require.ErrorIs(t, err, headers.ErrInvalidSnapshot)
+		require.Contains(t, errors.Cause(err).Error(), "invalid header snapshot")
+	})
+
+	t.Run("truncated snapshot errors", func(t *testing.T) {
+		dst := openStore(t, filepath.Join(t.TempDir(), "dst.dat"))
+		b := snapshot(0, 100)
+
+		err := dst.Import(ctx, bytes.NewReader(b[:len(b)-100]))
+		require.ErrorIs(t, err, headers.ErrInvalidSnapshot)
+	})
+}
+
+// heightIndexOnly hides the time index of a store, so bc.BlockHeaderAtTime searches it by height.
+type heightIndexOnly struct {
+	bc.HeightIndexedBlockHeaderChain
+}
+
+func TestFileStore_BlockHeaderAtTime(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "headers.dat")
+	hh := mainnetHeaders(t, 100)
+
+	// block 50 carries a timestamp ahead of the blocks after it.
+	chain := hh[:50:50]
+	for i := 50; i < len(hh); i++ {
+		bh := fakeChain(chain[i-1], 1)[0]
+		bh.Time = hh[i].Time
+		if i == 50 {
+			bh.Time = hh[60].Time
+		}
+		chain = append(chain, bh)
+	}
+
+	s := openStore(t, path)
+	require.NoError(t, s.Append(ctx, chain[:70]...))
+	require.NoError(t, s.Append(ctx, chain[70:]...))
+	mtp := func(height uint64) time.Time {
+		mtp, err := bc.MedianTimePastAtHeight(ctx, s, height)
+		require.NoError(t, err)
+		return mtp
+	}
+
+	tests := map[string]struct {
+		time      time.Time
+		expHeight uint64
+		expErr    error
+	}{
+		"median time past of a block": {
+			time:      mtp(20),
+			expHeight: 20,
+		},
+		"time between median times returns the later block": {
+			time:      mtp(20).Add(time.Second),
+			expHeight: 21,
+		},
+		"block ahead of its successors is not found before its median time": {
+			time:      time.Unix(int64(chain[50].Time), 0),
+			expHeight: 65,
+		},
+		"time after the tip errors": {
+			time:   mtp(99).Add(time.Second),
+			expErr: bc.ErrHeaderNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// the index should survive the store being reopened, and agree with searching
+			// the store by height.
+			for _, s := range []bc.BlockHeaderChain{s, openStore(t, path), heightIndexOnly{s}} {
+				bh, height, err := bc.BlockHeaderAtTime(ctx, s, test.time)
+				if test.expErr != nil {
+					require.ErrorIs(t, err, test.expErr)
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, test.expHeight, height)
+				require.Equal(t, chain[test.expHeight], bh)
+			}
+		})
+	}
+
+	// the index agrees with searching the store by height either side of every block time.
+	for _, bh := range chain {
+		for _, at := range []time.Time{time.Unix(int64(bh.Time)-1, 0), time.Unix(int64(bh.Time), 0), time.Unix(int64(bh.Time)+1, 0)} {
+			_, expHeight, expErr := bc.BlockHeaderAtTime(ctx, heightIndexOnly{s}, at)
+			_, height, err := bc.BlockHeaderAtTime(ctx, s, at)
+			require.Equal(t, expErr, err, "time %d", at.Unix())
+			require.Equal(t, expHeight, height, "time %d", at.Unix())
+		}
+	}
+
+	// truncating should drop the times of the removed headers.
+	require.NoError(t, s.Truncate(ctx, 40))
+	_, _, err := bc.BlockHeaderAtTime(ctx, s, mtp(40).Add(time.Second))
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+}
+
+func TestFileStore_PaymentVerifier(t *testing.T) {
+	s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+
+	_, err := spv.NewPaymentVerifier(s)
+	require.NoError(t, err)
+}
@@ -0,0 +1,77 @@
+package headers
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// MaxHeadersPerMessage is the most headers a peer will send in a single headers message.
+const MaxHeadersPerMessage = 2000
+
+// ErrInvalidHeadersMessage is returned when a batch of headers cannot be decoded.
+var ErrInvalidHeadersMessage = errors.New("invalid headers message")
+
+// DecodeHeadersMessage decodes the payload of a p2p headers message, a varint count followed
+// by each 80 byte header and its transaction count, which is always zero.
+func DecodeHeadersMessage(b []byte) ([]*bc.BlockHeader, error) {
+	r := bytes.NewReader(b)
+
+	var count bt.VarInt
+	if _, err := count.ReadFrom(r); err != nil {
+		return nil, errors.Wrap(ErrInvalidHeadersMessage, "missing header count")
+	}
+	if count > MaxHeadersPerMessage {
+		return nil, errors.Wrapf(ErrInvalidHeadersMessage, "%d headers is more than the maximum of %d", count, MaxHeadersPerMessage)
+	}
+
+	hh := make([]*bc.BlockHeader, 0, count)
+	raw := make([]byte, HeaderSize)
+	for i := uint64(0); i < uint64(count); i++ {
+		if _, err := io.ReadFull(r, raw); err != nil {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d truncated", i)
+		}
+		var txs bt.VarInt
+		if _, err := txs.ReadFrom(r); err != nil {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d missing transaction count", i)
+		}
+		if txs != 0 {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d has a transaction count of %d", i, txs)
+		}
+		bh, err := bc.NewBlockHeaderFromBytes(raw)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d: %s", i, err)
+		}
+		hh = append(hh, bh)
+	}
+	if r.Len() != 0 {
+		return nil, errors.Wrapf(ErrInvalidHeadersMessage, "%d trailing bytes", r.Len())
+	}
+
+	return hh, nil
+}
+
+// ReadHeaders reads serialised 80 byte headers, one after another, from r until it is
+// exhausted. It can be used to read headers from a file or a binary http response.
+func ReadHeaders(r io.Reader) ([]*bc.BlockHeader, error) {
+	var hh []*bc.BlockHeader
+	raw := make([]byte, HeaderSize)
+	for {
+		_, err := io.ReadFull(r, raw)
+		if err == io.EOF {
+			return hh, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d truncated", len(hh))
+		}
+		bh, err := bc.NewBlockHeaderFromBytes(raw)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidHeadersMessage, "header %d: %s", len(hh), err)
+		}
+		hh = append(hh, bh)
+	}
+}
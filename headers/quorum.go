@@ -0,0 +1,299 @@
+package headers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+var (
+	// ErrQuorumDisagreement is returned when the sources of a Quorum give different results and
+	// too few agree to reach the quorum.
+	ErrQuorumDisagreement = errors.New("block header sources disagree")
+	// ErrQuorumNotReached is returned when the sources of a Quorum which gave a result agree,
+	// but too many failed to reach the quorum.
+	ErrQuorumNotReached = errors.New("too few block header sources responded to reach quorum")
+)
+
+// SourceResult is what one source of a Quorum gave for a lookup.
+type SourceResult struct {
+	// Source is the index of the source in the chains given to NewQuorum.
+	Source int
+	// Result describes what the source gave, such as the hash of the header found, and is
+	// empty if it failed.
+	Result string
+	// Err is the error the source returned, if any.
+	Err error
+}
+
+func (r SourceResult) String() string {
+	if r.Result == "" {
+		return fmt.Sprintf("source %d failed: %s", r.Source, r.Err)
+	}
+	return fmt.Sprintf("source %d gave %s", r.Source, r.Result)
+}
+
+// A QuorumError is returned when the sources of a Quorum do not reach the quorum, it holds the
+// result of every source so those which diverged can be found. It matches ErrQuorumDisagreement
+// when the sources gave different results, and ErrQuorumNotReached otherwise.
+type QuorumError struct {
+	// Lookup describes the lookup made, such as "header 0000...".
+	Lookup  string
+	Quorum  int
+	Results []SourceResult
+}
+
+func (e *QuorumError) Error() string {
+	rr := make([]string, len(e.Results))
+	for i, r := range e.Results {
+		rr[i] = r.String()
+	}
+	reason := ErrQuorumNotReached
+	if e.Disagreed() {
+		reason = ErrQuorumDisagreement
+	}
+	return fmt.Sprintf("%s for %s, %d needed: %s", reason, e.Lookup, e.Quorum, strings.Join(rr, "; "))
+}
+
+// Is reports whether the error matches ErrQuorumDisagreement or ErrQuorumNotReached.
+func (e *QuorumError) Is(target error) bool {
+	if e.Disagreed() {
+		return target == ErrQuorumDisagreement
+	}
+	return target == ErrQuorumNotReached
+}
+
+// Disagreed returns true if the sources which did not fail gave different results.
+func (e *QuorumError) Disagreed() bool {
+	var result string
+	for _, r := range e.Results {
+		switch {
+		case r.Result == "":
+		case result == "":
+			result = r.Result
+		case r.Result != result:
+			return true
+		}
+	}
+	return false
+}
+
+type quorumOptions struct {
+	quorum int
+}
+
+// QuorumOpt defines a functional option that is used to modify the behaviour of a Quorum.
+type QuorumOpt func(*quorumOptions)
+
+// WithQuorumSize sets the number of sources which must agree on a result, it defaults to a
+// majority of the sources. It must be more than half of the sources, so two different results
+// cannot both reach it.
+func WithQuorumSize(n int) QuorumOpt {
+	return func(o *quorumOptions) {
+		o.quorum = n
+	}
+}
+
+// A Quorum is a bc.HeightIndexedBlockHeaderChain which looks up each header from several
+// sources at once, such as clients of independent nodes and header services, and only gives a
+// result which a quorum of them agree on. A single compromised source cannot then supply a
+// fake header, or hide a real one, so it suits spv.NewPaymentVerifier for high value payments.
+//
+// Sources agree when they give the same header, or both return bc.ErrHeaderNotFound or
+// bc.ErrNotOnLongestChain. Any other error from a source is a failure which is not counted
+// towards the quorum, nor is a header which does not have the hash asked for. A *QuorumError
+// is returned when the quorum is not reached.
+//
+// Height lookups are made of the sources which implement bc.HeightIndexedBlockHeaderChain,
+// bc.ErrNoHeightIndex is returned if too few do to reach the quorum. Sources can briefly
+// disagree on the chain tip as a new block propagates, so ChainTip may fail while they do.
+type Quorum struct {
+	chains []bc.BlockHeaderChain
+	quorum int
+}
+
+// NewQuorum returns a Quorum of the chains provided.
+func NewQuorum(chains []bc.BlockHeaderChain, opts ...QuorumOpt) (*Quorum, error) {
+	if len(chains) == 0 {
+		return nil, errors.New("at least one block header chain must be provided")
+	}
+	for i, c := range chains {
+		if c == nil {
+			return nil, errors.Errorf("block header chain %d is nil", i)
+		}
+	}
+	o := &quorumOptions{quorum: len(chains)/2 + 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.quorum*2 <= len(chains) || o.quorum > len(chains) {
+		return nil, errors.Errorf("quorum of %d must be more than half of the %d chains and no more than all of them", o.quorum, len(chains))
+	}
+
+	return &Quorum{chains: chains, quorum: o.quorum}, nil
+}
+
+// BlockHeader returns the header with the hash provided once a quorum of the sources agree on it.
+func (q *Quorum) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	v, err := q.poll(ctx, "header "+blockHash, q.all(), func(ctx context.Context, chain bc.BlockHeaderChain) (string, interface{}, error) {
+		bh, err := chain.BlockHeader(ctx, blockHash)
+		if err != nil {
+			return "", nil, err
+		}
+		if hash := bh.Hash(); hash.String() != blockHash {
+			return "", nil, errors.Wrapf(bc.ErrBlockHashMismatch, "asked for %s, header hashes to %s", blockHash, hash)
+		}
+		return "header " + blockHash, bh, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*bc.BlockHeader), nil
+}
+
+// BlockHeight returns the height of the header with the hash provided once a quorum of the
+// sources agree on it.
+func (q *Quorum) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	sources, err := q.indexed()
+	if err != nil {
+		return 0, err
+	}
+	v, err := q.poll(ctx, "height of "+blockHash, sources, func(ctx context.Context, chain bc.BlockHeaderChain) (string, interface{}, error) {
+		height, err := chain.(bc.HeightIndexedBlockHeaderChain).BlockHeight(ctx, blockHash)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("height %d", height), height, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return v.(uint64), nil
+}
+
+// BlockHeaderByHeight returns the header at the height provided once a quorum of the sources
+// agree on it.
+func (q *Quorum) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	sources, err := q.indexed()
+	if err != nil {
+		return nil, err
+	}
+	v, err := q.poll(ctx, fmt.Sprintf("header at height %d", height), sources, func(ctx context.Context, chain bc.BlockHeaderChain) (string, interface{}, error) {
+		bh, err := chain.(bc.HeightIndexedBlockHeaderChain).BlockHeaderByHeight(ctx, height)
+		if err != nil {
+			return "", nil, err
+		}
+		hash := bh.Hash()
+		return "header " + hash.String(), bh, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*bc.BlockHeader), nil
+}
+
+type chainTip struct {
+	height uint64
+	hash   string
+}
+
+// ChainTip returns the tip of the longest chain once a quorum of the sources agree on it.
+func (q *Quorum) ChainTip(ctx context.Context) (uint64, string, error) {
+	sources, err := q.indexed()
+	if err != nil {
+		return 0, "", err
+	}
+	v, err := q.poll(ctx, "chain tip", sources, func(ctx context.Context, chain bc.BlockHeaderChain) (string, interface{}, error) {
+		height, hash, err := chain.(bc.HeightIndexedBlockHeaderChain).ChainTip(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("tip %s at height %d", hash, height), chainTip{height: height, hash: hash}, nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	tip := v.(chainTip)
+	return tip.height, tip.hash, nil
+}
+
+// all returns the indices of every source.
+func (q *Quorum) all() []int {
+	sources := make([]int, len(q.chains))
+	for i := range sources {
+		sources[i] = i
+	}
+	return sources
+}
+
+// indexed returns the indices of the sources which can look up headers by height.
+func (q *Quorum) indexed() ([]int, error) {
+	var sources []int
+	for i, c := range q.chains {
+		if _, ok := c.(bc.HeightIndexedBlockHeaderChain); ok {
+			sources = append(sources, i)
+		}
+	}
+	if len(sources) < q.quorum {
+		return nil, bc.ErrNoHeightIndex
+	}
+	return sources, nil
+}
+
+type sourceResponse struct {
+	source int
+	key    string
+	val    interface{}
+	err    error
+}
+
+// poll makes a lookup of each source at once, returning the value given by the first quorum of
+// them to agree. The lookups still being made are then cancelled. A lookup gives a key which
+// is equal for equal results, sources which return bc.ErrHeaderNotFound or
+// bc.ErrNotOnLongestChain agree with each other and that error is returned.
+func (q *Quorum) poll(ctx context.Context, lookup string, sources []int,
+	fn func(context.Context, bc.BlockHeaderChain) (string, interface{}, error)) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make(chan sourceResponse, len(sources))
+	for i, source := range sources {
+		go func(i int, chain bc.BlockHeaderChain) {
+			key, val, err := fn(ctx, chain)
+			switch {
+			case errors.Is(err, bc.ErrHeaderNotFound):
+				key = "not found"
+			case errors.Is(err, bc.ErrNotOnLongestChain):
+				key = "not on the longest chain"
+			}
+			responses <- sourceResponse{source: i, key: key, val: val, err: err}
+		}(i, q.chains[source])
+	}
+
+	votes := map[string]int{}
+	results := make([]SourceResult, len(sources))
+	for range sources {
+		var r sourceResponse
+		select {
+		case r = <-responses:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		results[r.source] = SourceResult{Source: sources[r.source], Result: r.key, Err: r.err}
+		if r.key == "" {
+			continue
+		}
+		if votes[r.key]++; votes[r.key] == q.quorum {
+			if r.err != nil {
+				return nil, r.err
+			}
+			return r.val, nil
+		}
+	}
+
+	return nil, &QuorumError{Lookup: lookup, Quorum: q.quorum, Results: results}
+}
@@ -0,0 +1,254 @@
+package headers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headers"
+	"github.com/libsv/go-bc/spv"
+)
+
+var _ bc.HeightIndexedBlockHeaderChain = (*headers.Quorum)(nil)
+
+// memChain is an in memory bc.HeightIndexedBlockHeaderChain which fails every lookup with err
+// when it is set.
+type memChain struct {
+	headers []*bc.BlockHeader
+	err     error
+}
+
+func (m *memChain) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	height, err := m.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return m.headers[height], nil
+}
+
+func (m *memChain) BlockHeight(_ context.Context, blockHash string) (uint64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	for i, bh := range m.headers {
+		if hashOf(bh) == blockHash {
+			return uint64(i), nil
+		}
+	}
+	return 0, bc.ErrHeaderNotFound
+}
+
+func (m *memChain) BlockHeaderByHeight(_ context.Context, height uint64) (*bc.BlockHeader, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if height >= uint64(len(m.headers)) {
+		return nil, bc.ErrHeaderNotFound
+	}
+	return m.headers[height], nil
+}
+
+func (m *memChain) ChainTip(_ context.Context) (uint64, string, error) {
+	if m.err != nil {
+		return 0, "", m.err
+	}
+	return uint64(len(m.headers) - 1), hashOf(m.headers[len(m.headers)-1]), nil
+}
+
+// liarChain gives a header with a forged merkle root for every hash it is asked for.
+type liarChain struct {
+	forged *bc.BlockHeader
+}
+
+func (l *liarChain) BlockHeader(context.Context, string) (*bc.BlockHeader, error) {
+	return l.forged, nil
+}
+
+func TestQuorum_Lookups(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 10)
+	forked := append(append([]*bc.BlockHeader{}, hh[:8]...), mineChain(hh[7], 3, 600)...)
+	down := errors.New("connection refused")
+
+	tests := map[string]struct {
+		chains      []bc.BlockHeaderChain
+		opts        []headers.QuorumOpt
+		lookup      func(*headers.Quorum) (interface{}, error)
+		expResult   interface{}
+		expErr      error
+		expDiverged []int
+	}{
+		"all sources agree": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{headers: hh}, &memChain{headers: hh}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				bh, err := q.BlockHeader(ctx, hashOf(hh[5]))
+				if err != nil {
+					return nil, err
+				}
+				return bh.String(), nil
+			},
+			expResult: hh[5].String(),
+		},
+		"a majority is enough": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{err: down}, &memChain{headers: hh}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeight(ctx, hashOf(hh[5]))
+			},
+			expResult: uint64(5),
+		},
+		"sources agree a header is not found": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh[:3]}, &memChain{headers: hh[:3]}, &memChain{headers: hh}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeader(ctx, hashOf(hh[5]))
+			},
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"a forged header is a failure": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &liarChain{forged: forged(hh[5])}},
+			opts:   []headers.QuorumOpt{headers.WithQuorumSize(2)},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeader(ctx, hashOf(hh[5]))
+			},
+			expErr: headers.ErrQuorumNotReached,
+		},
+		"sources disagree on the header at a height": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{headers: forked}, &memChain{err: down}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeaderByHeight(ctx, 9)
+			},
+			expErr:      headers.ErrQuorumDisagreement,
+			expDiverged: []int{0, 1},
+		},
+		"sources disagree on the tip": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{headers: hh[:9]}, &memChain{headers: forked}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				_, hash, err := q.ChainTip(ctx)
+				return hash, err
+			},
+			expErr:      headers.ErrQuorumDisagreement,
+			expDiverged: []int{0, 1, 2},
+		},
+		"too few sources respond": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{err: down}, &memChain{err: down}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeader(ctx, hashOf(hh[1]))
+			},
+			expErr: headers.ErrQuorumNotReached,
+		},
+		"too few sources have a height index": {
+			chains: []bc.BlockHeaderChain{&memChain{headers: hh}, headersOnly{chain: &memChain{headers: hh}}, headersOnly{chain: &memChain{headers: hh}}},
+			lookup: func(q *headers.Quorum) (interface{}, error) {
+				return q.BlockHeight(ctx, hashOf(hh[1]))
+			},
+			expErr: bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			q, err := headers.NewQuorum(test.chains, test.opts...)
+			require.NoError(t, err)
+
+			result, err := test.lookup(q)
+			require.ErrorIs(t, err, test.expErr)
+			if test.expErr == nil {
+				require.Equal(t, test.expResult, result)
+			}
+
+			var qerr *headers.QuorumError
+			if errors.As(err, &qerr) {
+				require.Len(t, qerr.Results, len(test.chains))
+				var diverged []int
+				for _, r := range qerr.Results {
+					if r.Result != "" {
+						diverged = append(diverged, r.Source)
+					}
+				}
+				if test.expDiverged != nil {
+					require.Equal(t, test.expDiverged, diverged)
+				}
+			}
+		})
+	}
+}
+
+// forged returns a copy of a header with a different merkle root.
+func forged(bh *bc.BlockHeader) *bc.BlockHeader {
+	f := *bh
+	f.HashMerkleRoot = make([]byte, 32)
+	return &f
+}
+
+func TestNewQuorum(t *testing.T) {
+	chain := &memChain{}
+	tests := map[string]struct {
+		chains []bc.BlockHeaderChain
+		opts   []headers.QuorumOpt
+		expErr bool
+	}{
+		"default majority": {
+			chains: []bc.BlockHeaderChain{chain, chain, chain, chain},
+		},
+		"all sources": {
+			chains: []bc.BlockHeaderChain{chain, chain, chain},
+			opts:   []headers.QuorumOpt{headers.WithQuorumSize(3)},
+		},
+		"no sources": {
+			expErr: true,
+		},
+		"nil source": {
+			chains: []bc.BlockHeaderChain{chain, nil},
+			expErr: true,
+		},
+		"half the sources": {
+			chains: []bc.BlockHeaderChain{chain, chain, chain, chain},
+			opts:   []headers.QuorumOpt{headers.WithQuorumSize(2)},
+			expErr: true,
+		},
+		"more than the sources": {
+			chains: []bc.BlockHeaderChain{chain, chain},
+			opts:   []headers.QuorumOpt{headers.WithQuorumSize(3)},
+			expErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := headers.NewQuorum(test.chains, test.opts...)
+			if test.expErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestQuorum_MerkleProofVerifier(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 5)
+	liar := &liarChain{forged: forged(hh[1])}
+
+	// a lone liar cannot forge a proof of the zero merkle root.
+	q, err := headers.NewQuorum([]bc.BlockHeaderChain{&memChain{headers: hh}, &memChain{headers: hh}, liar})
+	require.NoError(t, err)
+	v, err := spv.NewMerkleProofVerifier(q)
+	require.NoError(t, err)
+
+	valid, _, err := v.VerifyMerkleProofJSON(ctx, &bc.MerkleProof{
+		TxOrID: hh[1].HashMerkleRootStr(),
+		Target: hashOf(hh[1]),
+	})
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	valid, _, err = v.VerifyMerkleProofJSON(ctx, &bc.MerkleProof{
+		TxOrID: liar.forged.HashMerkleRootStr(),
+		Target: hashOf(hh[1]),
+	})
+	require.NoError(t, err)
+	require.False(t, valid)
+}
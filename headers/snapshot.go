@@ -0,0 +1,151 @@
+package headers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Snapshot layout
+
+Field 						Purpose 															Size (Bytes)
+----------------------------------------------------------------------------------------------------
+magic 						"BCHS" 																4
+version 					snapshot format version, little endian 								4
+start height 				height of the first header in the snapshot, little endian 			8
+count 						number of headers in the snapshot, little endian 					8
+headers 					serialised 80 byte block headers, one after another 				80 * count
+checksum 					sha256 of every preceding byte 										32
+*/
+
+const (
+	snapshotMagic           = "BCHS"
+	snapshotVersion  uint32 = 1
+	snapshotPreamble        = 24
+)
+
+// Export writes a snapshot of the stored headers between the from and to heights, inclusive,
+// to w. The snapshot can be loaded into another store with Import.
+func (s *FileStore) Export(_ context.Context, w io.Writer, from, to uint64) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if from > to || from < s.base || to-s.base >= uint64(len(s.hashes)) {
+		return ErrHeightOutOfRange
+	}
+
+	preamble := make([]byte, snapshotPreamble)
+	copy(preamble, snapshotMagic)
+	binary.LittleEndian.PutUint32(preamble[4:8], snapshotVersion)
+	binary.LittleEndian.PutUint64(preamble[8:16], from)
+	binary.LittleEndian.PutUint64(preamble[16:24], to-from+1)
+
+	h := sha256.New()
+	mw := io.MultiWriter(w, h)
+	if _, err := mw.Write(preamble); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+	if _, err := mw.Write(s.raw[(from-s.base)*HeaderSize : (to-s.base+1)*HeaderSize]); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+	_, err := w.Write(h.Sum(nil))
+
+	return errors.Wrap(err, "failed to write snapshot")
+}
+
+// Import reads a snapshot written by Export and adds its headers to the store.
+//
+// An empty store takes the height of the first header in the snapshot as its base. Otherwise
+// the snapshot must start at or below the height after the current tip, and any headers
+// it holds which are already stored must match, or ErrSnapshotConflict is returned.
+//
+// Import only checks that the snapshot is intact and that its headers link together, it
+// should only be used with snapshots from a trusted source.
+func (s *FileStore) Import(_ context.Context, r io.Reader) error {
+	start, raw, _, err := readSnapshot(r)
+	if err != nil {
+		return err
+	}
+
+	return s.importHeaders(start, raw)
+}
+
+// importHeaders adds serialised headers, the first at height start, to the store.
+func (s *FileStore) importHeaders(start uint64, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return ErrStoreClosed
+	}
+
+	if len(s.hashes) == 0 {
+		if start != s.base {
+			if err := s.reset(start); err != nil {
+				return err
+			}
+		}
+		return s.append(raw)
+	}
+
+	next := s.base + uint64(len(s.hashes))
+	if start > next || start < s.base {
+		return errors.Wrapf(ErrHeightOutOfRange, "snapshot starts at %d, store holds %d to %d", start, s.base, next-1)
+	}
+
+	overlap := next - start
+	if count := uint64(len(raw) / HeaderSize); overlap > count {
+		overlap = count
+	}
+	stored := s.raw[(start-s.base)*HeaderSize : (start-s.base+overlap)*HeaderSize]
+	if !bytes.Equal(stored, raw[:overlap*HeaderSize]) {
+		return ErrSnapshotConflict
+	}
+
+	return s.append(raw[overlap*HeaderSize:])
+}
+
+// readSnapshot reads and checks a snapshot, returning the height of its first header, the
+// serialised headers and the snapshot checksum.
+func readSnapshot(r io.Reader) (uint64, []byte, []byte, error) {
+	h := sha256.New()
+	tr := io.TeeReader(r, h)
+
+	preamble := make([]byte, snapshotPreamble)
+	if _, err := io.ReadFull(tr, preamble); err != nil {
+		return 0, nil, nil, errors.Wrap(ErrInvalidSnapshot, err.Error())
+	}
+	if string(preamble[:4]) != snapshotMagic {
+		return 0, nil, nil, errors.Wrap(ErrInvalidSnapshot, "bad magic")
+	}
+	if v := binary.LittleEndian.Uint32(preamble[4:8]); v != snapshotVersion {
+		return 0, nil, nil, errors.Wrapf(ErrInvalidSnapshot, "unsupported version %d", v)
+	}
+	start := binary.LittleEndian.Uint64(preamble[8:16])
+	count := binary.LittleEndian.Uint64(preamble[16:24])
+	if count > math.MaxInt64/HeaderSize {
+		return 0, nil, nil, errors.Wrapf(ErrInvalidSnapshot, "header count %d too large", count)
+	}
+
+	// read through a bounded copy rather than allocating count*80 up front, so a corrupt
+	// count cannot cause a huge allocation.
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, tr, int64(count*HeaderSize))
+	if err != nil || uint64(n) != count*HeaderSize {
+		return 0, nil, nil, errors.Wrap(ErrInvalidSnapshot, "truncated headers")
+	}
+
+	sum := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(r, sum); err != nil {
+		return 0, nil, nil, errors.Wrap(ErrInvalidSnapshot, "missing checksum")
+	}
+	if !bytes.Equal(sum, h.Sum(nil)) {
+		return 0, nil, nil, errors.Wrap(ErrInvalidSnapshot, "checksum mismatch")
+	}
+
+	return start, buf.Bytes(), sum, nil
+}
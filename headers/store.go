@@ -0,0 +1,55 @@
+// Package headers provides storage and tooling for chains of bitcoin block headers.
+//
+// The types in this package implement bc.BlockHeaderChain so they can be supplied
+// directly to spv.NewPaymentVerifier and spv.NewMerkleProofVerifier.
+package headers
+
+import (
+	"context"
+
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// HeaderSize is the size in bytes of a serialised block header.
+const HeaderSize = 80
+
+var (
+	// ErrHeaderDoesNotConnect is returned when a header being appended does not
+	// reference the current tip of the store as its previous block.
+	ErrHeaderDoesNotConnect = errors.New("header does not connect to the chain tip")
+
+	// ErrEmptyStore is returned when the tip of a store is requested but no headers have been stored.
+	ErrEmptyStore = errors.New("header store is empty")
+
+	// ErrHeightOutOfRange is returned when a height is requested which is not held by the store.
+	ErrHeightOutOfRange = errors.New("height is outside the range of stored headers")
+
+	// ErrStoreClosed is returned when a store is used after it has been closed.
+	ErrStoreClosed = errors.New("header store is closed")
+
+	// ErrInvalidSnapshot is returned when a header snapshot is malformed or fails its checksum.
+	ErrInvalidSnapshot = errors.New("invalid header snapshot")
+
+	// ErrSnapshotConflict is returned when a snapshot contains headers which disagree
+	// with headers already held in the store.
+	ErrSnapshotConflict = errors.New("header snapshot conflicts with stored headers")
+)
+
+// A Store is a bc.HeightIndexedBlockHeaderChain which holds the longest chain of headers
+// it knows about, indexed by height and hash.
+//
+// Headers are appended to the tip of the store, and a reorg is handled by truncating
+// the store back to the fork point before appending the headers of the new chain.
+type Store interface {
+	bc.HeightIndexedBlockHeaderChain
+	Append(ctx context.Context, headers ...*bc.BlockHeader) error
+	Truncate(ctx context.Context, height uint64) error
+}
+
+// blockHash returns the hash of a block header.
+func blockHash(raw []byte) chainhash.Hash {
+	return chainhash.DoubleHashH(raw)
+}
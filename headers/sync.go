@@ -0,0 +1,521 @@
+package headers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+var (
+	// ErrInvalidProofOfWork is returned when the hash of a header does not satisfy its bits.
+	ErrInvalidProofOfWork = errors.New("header hash does not satisfy its proof of work")
+
+	// ErrUnexpectedBits is returned when the bits of a header are not those required by
+	// the difficulty rules of the network.
+	ErrUnexpectedBits = errors.New("header bits do not match the required difficulty")
+
+	// ErrTimeTooOld is returned when a header time is not after the median time of the
+	// headers before it.
+	ErrTimeTooOld = bc.ErrTimeTooOld
+
+	// ErrTimeTooNew is returned when a header time is too far in the future.
+	ErrTimeTooNew = bc.ErrTimeTooNew
+
+	// ErrCheckpointMismatch is returned when a header at a checkpoint height does not have
+	// the checkpoint hash.
+	ErrCheckpointMismatch = errors.New("header does not match the checkpoint at its height")
+
+	// ErrForkBelowCheckpoint is returned when a batch of headers forks from the stored chain
+	// below the highest checkpoint it has reached.
+	ErrForkBelowCheckpoint = errors.New("fork replaces headers below a checkpoint")
+
+	// ErrForkNotLonger is returned when a batch of headers forks from the stored chain but
+	// does not have more work than the headers it would replace.
+	ErrForkNotLonger = errors.New("fork does not have more work than the stored chain")
+)
+
+// A HeaderError is returned when a header is rejected by a Syncer, it records the header
+// and the reason it was rejected. The reason can be checked with errors.Is.
+type HeaderError struct {
+	Height uint64
+	Hash   string
+	Err    error
+}
+
+// Error returns the reason the header was rejected.
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("header %s at height %d rejected: %s", e.Hash, e.Height, e.Err)
+}
+
+// Unwrap returns the reason the header was rejected.
+func (e *HeaderError) Unwrap() error {
+	return e.Err
+}
+
+// NextBitsFunc returns the bits the network's difficulty rules require header to have.
+// The tip of chain is the parent of header.
+type NextBitsFunc func(ctx context.Context, chain bc.HeightIndexedBlockHeaderChain, header *bc.BlockHeader) ([]byte, error)
+
+// NetworkNextBits returns a NextBitsFunc which applies the difficulty rules of the network provided.
+func NetworkNextBits(params *bc.NetworkParams) NextBitsFunc {
+	return func(ctx context.Context, chain bc.HeightIndexedBlockHeaderChain, header *bc.BlockHeader) ([]byte, error) {
+		return bc.NextWorkRequired(ctx, chain, params, header)
+	}
+}
+
+// SyncState describes the progress of a Syncer.
+type SyncState int
+
+// The states of a Syncer.
+const (
+	// SyncStateIdle is the state of a Syncer which has not processed any headers.
+	SyncStateIdle SyncState = iota
+	// SyncStateSyncing is the state of a Syncer whose last batch was full, more headers
+	// should be requested.
+	SyncStateSyncing
+	// SyncStateSynced is the state of a Syncer whose last batch was not full, it has caught
+	// up with its source.
+	SyncStateSynced
+)
+
+// String returns the name of the state.
+func (s SyncState) String() string {
+	switch s {
+	case SyncStateIdle:
+		return "idle"
+	case SyncStateSyncing:
+		return "syncing"
+	case SyncStateSynced:
+		return "synced"
+	}
+	return "unknown"
+}
+
+type syncOptions struct {
+	nextBits    NextBitsFunc
+	checkpoints map[uint64]string
+	genesis     *bc.BlockHeader
+	now         func() time.Time
+	workers     int
+	onReorg     func(height uint64)
+}
+
+// SyncOpt defines a functional option that is used to modify the behaviour of a Syncer.
+type SyncOpt func(*syncOptions)
+
+// WithNextBits sets the difficulty rules headers are checked against, usually with
+// NetworkNextBits. Without it the bits of a header are not checked, though its hash
+// must still satisfy them.
+func WithNextBits(fn NextBitsFunc) SyncOpt {
+	return func(o *syncOptions) {
+		o.nextBits = fn
+	}
+}
+
+// WithCheckpoints sets the block hashes which headers at the checkpoint heights must match.
+func WithCheckpoints(checkpoints ...bc.Checkpoint) SyncOpt {
+	return func(o *syncOptions) {
+		o.checkpoints = make(map[uint64]string, len(checkpoints))
+		for _, cp := range checkpoints {
+			o.checkpoints[cp.Height] = cp.Hash
+		}
+	}
+}
+
+// WithNetwork checks headers against the difficulty rules and checkpoints of a network, and
+// seeds an empty store with the network's genesis header.
+func WithNetwork(params *bc.NetworkParams) SyncOpt {
+	return func(o *syncOptions) {
+		WithNextBits(NetworkNextBits(params))(o)
+		WithCheckpoints(params.Checkpoints...)(o)
+		o.genesis = params.GenesisHeader
+	}
+}
+
+// WithClock sets the clock header times are checked against, it defaults to time.Now.
+func WithClock(now func() time.Time) SyncOpt {
+	return func(o *syncOptions) {
+		o.now = now
+	}
+}
+
+// WithWorkers sets the number of goroutines used to check the proof of work of a batch,
+// it defaults to the number of CPUs.
+func WithWorkers(n int) SyncOpt {
+	return func(o *syncOptions) {
+		o.workers = n
+	}
+}
+
+// WithReorgHandler sets a function called with the height of the fork point whenever a fork is
+// adopted, after the stored headers above it are replaced, such as Cache.Reorg.
+func WithReorgHandler(fn func(height uint64)) SyncOpt {
+	return func(o *syncOptions) {
+		o.onReorg = fn
+	}
+}
+
+// A Syncer validates batches of headers and adds them to a Store.
+//
+// Each header must link to the one before it, satisfy its proof of work, have the bits
+// required by the network's difficulty rules, have a time after the median time past of
+// the 11 headers before it and no more than 2 hours ahead of the clock, and match any
+// checkpoint at its height.
+//
+// A batch which forks from the stored chain is adopted when it has more work than the
+// stored headers it replaces, the store is truncated to the fork point and the batch
+// appended. A fork from below the highest checkpoint the stored chain has reached is refused
+// with ErrForkBelowCheckpoint. Forks are only compared against a single batch, so a fork longer than a
+// batch cannot be adopted.
+type Syncer struct {
+	mu    sync.Mutex
+	store Store
+	opts  *syncOptions
+	state SyncState
+}
+
+// NewSyncer creates a new Syncer which adds headers to store. The store should already hold
+// a trusted header to sync from, such as an imported snapshot, unless WithNetwork is used
+// when an empty store is seeded with the genesis header.
+func NewSyncer(store Store, opts ...SyncOpt) (*Syncer, error) {
+	if store == nil {
+		return nil, errors.New("a header store must be provided")
+	}
+	o := &syncOptions{
+		now:     time.Now,
+		workers: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+
+	return &Syncer{store: store, opts: o}, nil
+}
+
+// State returns the current state of the syncer.
+func (s *Syncer) State() SyncState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Locator returns a block locator for the stored chain, the hashes of the tip and headers
+// at exponentially increasing distances below it, which a peer uses to find where to send
+// headers from.
+func (s *Syncer) Locator(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tip, hash, err := s.store.ChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	locator := []string{hash}
+	step := uint64(1)
+	for height := tip; height > 0; {
+		if step > height {
+			height = 0
+		} else {
+			height -= step
+		}
+		if len(locator) >= 10 {
+			step *= 2
+		}
+		bh, err := s.store.BlockHeaderByHeight(ctx, height)
+		if errors.Is(err, bc.ErrHeaderNotFound) {
+			// the store does not hold headers this far back.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		locator = append(locator, bh.Hash().String())
+	}
+
+	return locator, nil
+}
+
+// ProcessHeaders validates a batch of headers, in height order, and adds the valid headers
+// to the store. Headers already held by the store are skipped.
+//
+// It returns the number of headers added. If a header is invalid the headers before it are
+// still added and a *HeaderError is returned giving the reason it was rejected.
+func (s *Syncer) ProcessHeaders(ctx context.Context, headers ...*bc.BlockHeader) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(headers) == 0 {
+		s.state = SyncStateSynced
+		return 0, nil
+	}
+
+	tip, _, err := s.store.ChainTip(ctx)
+	if errors.Is(err, ErrEmptyStore) && s.opts.genesis != nil {
+		if err = s.store.Append(ctx, s.opts.genesis); err != nil {
+			return 0, err
+		}
+		tip, _, err = s.store.ChainTip(ctx)
+	}
+	if err != nil {
+		return 0, err
+	}
+	full := len(headers) >= MaxHeadersPerMessage
+	hashes, pow := s.checkProofOfWork(headers)
+
+	parent, err := s.store.BlockHeight(ctx, headers[0].HashPrevBlockStr())
+	if errors.Is(err, bc.ErrHeaderNotFound) {
+		return 0, &HeaderError{Hash: hashes[0].String(), Err: ErrHeaderDoesNotConnect}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// skip headers already in the store.
+	for len(headers) > 0 && parent < tip {
+		h, err := s.store.BlockHeight(ctx, hashes[0].String())
+		if errors.Is(err, bc.ErrHeaderNotFound) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if h != parent+1 {
+			break
+		}
+		parent = h
+		headers, hashes, pow = headers[1:], hashes[1:], pow[1:]
+	}
+	if len(headers) == 0 {
+		s.state = SyncStateSynced
+		return 0, nil
+	}
+	// a fork must not replace headers below the last checkpoint the stored chain has passed,
+	// whichever heights the batch covers.
+	if parent < tip {
+		var last uint64
+		for height := range s.opts.checkpoints {
+			if height <= tip && height > last {
+				last = height
+			}
+		}
+		if parent < last {
+			return 0, &HeaderError{Height: parent + 1, Hash: hashes[0].String(), Err: ErrForkBelowCheckpoint}
+		}
+	}
+
+	view := &batchView{store: s.store, base: parent}
+	var rejected error
+	for i, bh := range headers {
+		if err := s.checkHeader(ctx, view, bh, hashes, pow, i); err != nil {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			var herr *HeaderError
+			if !errors.As(err, &herr) {
+				return 0, err
+			}
+			rejected = err
+			break
+		}
+		view.push(bh, hashes[i])
+	}
+
+	if parent < tip {
+		if len(view.headers) == 0 {
+			return 0, rejected
+		}
+		longer, err := s.hasMoreWork(ctx, view.headers, parent, tip)
+		if err != nil {
+			return 0, err
+		}
+		if !longer {
+			if rejected != nil {
+				return 0, rejected
+			}
+			return 0, &HeaderError{Height: parent + 1, Hash: hashes[0].String(), Err: ErrForkNotLonger}
+		}
+		if err = s.store.Truncate(ctx, parent); err != nil {
+			return 0, err
+		}
+		if s.opts.onReorg != nil {
+			defer s.opts.onReorg(parent)
+		}
+	}
+
+	if err := s.store.Append(ctx, view.headers...); err != nil {
+		return 0, err
+	}
+	if rejected == nil {
+		s.state = SyncStateSynced
+		if full {
+			s.state = SyncStateSyncing
+		}
+	}
+
+	return len(view.headers), rejected
+}
+
+// checkProofOfWork hashes each header and checks its proof of work, splitting the
+// batch across the configured workers.
+func (s *Syncer) checkProofOfWork(headers []*bc.BlockHeader) ([]chainhash.Hash, []bool) {
+	hashes := make([]chainhash.Hash, len(headers))
+	pow := make([]bool, len(headers))
+
+	per := (len(headers) + s.opts.workers - 1) / s.opts.workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(headers); start += per {
+		end := start + per
+		if end > len(headers) {
+			end = len(headers)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				hashes[i] = headers[i].Hash()
+				pow[i] = headers[i].Valid()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	return hashes, pow
+}
+
+// checkHeader validates the header at index i of the batch against the headers before it.
+func (s *Syncer) checkHeader(ctx context.Context, view *batchView, bh *bc.BlockHeader, hashes []chainhash.Hash,
+	pow []bool, i int) error {
+	height := view.base + uint64(len(view.headers)) + 1
+	reject := func(err error) error {
+		return &HeaderError{Height: height, Hash: hashes[i].String(), Err: err}
+	}
+
+	if i > 0 && bh.HashPrevBlockStr() != hashes[i-1].String() {
+		return reject(ErrHeaderDoesNotConnect)
+	}
+	if !pow[i] {
+		return reject(ErrInvalidProofOfWork)
+	}
+	if cp, ok := s.opts.checkpoints[height]; ok && cp != hashes[i].String() {
+		return reject(ErrCheckpointMismatch)
+	}
+	if s.opts.nextBits != nil {
+		bits, err := s.opts.nextBits(ctx, view, bh)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(bits, bh.Bits) {
+			return reject(errors.Wrapf(ErrUnexpectedBits, "expected %x, got %x", bits, bh.Bits))
+		}
+	}
+
+	if err := bc.CheckHeaderTime(ctx, view, bh, bc.WithClock(s.opts.now)); err != nil {
+		if errors.Is(err, bc.ErrTimeTooOld) || errors.Is(err, bc.ErrTimeTooNew) {
+			return reject(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// hasMoreWork returns true if the headers forking from the stored chain at parent have more
+// work than the stored headers above parent.
+func (s *Syncer) hasMoreWork(ctx context.Context, fork []*bc.BlockHeader, parent, tip uint64) (bool, error) {
+	var forkWork, storedWork bc.ChainWork
+	for _, bh := range fork {
+		forkWork = forkWork.Add(headerWork(bh))
+	}
+	for height := parent + 1; height <= tip; height++ {
+		bh, err := s.store.BlockHeaderByHeight(ctx, height)
+		if err != nil {
+			return false, err
+		}
+		storedWork = storedWork.Add(headerWork(bh))
+	}
+
+	return forkWork.Cmp(storedWork) > 0, nil
+}
+
+// headerWork returns the work of a header, headers with invalid bits have no work.
+func headerWork(bh *bc.BlockHeader) bc.ChainWork {
+	target, err := bc.NewTargetFromBits(bh.Bits)
+	if err != nil {
+		return bc.ChainWork{}
+	}
+	return target.Work()
+}
+
+// batchView is a bc.HeightIndexedBlockHeaderChain over the stored chain up to, and including,
+// the height base followed by the headers of a batch which have been validated so far.
+type batchView struct {
+	store   Store
+	base    uint64
+	headers []*bc.BlockHeader
+	hashes  []chainhash.Hash
+	index   map[string]uint64
+}
+
+func (v *batchView) push(bh *bc.BlockHeader, hash chainhash.Hash) {
+	if v.index == nil {
+		v.index = make(map[string]uint64)
+	}
+	v.headers = append(v.headers, bh)
+	v.hashes = append(v.hashes, hash)
+	v.index[hash.String()] = v.base + uint64(len(v.headers))
+}
+
+func (v *batchView) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	height, err := v.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	return v.BlockHeaderByHeight(ctx, height)
+}
+
+func (v *batchView) ChainTip(ctx context.Context) (uint64, string, error) {
+	if n := len(v.hashes); n > 0 {
+		return v.base + uint64(n), v.hashes[n-1].String(), nil
+	}
+	bh, err := v.store.BlockHeaderByHeight(ctx, v.base)
+	if err != nil {
+		return 0, "", err
+	}
+	return v.base, bh.Hash().String(), nil
+}
+
+func (v *batchView) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	if height > v.base {
+		if height-v.base > uint64(len(v.headers)) {
+			return nil, bc.ErrHeaderNotFound
+		}
+		return v.headers[height-v.base-1], nil
+	}
+	return v.store.BlockHeaderByHeight(ctx, height)
+}
+
+func (v *batchView) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	if height, ok := v.index[blockHash]; ok {
+		return height, nil
+	}
+	height, err := v.store.BlockHeight(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	if height > v.base {
+		return 0, bc.ErrNotOnLongestChain
+	}
+	return height, nil
+}
@@ -0,0 +1,349 @@
+package headers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libsv/go-bk/crypto"
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headers"
+)
+
+// mineChain builds n headers with the easiest difficulty, spaced apart in time, which link on from parent.
+func mineChain(parent *bc.BlockHeader, n int, spacing uint32) []*bc.BlockHeader {
+	hh := make([]*bc.BlockHeader, n)
+	for i := range hh {
+		hh[i] = &bc.BlockHeader{
+			Version:        parent.Version,
+			Time:           parent.Time + spacing,
+			HashPrevBlock:  bt.ReverseBytes(crypto.Sha256d(parent.Bytes())),
+			HashMerkleRoot: parent.HashMerkleRoot,
+			Bits:           []byte{0x20, 0x7f, 0xff, 0xff},
+		}
+		for !hh[i].Valid() {
+			hh[i].Nonce++
+		}
+		parent = hh[i]
+	}
+	return hh
+}
+
+// easyGenesis returns a header with the easiest difficulty to build a chain on.
+func easyGenesis() *bc.BlockHeader {
+	bh := &bc.BlockHeader{
+		Version:        1,
+		Time:           1296688602,
+		HashPrevBlock:  make([]byte, 32),
+		HashMerkleRoot: make([]byte, 32),
+		Bits:           []byte{0x20, 0x7f, 0xff, 0xff},
+	}
+	for !bh.Valid() {
+		bh.Nonce++
+	}
+	return bh
+}
+
+func hashOf(bh *bc.BlockHeader) string {
+	return hex.EncodeToString(bt.ReverseBytes(crypto.Sha256d(bh.Bytes())))
+}
+
+func TestSyncer_ProcessHeaders(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+
+	// the empty store is seeded with the genesis header of the network.
+	s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+	syncer, err := headers.NewSyncer(s, headers.WithWorkers(4), headers.WithNetwork(bc.MainNet))
+	require.NoError(t, err)
+	require.Equal(t, headers.SyncStateIdle, syncer.State())
+
+	n, err := syncer.ProcessHeaders(ctx, hh[1:2001]...)
+	require.NoError(t, err)
+	require.Equal(t, 2000, n)
+	require.Equal(t, headers.SyncStateSyncing, syncer.State())
+
+	// a batch overlapping the stored chain only adds the new headers.
+	n, err = syncer.ProcessHeaders(ctx, hh[1990:3990]...)
+	require.NoError(t, err)
+	require.Equal(t, 1989, n)
+	require.Equal(t, headers.SyncStateSyncing, syncer.State())
+
+	n, err = syncer.ProcessHeaders(ctx, hh[3990:]...)
+	require.NoError(t, err)
+	require.Equal(t, 43, n)
+	require.Equal(t, headers.SyncStateSynced, syncer.State())
+
+	height, hash, err := s.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4032), height)
+	require.Equal(t, "00000000ca4b69045a03d7b20624def97a5366418648d5005e82fd3b345d20d0", hash)
+
+	// replaying stored headers adds nothing.
+	n, err = syncer.ProcessHeaders(ctx, hh[100:200]...)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	locator, err := syncer.Locator(ctx)
+	require.NoError(t, err)
+	require.Equal(t, hash, locator[0])
+	require.Equal(t, hashOf(hh[4031]), locator[1])
+	require.Equal(t, hashOf(hh[0]), locator[len(locator)-1])
+	require.Less(t, len(locator), 30)
+}
+
+func TestSyncer_ProcessHeadersRejected(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 20)
+	genesis := easyGenesis()
+	easy := mineChain(genesis, 20, 600)
+
+	badPoW := *hh[6]
+	badPoW.Nonce++
+
+	// a header whose time is not after the median of the 11 before it.
+	old := mineChain(easy[14], 1, 600)[0]
+	old.Time = easy[9].Time
+	old.Nonce = 0
+	for !old.Valid() {
+		old.Nonce++
+	}
+
+	tests := map[string]struct {
+		stored    []*bc.BlockHeader
+		batch     []*bc.BlockHeader
+		opts      []headers.SyncOpt
+		expAdded  int
+		expHeight uint64
+		expErr    error
+	}{
+		"header not linking to the previous header": {
+			stored:    hh[:1],
+			batch:     append(append([]*bc.BlockHeader{}, hh[1:5]...), hh[6:10]...),
+			expAdded:  4,
+			expHeight: 5,
+			expErr:    headers.ErrHeaderDoesNotConnect,
+		},
+		"batch not connecting to the store": {
+			stored: hh[:1],
+			batch:  hh[5:10],
+			expErr: headers.ErrHeaderDoesNotConnect,
+		},
+		"header with invalid proof of work": {
+			stored:    hh[:1],
+			batch:     append(append([]*bc.BlockHeader{}, hh[1:6]...), &badPoW),
+			expAdded:  5,
+			expHeight: 6,
+			expErr:    headers.ErrInvalidProofOfWork,
+		},
+		"header with unexpected bits": {
+			stored: hh[:1],
+			batch:  hh[1:10],
+			opts: []headers.SyncOpt{headers.WithNextBits(
+				func(ctx context.Context, chain bc.HeightIndexedBlockHeaderChain, _ *bc.BlockHeader) ([]byte, error) {
+					height, _, err := chain.ChainTip(ctx)
+					if err != nil {
+						return nil, err
+					}
+					if height == 7 {
+						return []byte{0x1c, 0x00, 0xff, 0xff}, nil
+					}
+					return []byte{0x1d, 0x00, 0xff, 0xff}, nil
+				},
+			)},
+			expAdded:  7,
+			expHeight: 8,
+			expErr:    headers.ErrUnexpectedBits,
+		},
+		"header not matching a checkpoint": {
+			stored:    hh[:1],
+			batch:     hh[1:10],
+			opts:      []headers.SyncOpt{headers.WithCheckpoints(bc.Checkpoint{Height: 3, Hash: hashOf(hh[4])})},
+			expAdded:  2,
+			expHeight: 3,
+			expErr:    headers.ErrCheckpointMismatch,
+		},
+		"header time too far in the future": {
+			stored: hh[:1],
+			batch:  hh[1:10],
+			opts: []headers.SyncOpt{headers.WithClock(func() time.Time {
+				return time.Unix(int64(hh[4].Time), 0).Add(-2*time.Hour - time.Second)
+			})},
+			expAdded:  3,
+			expHeight: 4,
+			expErr:    headers.ErrTimeTooNew,
+		},
+		"header time not after the median time past": {
+			stored:    append([]*bc.BlockHeader{genesis}, easy[:10]...),
+			batch:     append(append([]*bc.BlockHeader{}, easy[10:15]...), old),
+			expAdded:  5,
+			expHeight: 16,
+			expErr:    headers.ErrTimeTooOld,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+			require.NoError(t, s.Append(ctx, test.stored...))
+			syncer, err := headers.NewSyncer(s, test.opts...)
+			require.NoError(t, err)
+
+			n, err := syncer.ProcessHeaders(ctx, test.batch...)
+			require.ErrorIs(t, err, test.expErr)
+			require.Equal(t, test.expAdded, n)
+
+			var herr *headers.HeaderError
+			require.ErrorAs(t, err, &herr)
+			require.Equal(t, test.expHeight, herr.Height)
+
+			height, _, err := s.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, uint64(len(test.stored)-1+test.expAdded), height)
+		})
+	}
+}
+
+func TestSyncer_ProcessHeadersFork(t *testing.T) {
+	ctx := context.Background()
+	genesis := easyGenesis()
+	chain := mineChain(genesis, 10, 600)
+
+	tests := map[string]struct {
+		fork     []*bc.BlockHeader
+		opts     []headers.SyncOpt
+		expAdded int
+		expTip   string
+		expErr   error
+	}{
+		"fork with more work is adopted": {
+			fork:     mineChain(chain[4], 7, 601),
+			expAdded: 7,
+		},
+		"fork with the same work is ignored": {
+			fork:   mineChain(chain[4], 5, 601),
+			expErr: headers.ErrForkNotLonger,
+		},
+		"fork below a checkpoint is refused": {
+			fork:   mineChain(chain[4], 7, 601),
+			opts:   []headers.SyncOpt{headers.WithCheckpoints(bc.Checkpoint{Height: 7, Hash: hashOf(chain[6])})},
+			expErr: headers.ErrForkBelowCheckpoint,
+		},
+		"fork below a checkpoint it does not reach is refused": {
+			fork:   mineChain(chain[1], 2, 601),
+			opts:   []headers.SyncOpt{headers.WithCheckpoints(bc.Checkpoint{Height: 7, Hash: hashOf(chain[6])})},
+			expErr: headers.ErrForkBelowCheckpoint,
+		},
+		"fork above the last checkpoint is adopted": {
+			fork:     mineChain(chain[4], 7, 601),
+			opts:     []headers.SyncOpt{headers.WithCheckpoints(bc.Checkpoint{Height: 5, Hash: hashOf(chain[4])})},
+			expAdded: 7,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := openStore(t, filepath.Join(t.TempDir(), "headers.dat"))
+			require.NoError(t, s.Append(ctx, genesis))
+			require.NoError(t, s.Append(ctx, chain...))
+			syncer, err := headers.NewSyncer(s, test.opts...)
+			require.NoError(t, err)
+
+			n, err := syncer.ProcessHeaders(ctx, test.fork...)
+			require.ErrorIs(t, err, test.expErr)
+			require.Equal(t, test.expAdded, n)
+
+			expTip := chain[len(chain)-1]
+			if test.expErr == nil {
+				expTip = test.fork[len(test.fork)-1]
+			}
+			height, hash, err := s.ChainTip(ctx)
+			require.NoError(t, err)
+			require.Equal(t, hashOf(expTip), hash)
+			_, err = s.BlockHeader(ctx, hashOf(chain[4]))
+			require.NoError(t, err)
+			if test.expErr == nil {
+				require.Equal(t, uint64(12), height)
+				_, err = s.BlockHeader(ctx, hashOf(chain[5]))
+				require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+			}
+		})
+	}
+}
+
+func TestDecodeHeadersMessage(t *testing.T) {
+	hh := mainnetHeaders(t, 3)
+
+	var msg []byte
+	msg = append(msg, 0x03)
+	for _, bh := range hh {
+		msg = append(msg, bh.Bytes()...)
+		msg = append(msg, 0x00)
+	}
+
+	tests := map[string]struct {
+		msg    []byte
+		exp    []*bc.BlockHeader
+		expErr error
+	}{
+		"valid message": {
+			msg: msg,
+			exp: hh,
+		},
+		"empty message": {
+			msg:    []byte{},
+			expErr: headers.ErrInvalidHeadersMessage,
+		},
+		"truncated header": {
+			msg:    msg[:100],
+			expErr: headers.ErrInvalidHeadersMessage,
+		},
+		"header with transactions": {
+			msg:    append(append([]byte{0x01}, hh[0].Bytes()...), 0x01),
+			expErr: headers.ErrInvalidHeadersMessage,
+		},
+		"too many headers": {
+			msg:    []byte{0xfd, 0xd1, 0x07},
+			expErr: headers.ErrInvalidHeadersMessage,
+		},
+		"trailing bytes": {
+			msg:    append(append([]byte{}, msg...), 0x00),
+			expErr: headers.ErrInvalidHeadersMessage,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hh, err := headers.DecodeHeadersMessage(test.msg)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, hh)
+		})
+	}
+}
+
+func TestReadHeaders(t *testing.T) {
+	hh := mainnetHeaders(t, 3)
+
+	var buf bytes.Buffer
+	for _, bh := range hh {
+		buf.Write(bh.Bytes())
+	}
+	b := buf.Bytes()
+
+	read, err := headers.ReadHeaders(bytes.NewReader(b))
+	require.NoError(t, err)
+	require.Equal(t, hh, read)
+
+	_, err = headers.ReadHeaders(bytes.NewReader(b[:len(b)-1]))
+	require.ErrorIs(t, err, headers.ErrInvalidHeadersMessage)
+}
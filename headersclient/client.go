@@ -0,0 +1,351 @@
+// Package headersclient provides a client for a standalone block headers service, which
+// serves the headers of the chain over a REST API.
+//
+// The Client implements bc.HeightIndexedBlockHeaderChain, so it can be supplied directly to
+// spv.NewPaymentVerifier and spv.NewMerkleProofVerifier.
+package headersclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// ErrUnauthorised is returned when the service rejects the API key of the client.
+var ErrUnauthorised = errors.New("headers service rejected the api key")
+
+// State is the state of a header known to the service.
+type State string
+
+// The states of a header.
+const (
+	// StateLongestChain is the state of a header on the longest chain.
+	StateLongestChain State = "LONGEST_CHAIN"
+	// StateStale is the state of a header on a chain which is not the longest.
+	StateStale State = "STALE"
+	// StateOrphan is the state of a header whose parent is not known.
+	StateOrphan State = "ORPHAN"
+	// StateRejected is the state of a header which failed validation.
+	StateRejected State = "REJECTED"
+)
+
+// HeaderState is a header with its state and height, as held by the service.
+type HeaderState struct {
+	Header *bc.BlockHeader
+	State  State
+	Height uint64
+}
+
+// The results of verifying merkle roots.
+const (
+	// ConfirmationConfirmed is the result for a merkle root of the block at its height.
+	ConfirmationConfirmed = "CONFIRMED"
+	// ConfirmationInvalid is the result for a merkle root which is not of the block at its height.
+	ConfirmationInvalid = "INVALID"
+	// ConfirmationUnableToVerify is the result for a merkle root of a height above the tip.
+	ConfirmationUnableToVerify = "UNABLE_TO_VERIFY"
+)
+
+// MerkleRoot is a merkle root, and the height of the block it is claimed to be of, to verify.
+type MerkleRoot struct {
+	MerkleRoot  string `json:"merkleRoot"`
+	BlockHeight uint64 `json:"blockHeight"`
+}
+
+// MerkleRootConfirmation is the result of verifying a merkle root.
+type MerkleRootConfirmation struct {
+	MerkleRoot   string `json:"merkleRoot"`
+	BlockHeight  uint64 `json:"blockHeight"`
+	BlockHash    string `json:"blockHash"`
+	Confirmation string `json:"confirmation"`
+}
+
+// MerkleRootsConfirmation is the result of verifying a set of merkle roots. ConfirmationState
+// is ConfirmationConfirmed only if every merkle root is confirmed.
+type MerkleRootsConfirmation struct {
+	ConfirmationState string                   `json:"confirmationState"`
+	Confirmations     []MerkleRootConfirmation `json:"confirmations"`
+}
+
+type clientOptions struct {
+	httpClient *http.Client
+	apiKey     string
+	timeout    time.Duration
+}
+
+// ClientOpt defines a functional option that is used to modify the behaviour of a Client.
+type ClientOpt func(*clientOptions)
+
+// WithAPIKey sets the API key sent, as a bearer token, with each request to the service.
+func WithAPIKey(key string) ClientOpt {
+	return func(o *clientOptions) {
+		o.apiKey = key
+	}
+}
+
+// WithHTTPClient sets the http.Client used to call the service, http.DefaultClient is used
+// by default.
+func WithHTTPClient(c *http.Client) ClientOpt {
+	return func(o *clientOptions) {
+		o.httpClient = c
+	}
+}
+
+// WithTimeout limits the time each request to the service can take. Requests are only
+// limited by the context they are given by default.
+func WithTimeout(d time.Duration) ClientOpt {
+	return func(o *clientOptions) {
+		o.timeout = d
+	}
+}
+
+// A Client calls the REST API of a block headers service. It is safe for concurrent use.
+type Client struct {
+	url  string
+	opts *clientOptions
+}
+
+// NewClient returns a Client for the headers service at the url provided, such as
+// http://localhost:8080.
+func NewClient(serviceURL string, opts ...ClientOpt) (*Client, error) {
+	if _, err := url.ParseRequestURI(serviceURL); err != nil {
+		return nil, errors.Wrapf(err, "invalid headers service url %q", serviceURL)
+	}
+	o := &clientOptions{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Client{url: strings.TrimSuffix(serviceURL, "/"), opts: o}, nil
+}
+
+type headerJSON struct {
+	Hash              string `json:"hash"`
+	Version           uint32 `json:"version"`
+	PrevBlockHash     string `json:"prevBlockHash"`
+	MerkleRoot        string `json:"merkleRoot"`
+	CreationTimestamp uint32 `json:"creationTimestamp"`
+	DifficultyTarget  uint32 `json:"difficultyTarget"`
+	Nonce             uint32 `json:"nonce"`
+}
+
+type headerStateJSON struct {
+	Header headerJSON `json:"header"`
+	State  State      `json:"state"`
+	Height uint64     `json:"height"`
+}
+
+// HeaderState returns the header with the hash provided along with its state and height.
+// bc.ErrHeaderNotFound is returned if the service does not know of it.
+func (c *Client) HeaderState(ctx context.Context, blockHash string) (*HeaderState, error) {
+	var hs headerStateJSON
+	if err := c.get(ctx, "/api/v1/chain/header/state/"+url.PathEscape(blockHash), &hs); err != nil {
+		return nil, notFound(err, blockHash)
+	}
+	return hs.toHeaderState()
+}
+
+// BlockHeader returns the header with the hash provided. bc.ErrHeaderNotFound is returned if
+// the service does not know of it and bc.ErrNotOnLongestChain if it is stale or an orphan.
+func (c *Client) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	hs, err := c.HeaderState(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if hs.State != StateLongestChain {
+		return nil, errors.Wrapf(bc.ErrNotOnLongestChain, "header %s is %s", blockHash, hs.State)
+	}
+	return hs.Header, nil
+}
+
+// BlockHeight returns the height of the header with the hash provided, on the longest chain.
+func (c *Client) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	hs, err := c.HeaderState(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	if hs.State != StateLongestChain {
+		return 0, errors.Wrapf(bc.ErrNotOnLongestChain, "header %s is %s", blockHash, hs.State)
+	}
+	return hs.Height, nil
+}
+
+// ChainTip returns the height and hash of the tip of the longest chain known to the service.
+func (c *Client) ChainTip(ctx context.Context) (uint64, string, error) {
+	var hs headerStateJSON
+	if err := c.get(ctx, "/api/v1/chain/tip/longest", &hs); err != nil {
+		return 0, "", err
+	}
+	tip, err := hs.toHeaderState()
+	if err != nil {
+		return 0, "", err
+	}
+	return tip.Height, tip.Header.Hash().String(), nil
+}
+
+// BlockHeaderByHeight returns the header at the height provided on the longest chain.
+// bc.ErrHeaderNotFound is returned if the height is above the tip of the service.
+func (c *Client) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	var hh []headerJSON
+	path := fmt.Sprintf("/api/v1/chain/header/byHeight?height=%d&count=1", height)
+	if err := c.get(ctx, path, &hh); err != nil {
+		return nil, notFound(err, fmt.Sprintf("height %d", height))
+	}
+
+	if len(hh) == 1 {
+		return hh[0].toBlockHeader()
+	}
+	// the service may return the stale headers at a height along with the longest chain header.
+	for _, h := range hh {
+		hs, err := c.HeaderState(ctx, h.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if hs.State == StateLongestChain && hs.Height == height {
+			return hs.Header, nil
+		}
+	}
+
+	return nil, errors.Wrapf(bc.ErrHeaderNotFound, "height %d", height)
+}
+
+// VerifyMerkleRoots asks the service whether each merkle root is that of the block at its
+// height on the longest chain.
+func (c *Client) VerifyMerkleRoots(ctx context.Context, roots ...MerkleRoot) (*MerkleRootsConfirmation, error) {
+	if roots == nil {
+		roots = []MerkleRoot{}
+	}
+	var resp MerkleRootsConfirmation
+	if err := c.do(ctx, http.MethodPost, "/api/v1/chain/merkleroot/verify", roots, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, path, nil, out)
+}
+
+// statusError is returned for a response with an unexpected status.
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("headers service returned status %d: %s", e.status, e.body)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode request")
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, body)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.apiKey)
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", path)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorised
+	case resp.StatusCode != http.StatusOK:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{status: resp.StatusCode, body: string(bytes.TrimSpace(b))}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "failed to decode response of %s", path)
+	}
+	return nil
+}
+
+// notFound maps a not found or bad request response onto bc.ErrHeaderNotFound, the service
+// returns a bad request for a height above its tip.
+func notFound(err error, what string) error {
+	var se *statusError
+	if errors.As(err, &se) && (se.status == http.StatusNotFound || se.status == http.StatusBadRequest) {
+		return errors.Wrap(bc.ErrHeaderNotFound, what)
+	}
+	return err
+}
+
+func (hs *headerStateJSON) toHeaderState() (*HeaderState, error) {
+	bh, err := hs.Header.toBlockHeader()
+	if err != nil {
+		return nil, err
+	}
+	return &HeaderState{Header: bh, State: hs.State, Height: hs.Height}, nil
+}
+
+// toBlockHeader returns the header, checking the hash given by the service matches it.
+func (h *headerJSON) toBlockHeader() (*bc.BlockHeader, error) {
+	prev, err := decodeHash(h.PrevBlockHash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid previous block hash of header %s", h.Hash)
+	}
+	root, err := decodeHash(h.MerkleRoot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid merkle root of header %s", h.Hash)
+	}
+	bh := &bc.BlockHeader{
+		Version:        h.Version,
+		Time:           h.CreationTimestamp,
+		Nonce:          h.Nonce,
+		HashPrevBlock:  prev,
+		HashMerkleRoot: root,
+		Bits:           make([]byte, 4),
+	}
+	binary.BigEndian.PutUint32(bh.Bits, h.DifficultyTarget)
+
+	if hash := bh.Hash(); hash.String() != h.Hash {
+		return nil, errors.Wrapf(bc.ErrBlockHashMismatch, "service gave %s, header hashes to %s", h.Hash, hash)
+	}
+	return bh, nil
+}
+
+// decodeHash decodes a 32 byte hash from hex.
+func decodeHash(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 32 {
+		return nil, errors.Errorf("hash should be 32 bytes long, got %d", len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,337 @@
+package headersclient_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/headersclient"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+var _ bc.HeightIndexedBlockHeaderChain = (*headersclient.Client)(nil)
+
+// fakeService is a stand in for a headers service, holding the first headers of mainnet as
+// the longest chain, a stale header at height 150 and an orphan.
+type fakeService struct {
+	t       *testing.T
+	headers []*bc.BlockHeader
+	states  map[string]headerState
+	stale   *bc.BlockHeader
+	orphan  *bc.BlockHeader
+	badHash bool
+}
+
+type headerState struct {
+	header *bc.BlockHeader
+	state  headersclient.State
+	height uint64
+}
+
+func newFakeService(t *testing.T) *fakeService {
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+
+	s := &fakeService{t: t, states: map[string]headerState{}}
+	for i := 0; i < 200; i++ {
+		bh, err := bc.NewBlockHeaderFromBytes(b[i*80 : (i+1)*80])
+		require.NoError(t, err)
+		s.headers = append(s.headers, bh)
+		s.states[bh.Hash().String()] = headerState{header: bh, state: headersclient.StateLongestChain, height: uint64(i)}
+	}
+
+	stale := *s.headers[150]
+	stale.Nonce++
+	s.stale = &stale
+	s.states[stale.Hash().String()] = headerState{header: s.stale, state: headersclient.StateStale, height: 150}
+
+	orphan := *s.headers[10]
+	orphan.HashPrevBlock = s.headers[1].HashMerkleRoot
+	s.orphan = &orphan
+	s.states[orphan.Hash().String()] = headerState{header: s.orphan, state: headersclient.StateOrphan}
+
+	return s
+}
+
+func (s *fakeService) header(bh *bc.BlockHeader) map[string]interface{} {
+	hash := bh.Hash().String()
+	if s.badHash {
+		hash = s.headers[0].Hash().String()
+	}
+	return map[string]interface{}{
+		"hash":              hash,
+		"version":           bh.Version,
+		"prevBlockHash":     bh.HashPrevBlockStr(),
+		"merkleRoot":        bh.HashMerkleRootStr(),
+		"creationTimestamp": bh.Time,
+		"difficultyTarget":  binary.BigEndian.Uint32(bh.Bits),
+		"nonce":             bh.Nonce,
+		"work":              "4295032833",
+	}
+}
+
+func (s *fakeService) state(hs headerState) map[string]interface{} {
+	return map[string]interface{}{
+		"header":    s.header(hs.header),
+		"state":     hs.state,
+		"chainWork": "0",
+		"height":    hs.height,
+	}
+}
+
+func (s *fakeService) start(apiKey string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var resp interface{}
+		switch path := r.URL.Path; {
+		case strings.HasPrefix(path, "/api/v1/chain/header/state/"):
+			hs, ok := s.states[strings.TrimPrefix(path, "/api/v1/chain/header/state/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			resp = s.state(hs)
+		case path == "/api/v1/chain/header/byHeight":
+			height, err := strconv.Atoi(r.URL.Query().Get("height"))
+			require.NoError(s.t, err)
+			hh := []interface{}{}
+			if height < len(s.headers) {
+				hh = append(hh, s.header(s.headers[height]))
+			}
+			if height == 150 {
+				hh = append([]interface{}{s.header(s.stale)}, hh...)
+			}
+			resp = hh
+		case path == "/api/v1/chain/tip/longest":
+			tip := s.headers[len(s.headers)-1]
+			resp = s.state(s.states[tip.Hash().String()])
+		case path == "/api/v1/chain/merkleroot/verify" && r.Method == http.MethodPost:
+			var roots []headersclient.MerkleRoot
+			require.NoError(s.t, json.NewDecoder(r.Body).Decode(&roots))
+			result := headersclient.MerkleRootsConfirmation{
+				ConfirmationState: headersclient.ConfirmationConfirmed,
+				Confirmations:     []headersclient.MerkleRootConfirmation{},
+			}
+			for _, root := range roots {
+				c := headersclient.MerkleRootConfirmation{MerkleRoot: root.MerkleRoot, BlockHeight: root.BlockHeight}
+				switch {
+				case root.BlockHeight >= uint64(len(s.headers)):
+					c.Confirmation = headersclient.ConfirmationUnableToVerify
+				case s.headers[root.BlockHeight].HashMerkleRootStr() == root.MerkleRoot:
+					c.Confirmation = headersclient.ConfirmationConfirmed
+					c.BlockHash = s.headers[root.BlockHeight].Hash().String()
+				default:
+					c.Confirmation = headersclient.ConfirmationInvalid
+				}
+				if c.Confirmation != headersclient.ConfirmationConfirmed {
+					result.ConfirmationState = c.Confirmation
+				}
+				result.Confirmations = append(result.Confirmations, c)
+			}
+			resp = result
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		require.NoError(s.t, json.NewEncoder(w).Encode(resp))
+	}))
+	s.t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClient_BlockHeaderChain(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeService(t)
+	srv := s.start("secret")
+	c, err := headersclient.NewClient(srv.URL+"/", headersclient.WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	bh, err := c.BlockHeader(ctx, "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048")
+	require.NoError(t, err)
+	require.Equal(t, s.headers[1].String(), bh.String())
+
+	height, err := c.BlockHeight(ctx, s.headers[77].Hash().String())
+	require.NoError(t, err)
+	require.Equal(t, uint64(77), height)
+
+	height, hash, err := c.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(199), height)
+	require.Equal(t, s.headers[199].Hash().String(), hash)
+
+	bh, err = c.BlockHeaderByHeight(ctx, 42)
+	require.NoError(t, err)
+	require.Equal(t, s.headers[42].String(), bh.String())
+
+	// the longest chain header is picked from the headers at a height.
+	bh, err = c.BlockHeaderByHeight(ctx, 150)
+	require.NoError(t, err)
+	require.Equal(t, s.headers[150].String(), bh.String())
+
+	hs, err := c.HeaderState(ctx, s.stale.Hash().String())
+	require.NoError(t, err)
+	require.Equal(t, headersclient.StateStale, hs.State)
+	require.Equal(t, s.stale.String(), hs.Header.String())
+
+	confs, err := bc.Confirmations(ctx, c, s.headers[190].Hash().String())
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), confs)
+
+	tests := map[string]struct {
+		fn     func() error
+		expErr error
+	}{
+		"unknown header": {
+			fn: func() error {
+				_, err := c.BlockHeader(ctx, "0000000000000000000000000000000000000000000000000000000000000001")
+				return err
+			},
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"stale header": {
+			fn: func() error {
+				_, err := c.BlockHeader(ctx, s.stale.Hash().String())
+				return err
+			},
+			expErr: bc.ErrNotOnLongestChain,
+		},
+		"orphan header": {
+			fn: func() error {
+				_, err := c.BlockHeader(ctx, s.orphan.Hash().String())
+				return err
+			},
+			expErr: bc.ErrNotOnLongestChain,
+		},
+		"height of a stale header": {
+			fn: func() error {
+				_, err := c.BlockHeight(ctx, s.stale.Hash().String())
+				return err
+			},
+			expErr: bc.ErrNotOnLongestChain,
+		},
+		"height above the tip": {
+			fn: func() error {
+				_, err := c.BlockHeaderByHeight(ctx, 200)
+				return err
+			},
+			expErr: bc.ErrHeaderNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, test.fn(), test.expErr)
+		})
+	}
+}
+
+func TestClient_VerifyMerkleRoots(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeService(t)
+	srv := s.start("secret")
+	c, err := headersclient.NewClient(srv.URL, headersclient.WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		roots    []headersclient.MerkleRoot
+		expState string
+	}{
+		"confirmed": {
+			roots: []headersclient.MerkleRoot{
+				{MerkleRoot: s.headers[1].HashMerkleRootStr(), BlockHeight: 1},
+				{MerkleRoot: s.headers[100].HashMerkleRootStr(), BlockHeight: 100},
+			},
+			expState: headersclient.ConfirmationConfirmed,
+		},
+		"invalid": {
+			roots: []headersclient.MerkleRoot{
+				{MerkleRoot: s.headers[1].HashMerkleRootStr(), BlockHeight: 1},
+				{MerkleRoot: s.headers[1].HashMerkleRootStr(), BlockHeight: 2},
+			},
+			expState: headersclient.ConfirmationInvalid,
+		},
+		"above the tip": {
+			roots: []headersclient.MerkleRoot{
+				{MerkleRoot: s.headers[1].HashMerkleRootStr(), BlockHeight: 500},
+			},
+			expState: headersclient.ConfirmationUnableToVerify,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := c.VerifyMerkleRoots(ctx, test.roots...)
+			require.NoError(t, err)
+			require.Equal(t, test.expState, resp.ConfirmationState)
+			require.Len(t, resp.Confirmations, len(test.roots))
+		})
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeService(t)
+	srv := s.start("secret")
+
+	c, err := headersclient.NewClient(srv.URL, headersclient.WithAPIKey("wrong"))
+	require.NoError(t, err)
+	_, _, err = c.ChainTip(ctx)
+	require.ErrorIs(t, err, headersclient.ErrUnauthorised)
+
+	c, err = headersclient.NewClient(srv.URL, headersclient.WithAPIKey("secret"))
+	require.NoError(t, err)
+	s.badHash = true
+	_, err = c.BlockHeader(ctx, s.headers[5].Hash().String())
+	require.ErrorIs(t, err, bc.ErrBlockHashMismatch)
+	s.badHash = false
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	c, err = headersclient.NewClient(slow.URL, headersclient.WithTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+	_, _, err = c.ChainTip(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = headersclient.NewClient("not a url")
+	require.Error(t, err)
+}
+
+func TestClient_VerifyMerkleProof(t *testing.T) {
+	ctx := context.Background()
+	s := newFakeService(t)
+	srv := s.start("secret")
+	c, err := headersclient.NewClient(srv.URL, headersclient.WithAPIKey("secret"))
+	require.NoError(t, err)
+
+	// block 1 holds only its coinbase, so its txid is the merkle root.
+	v, err := spv.NewMerkleProofVerifier(c)
+	require.NoError(t, err)
+	valid, _, err := v.VerifyMerkleProofJSON(ctx, &bc.MerkleProof{
+		TxOrID: s.headers[1].HashMerkleRootStr(),
+		Target: s.headers[1].Hash().String(),
+	})
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	_, _, err = v.VerifyMerkleProofJSON(ctx, &bc.MerkleProof{
+		TxOrID: s.stale.HashMerkleRootStr(),
+		Target: s.stale.Hash().String(),
+	})
+	require.ErrorIs(t, err, bc.ErrNotOnLongestChain)
+}
@@ -0,0 +1,119 @@
+package bc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxFutureBlockTime is how far ahead of the clock the time of a block header can be.
+const MaxFutureBlockTime = 2 * time.Hour
+
+var (
+	// ErrTimeTooOld is returned when a header time is not after the median time past of the
+	// headers before it.
+	ErrTimeTooOld = errors.New("header time is not after the median time past")
+
+	// ErrTimeTooNew is returned when a header time is more than MaxFutureBlockTime ahead of the clock.
+	ErrTimeTooNew = errors.New("header time is too far in the future")
+)
+
+// MedianTimePast returns the median time of the block with the hash provided and the 10 blocks
+// before it, which is used in place of the block time for time based rules as it cannot be
+// manipulated by a single miner.
+//
+// Fewer blocks are used near the genesis block, or when bhc does not hold headers that far back.
+func MedianTimePast(ctx context.Context, bhc BlockHeaderChain, blockHash string) (time.Time, error) {
+	bh, err := bhc.BlockHeader(ctx, blockHash)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	times := make([]int64, 0, medianTimeBlocks)
+	times = append(times, int64(bh.Time))
+	for len(times) < medianTimeBlocks && !bytes.Equal(bh.HashPrevBlock, make([]byte, len(bh.HashPrevBlock))) {
+		bh, err = bhc.BlockHeader(ctx, bh.HashPrevBlockStr())
+		if errors.Is(err, ErrHeaderNotFound) {
+			// the chain may not hold blocks this far back.
+			break
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		times = append(times, int64(bh.Time))
+	}
+
+	return time.Unix(median(times), 0), nil
+}
+
+// MedianTimePastAtHeight returns the median time of the block at height on the longest chain
+// and the 10 blocks before it.
+func MedianTimePastAtHeight(ctx context.Context, chain HeightIndexedBlockHeaderChain, height uint64) (time.Time, error) {
+	times := make([]int64, 0, medianTimeBlocks)
+	for i := uint64(0); i < medianTimeBlocks && i <= height; i++ {
+		bh, err := chain.BlockHeaderByHeight(ctx, height-i)
+		if errors.Is(err, ErrHeaderNotFound) && i > 0 {
+			// the chain may not hold blocks this far back.
+			break
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		times = append(times, int64(bh.Time))
+	}
+
+	return time.Unix(median(times), 0), nil
+}
+
+// median returns the median of times, the upper one when there is an even number.
+func median(times []int64) int64 {
+	sort.Slice(times, func(i, j int) bool {
+		return times[i] < times[j]
+	})
+	return times[len(times)/2]
+}
+
+type headerTimeOptions struct {
+	now func() time.Time
+}
+
+// HeaderTimeOpt defines a functional option that is used to modify the behaviour of CheckHeaderTime.
+type HeaderTimeOpt func(*headerTimeOptions)
+
+// WithClock sets the clock header times are checked against, it defaults to time.Now.
+func WithClock(now func() time.Time) HeaderTimeOpt {
+	return func(o *headerTimeOptions) {
+		o.now = now
+	}
+}
+
+// CheckHeaderTime checks the time of a header against the consensus rules. It must be after the
+// median time past of its parent, which is looked up in bhc, and no more than MaxFutureBlockTime
+// ahead of the clock. ErrTimeTooOld or ErrTimeTooNew are returned when it is not.
+//
+// The median time past is not checked for a header without a parent, such as a genesis block.
+func CheckHeaderTime(ctx context.Context, bhc BlockHeaderChain, bh *BlockHeader, opts ...HeaderTimeOpt) error {
+	o := &headerTimeOptions{now: time.Now}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if !bytes.Equal(bh.HashPrevBlock, make([]byte, len(bh.HashPrevBlock))) {
+		mtp, err := MedianTimePast(ctx, bhc, bh.HashPrevBlockStr())
+		if err != nil {
+			return err
+		}
+		if int64(bh.Time) <= mtp.Unix() {
+			return fmt.Errorf("%w: header time %d, median time past %d", ErrTimeTooOld, bh.Time, mtp.Unix())
+		}
+	}
+
+	if limit := o.now().Add(MaxFutureBlockTime).Unix(); int64(bh.Time) > limit {
+		return fmt.Errorf("%w: header time %d, limit %d", ErrTimeTooNew, bh.Time, limit)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package bc_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/libsv/go-bk/crypto"
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestMedianTimePast(t *testing.T) {
+	ctx := context.Background()
+	chain := newIndexedChain(t, 4033)
+
+	tests := map[string]struct {
+		height uint64
+		exp    int64
+	}{
+		"genesis": {
+			height: 0,
+			exp:    1231006505,
+		},
+		"fewer than 11 blocks": {
+			height: 5,
+			exp:    1231470173,
+		},
+		"block 100": {
+			height: 100,
+			exp:    1231656204,
+		},
+		"block 4032": {
+			height: 4032,
+			exp:    1234462546,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hash := hex.EncodeToString(bt.ReverseBytes(crypto.Sha256d(chain.headers[test.height].Bytes())))
+			mtp, err := bc.MedianTimePast(ctx, chain, hash)
+			require.NoError(t, err)
+			require.Equal(t, test.exp, mtp.Unix())
+
+			mtp, err = bc.MedianTimePastAtHeight(ctx, chain, test.height)
+			require.NoError(t, err)
+			require.Equal(t, test.exp, mtp.Unix())
+		})
+	}
+
+	_, err := bc.MedianTimePast(ctx, chain, "0000000000000000000000000000000000000000000000000000000000000001")
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+	_, err = bc.MedianTimePastAtHeight(ctx, chain, 5000)
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+}
+
+func TestMedianTimePast_PartialChain(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 101)
+
+	// a chain holding only the last 3 headers uses their times.
+	chain := indexedChainOf(hh)
+	for hash, height := range chain.heights {
+		if height < 98 {
+			delete(chain.heights, hash)
+		}
+	}
+
+	mtp, err := bc.MedianTimePast(ctx, chain, block100)
+	require.NoError(t, err)
+	require.Equal(t, int64(hh[99].Time), mtp.Unix())
+}
+
+func TestCheckHeaderTime(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 102)
+	chain := indexedChainOf(hh[:101])
+	clock := bc.WithClock(func() time.Time {
+		return time.Unix(int64(hh[101].Time), 0)
+	})
+	withTime := func(bh *bc.BlockHeader, t uint32) *bc.BlockHeader {
+		c := *bh
+		c.Time = t
+		return &c
+	}
+	orphan := withTime(hh[101], hh[101].Time)
+	orphan.HashPrevBlock = hh[101].HashMerkleRoot
+
+	tests := map[string]struct {
+		header *bc.BlockHeader
+		opts   []bc.HeaderTimeOpt
+		expErr error
+	}{
+		"next block passes": {
+			header: hh[101],
+			opts:   []bc.HeaderTimeOpt{clock},
+		},
+		"genesis passes": {
+			header: hh[0],
+			opts:   []bc.HeaderTimeOpt{clock},
+		},
+		"time after median time past passes": {
+			header: withTime(hh[101], 1231656205),
+			opts:   []bc.HeaderTimeOpt{clock},
+		},
+		"time at median time past fails": {
+			header: withTime(hh[101], 1231656204),
+			opts:   []bc.HeaderTimeOpt{clock},
+			expErr: bc.ErrTimeTooOld,
+		},
+		"time 2 hours ahead of the clock passes": {
+			header: withTime(hh[101], hh[101].Time+7200),
+			opts:   []bc.HeaderTimeOpt{clock},
+		},
+		"time more than 2 hours ahead of the clock fails": {
+			header: withTime(hh[101], hh[101].Time+7201),
+			opts:   []bc.HeaderTimeOpt{clock},
+			expErr: bc.ErrTimeTooNew,
+		},
+		"time in 2009 passes against the real clock": {
+			header: hh[101],
+		},
+		"unknown parent fails": {
+			header: orphan,
+			opts:   []bc.HeaderTimeOpt{clock},
+			expErr: bc.ErrHeaderNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := bc.CheckHeaderTime(ctx, chain, test.header, test.opts...)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -12,28 +12,38 @@ type MerklePath struct {
 	Path  []string `json:"path"`
 }
 
-// NewMerklePathFromBytes creates a new MerklePath from a byte slice.
+// NewMerklePathFromBytes creates a new MerklePath from a byte slice. An error is returned if
+// the bytes do not hold exactly one MerklePath.
 func NewMerklePathFromBytes(bytes []byte) (*MerklePath, error) {
 	mp := &MerklePath{}
-	mp.Path = make([]string, 0)
+	d := NewDecoder(bytes, nil)
 
 	// start paring transaction index.
-	var offset int
-	index, size := bt.NewVarIntFromBytes(bytes[offset:])
-	offset += size
-	mp.Index = uint64(index)
+	index, err := d.VarInt("merkle path index")
+	if err != nil {
+		return nil, err
+	}
+	mp.Index = index
 
 	// next value in the byte array is nLeaves (number of leaves in merkle path).
-	nLeaves, size := bt.NewVarIntFromBytes(bytes[offset:])
-	offset += size
+	nLeaves, err := d.Count("merkle path leaf count", 32)
+	if err != nil {
+		return nil, err
+	}
+	mp.Path = make([]string, 0, nLeaves)
 
 	// parse each leaf from the binary path
-	for k := 0; k < int(nLeaves); k++ {
-		leaf := bytes[offset : offset+32]
+	for k := uint64(0); k < nLeaves; k++ {
+		leaf, err := d.Bytes("merkle path leaf", 32)
+		if err != nil {
+			return nil, err
+		}
 		mp.Path = append(mp.Path, StringFromBytesReverse(leaf))
-		offset += 32
 	}
 
+	if err := d.End(); err != nil {
+		return nil, err
+	}
 	return mp, nil
 }
 
@@ -0,0 +1,296 @@
+package bc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+)
+
+// ErrBlockHashMismatch is returned when the hash given in the node's JSON for a block does
+// not match the hash of its header.
+var ErrBlockHashMismatch = errors.New("block hash does not match the block header")
+
+// NodeBlockHeader is a block header with the chain information the node returns for it
+// from the verbose getblockheader RPC. It marshals to and from the node's JSON.
+//
+// Confirmations is -1 for a header which is not on the longest chain, and NextBlockHash
+// is empty for the tip.
+type NodeBlockHeader struct {
+	Header        *BlockHeader
+	Height        uint64
+	Confirmations int64
+	MedianTime    uint32
+	ChainWork     ChainWork
+	NumTx         uint64
+	NextBlockHash string
+}
+
+type nodeHeaderJSON struct {
+	Hash              string      `json:"hash"`
+	Confirmations     int64       `json:"confirmations"`
+	Height            uint64      `json:"height"`
+	Version           uint32      `json:"version"`
+	VersionHex        string      `json:"versionHex"`
+	MerkleRoot        string      `json:"merkleroot"`
+	NumTx             *uint64     `json:"num_tx,omitempty"`
+	NTx               *uint64     `json:"nTx,omitempty"`
+	Time              uint32      `json:"time"`
+	MedianTime        uint32      `json:"mediantime"`
+	Nonce             uint32      `json:"nonce"`
+	Bits              string      `json:"bits"`
+	Difficulty        json.Number `json:"difficulty"`
+	ChainWork         string      `json:"chainwork"`
+	PreviousBlockHash string      `json:"previousblockhash,omitempty"`
+	NextBlockHash     string      `json:"nextblockhash,omitempty"`
+}
+
+// MarshalJSON marshals the header into the JSON of the node's verbose getblockheader RPC.
+func (h *NodeBlockHeader) MarshalJSON() ([]byte, error) {
+	j, err := h.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON unmarshals the JSON of the node's verbose getblockheader RPC into the header.
+// The number of transactions is read from either num_tx or nTx. ErrBlockHashMismatch is
+// returned if the hash does not match the header.
+func (h *NodeBlockHeader) UnmarshalJSON(b []byte) error {
+	var j nodeHeaderJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	return h.fromJSON(&j)
+}
+
+func (h *NodeBlockHeader) toJSON() (*nodeHeaderJSON, error) {
+	bh := h.Header
+	if bh == nil {
+		return nil, errors.New("node block header has no header")
+	}
+	target, err := NewTargetFromBits(bh.Bits)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &nodeHeaderJSON{
+		Hash:          bh.Hash().String(),
+		Confirmations: h.Confirmations,
+		Height:        h.Height,
+		Version:       bh.Version,
+		VersionHex:    fmt.Sprintf("%08x", bh.Version),
+		MerkleRoot:    bh.HashMerkleRootStr(),
+		NumTx:         &h.NumTx,
+		Time:          bh.Time,
+		MedianTime:    h.MedianTime,
+		Nonce:         bh.Nonce,
+		Bits:          bh.BitsStr(),
+		Difficulty:    json.Number(strconv.FormatFloat(target.Difficulty(), 'f', -1, 64)),
+		ChainWork:     h.ChainWork.String(),
+		NextBlockHash: h.NextBlockHash,
+	}
+	if prev := bh.PrevBlockHash(); prev != (chainhash.Hash{}) {
+		j.PreviousBlockHash = bh.HashPrevBlockStr()
+	}
+
+	return j, nil
+}
+
+func (h *NodeBlockHeader) fromJSON(j *nodeHeaderJSON) error {
+	bh := &BlockHeader{
+		Version:       j.Version,
+		Time:          j.Time,
+		Nonce:         j.Nonce,
+		HashPrevBlock: make([]byte, 32),
+	}
+	var err error
+	if j.PreviousBlockHash != "" {
+		if bh.HashPrevBlock, err = decodeHex(j.PreviousBlockHash, 32, "previousblockhash"); err != nil {
+			return err
+		}
+	}
+	if bh.HashMerkleRoot, err = decodeHex(j.MerkleRoot, 32, "merkleroot"); err != nil {
+		return err
+	}
+	if bh.Bits, err = decodeHex(j.Bits, 4, "bits"); err != nil {
+		return err
+	}
+	if j.Hash != "" {
+		if hash := bh.Hash(); hash.String() != j.Hash {
+			return fmt.Errorf("%w: %s, header hashes to %s", ErrBlockHashMismatch, j.Hash, hash)
+		}
+	}
+
+	var chainWork ChainWork
+	if j.ChainWork != "" {
+		if chainWork, err = NewChainWorkFromString(j.ChainWork); err != nil {
+			return err
+		}
+	}
+
+	*h = NodeBlockHeader{
+		Header:        bh,
+		Height:        j.Height,
+		Confirmations: j.Confirmations,
+		MedianTime:    j.MedianTime,
+		ChainWork:     chainWork,
+		NextBlockHash: j.NextBlockHash,
+	}
+	switch {
+	case j.NumTx != nil:
+		h.NumTx = *j.NumTx
+	case j.NTx != nil:
+		h.NumTx = *j.NTx
+	}
+
+	return nil
+}
+
+// NodeBlock is a block with the chain information the node returns for it from the getblock
+// RPC with verbosity 1 or 2. It marshals to and from the node's JSON.
+//
+// TxIDs holds the ids of the transactions in the block, and Txs the transactions themselves
+// when they are known, as they are with verbosity 2. A block with Txs marshals to the JSON
+// of verbosity 2, giving the txid, hash, version, size, locktime and hex of each transaction
+// but not the decoded inputs and outputs, otherwise it marshals to the JSON of verbosity 1.
+type NodeBlock struct {
+	NodeBlockHeader
+	Size  uint64
+	TxIDs []string
+	Txs   []*bt.Tx
+}
+
+// NewNodeBlock returns the node JSON form of a block, without chain information.
+func NewNodeBlock(b *Block) *NodeBlock {
+	nb := &NodeBlock{
+		NodeBlockHeader: NodeBlockHeader{
+			Header: b.BlockHeader,
+			NumTx:  uint64(len(b.Txs)),
+		},
+		Size:  uint64(len(b.Bytes())),
+		TxIDs: make([]string, len(b.Txs)),
+		Txs:   b.Txs,
+	}
+	for i, tx := range b.Txs {
+		nb.TxIDs[i] = tx.TxID()
+	}
+	return nb
+}
+
+// Block returns the block. The transactions must be known, so the block must have been read
+// from the JSON of verbosity 2.
+func (b *NodeBlock) Block() (*Block, error) {
+	if b.Txs == nil && b.NumTx != 0 {
+		return nil, errors.New("block has no transactions, use getblock verbosity 2")
+	}
+	return &Block{BlockHeader: b.Header, Txs: b.Txs}, nil
+}
+
+type nodeBlockJSON struct {
+	*nodeHeaderJSON
+	Size uint64          `json:"size"`
+	Tx   json.RawMessage `json:"tx"`
+}
+
+type nodeTxJSON struct {
+	TxID     string `json:"txid"`
+	Hash     string `json:"hash"`
+	Version  uint32 `json:"version"`
+	Size     int    `json:"size"`
+	LockTime uint32 `json:"locktime"`
+	Hex      string `json:"hex"`
+}
+
+// MarshalJSON marshals the block into the JSON of the node's getblock RPC.
+func (b *NodeBlock) MarshalJSON() ([]byte, error) {
+	j, err := b.toJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	var tx interface{} = []string{}
+	if b.TxIDs != nil {
+		tx = b.TxIDs
+	}
+	if b.Txs != nil {
+		txs := make([]nodeTxJSON, len(b.Txs))
+		for i, t := range b.Txs {
+			txs[i] = nodeTxJSON{
+				TxID:     t.TxID(),
+				Hash:     t.TxID(),
+				Version:  t.Version,
+				Size:     t.Size(),
+				LockTime: t.LockTime,
+				Hex:      t.String(),
+			}
+		}
+		tx = txs
+	}
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(nodeBlockJSON{nodeHeaderJSON: j, Size: b.Size, Tx: raw})
+}
+
+// UnmarshalJSON unmarshals the JSON of the node's getblock RPC, with verbosity 1 or 2, into
+// the block. With verbosity 2 each transaction is read from its hex, and must match its txid.
+func (b *NodeBlock) UnmarshalJSON(data []byte) error {
+	j := nodeBlockJSON{nodeHeaderJSON: &nodeHeaderJSON{}}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+
+	var nb NodeBlock
+	if err := nb.fromJSON(j.nodeHeaderJSON); err != nil {
+		return err
+	}
+	nb.Size = j.Size
+
+	if len(j.Tx) > 0 && j.Tx[0] == '[' {
+		var txids []string
+		if err := json.Unmarshal(j.Tx, &txids); err == nil {
+			nb.TxIDs = txids
+		} else {
+			var txs []nodeTxJSON
+			if err := json.Unmarshal(j.Tx, &txs); err != nil {
+				return err
+			}
+			nb.TxIDs = make([]string, len(txs))
+			nb.Txs = make([]*bt.Tx, len(txs))
+			for i, t := range txs {
+				tx, err := bt.NewTxFromString(t.Hex)
+				if err != nil {
+					return fmt.Errorf("failed to read transaction %s: %w", t.TxID, err)
+				}
+				if t.TxID != "" && tx.TxID() != t.TxID {
+					return fmt.Errorf("transaction hex does not match txid %s", t.TxID)
+				}
+				nb.TxIDs[i] = tx.TxID()
+				nb.Txs[i] = tx
+			}
+		}
+	}
+
+	*b = nb
+	return nil
+}
+
+// decodeHex decodes a hex field of the node's JSON which must be size bytes long.
+func decodeHex(s string, size int, field string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if len(b) != size {
+		return nil, fmt.Errorf("%s should be %d bytes long, got %d", field, size, len(b))
+	}
+	return b, nil
+}
@@ -0,0 +1,175 @@
+package bc_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+const (
+	genesisCoinbase = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+	block1HeaderJSON = `{
+		"hash": "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+		"confirmations": 750000,
+		"height": 1,
+		"version": 1,
+		"versionHex": "00000001",
+		"merkleroot": "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098",
+		"num_tx": 1,
+		"time": 1231469665,
+		"mediantime": 1231469665,
+		"nonce": 2573394689,
+		"bits": "1d00ffff",
+		"difficulty": 1,
+		"chainwork": "0000000000000000000000000000000000000000000000000000000200020002",
+		"previousblockhash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		"nextblockhash": "000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd"
+	}`
+
+	genesisBlockJSON = `{
+		"hash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		"confirmations": 750001,
+		"size": 285,
+		"height": 0,
+		"version": 1,
+		"versionHex": "00000001",
+		"merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		"num_tx": 1,
+		"tx": ["4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"],
+		"time": 1231006505,
+		"mediantime": 1231006505,
+		"nonce": 2083236893,
+		"bits": "1d00ffff",
+		"difficulty": 1,
+		"chainwork": "0000000000000000000000000000000000000000000000000000000100010001",
+		"nextblockhash": "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048"
+	}`
+)
+
+func TestNodeBlockHeader_JSON(t *testing.T) {
+	var h bc.NodeBlockHeader
+	require.NoError(t, json.Unmarshal([]byte(block1HeaderJSON), &h))
+
+	require.Equal(t, "010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e36299", h.Header.String())
+	require.Equal(t, uint64(1), h.Height)
+	require.Equal(t, int64(750000), h.Confirmations)
+	require.Equal(t, uint32(1231469665), h.MedianTime)
+	require.Equal(t, "0000000000000000000000000000000000000000000000000000000200020002", h.ChainWork.String())
+	require.Equal(t, uint64(1), h.NumTx)
+	require.Equal(t, "000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd", h.NextBlockHash)
+
+	// the header marshals back to the node's JSON.
+	b, err := json.Marshal(&h)
+	require.NoError(t, err)
+	require.JSONEq(t, block1HeaderJSON, string(b))
+}
+
+func TestNodeBlockHeader_UnmarshalJSON(t *testing.T) {
+	tests := map[string]struct {
+		json     string
+		expNumTx uint64
+		expErr   error
+	}{
+		"genesis without a previous block": {
+			json:     `{"hash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", "version": 1, "merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "time": 1231006505, "nonce": 2083236893, "bits": "1d00ffff", "num_tx": 1}`,
+			expNumTx: 1,
+		},
+		"nTx": {
+			json:     `{"version": 1, "merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "time": 1231006505, "nonce": 2083236893, "bits": "1d00ffff", "nTx": 3}`,
+			expNumTx: 3,
+		},
+		"hash mismatch": {
+			json:   `{"hash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f", "version": 1, "merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "time": 1231006505, "nonce": 1, "bits": "1d00ffff"}`,
+			expErr: bc.ErrBlockHashMismatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var h bc.NodeBlockHeader
+			err := json.Unmarshal([]byte(test.json), &h)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expNumTx, h.NumTx)
+			require.True(t, h.Header.Valid())
+		})
+	}
+
+	invalid := map[string]string{
+		"short merkle root":  `{"merkleroot": "4a5e", "bits": "1d00ffff"}`,
+		"invalid bits":       `{"merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "bits": "zz00ffff"}`,
+		"invalid chain work": `{"merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "bits": "1d00ffff", "chainwork": "xyz"}`,
+	}
+	for name, j := range invalid {
+		t.Run(name, func(t *testing.T) {
+			var h bc.NodeBlockHeader
+			require.Error(t, json.Unmarshal([]byte(j), &h))
+		})
+	}
+}
+
+func TestNodeBlock_JSON(t *testing.T) {
+	// verbosity 1 gives the txids.
+	var nb bc.NodeBlock
+	require.NoError(t, json.Unmarshal([]byte(genesisBlockJSON), &nb))
+	require.Equal(t, uint64(285), nb.Size)
+	require.Equal(t, []string{"4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"}, nb.TxIDs)
+	require.Nil(t, nb.Txs)
+	_, err := nb.Block()
+	require.Error(t, err)
+
+	b, err := json.Marshal(&nb)
+	require.NoError(t, err)
+	require.JSONEq(t, genesisBlockJSON, string(b))
+
+	// verbosity 2 gives the transactions.
+	verbose := `{
+		"hash": "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		"size": 285,
+		"height": 0,
+		"version": 1,
+		"merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		"num_tx": 1,
+		"tx": [{
+			"txid": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+			"version": 1,
+			"vin": [{"coinbase": "04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73", "sequence": 4294967295}],
+			"vout": [{"value": 50, "n": 0}],
+			"hex": "` + genesisCoinbase + `"
+		}],
+		"time": 1231006505,
+		"nonce": 2083236893,
+		"bits": "1d00ffff"
+	}`
+	nb = bc.NodeBlock{}
+	require.NoError(t, json.Unmarshal([]byte(verbose), &nb))
+	require.Len(t, nb.Txs, 1)
+	require.Equal(t, nb.TxIDs, []string{nb.Txs[0].TxID()})
+
+	block, err := nb.Block()
+	require.NoError(t, err)
+	require.Equal(t, bc.MainNet.GenesisHeader.String(), block.BlockHeader.String())
+	require.Equal(t, genesisCoinbase, block.Txs[0].String())
+
+	// a block marshals to verbosity 2 and back.
+	b, err = json.Marshal(bc.NewNodeBlock(block))
+	require.NoError(t, err)
+	var again bc.NodeBlock
+	require.NoError(t, json.Unmarshal(b, &again))
+	require.Equal(t, uint64(285), again.Size)
+	require.Equal(t, uint64(1), again.NumTx)
+	require.Equal(t, genesisCoinbase, again.Txs[0].String())
+
+	// the hex of a transaction must match its txid.
+	var mismatch bc.NodeBlock
+	err = json.Unmarshal([]byte(`{"merkleroot": "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", "bits": "1d00ffff",
+		"tx": [{"txid": "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", "hex": "`+genesisCoinbase+`"}]}`), &mismatch)
+	require.Error(t, err)
+}
@@ -0,0 +1,292 @@
+package bc
+
+import (
+	"math"
+	"math/big"
+	"time"
+)
+
+// baseSubsidy is the block subsidy, in satoshis, before the first halving.
+const baseSubsidy = 50 * 1e8
+
+var (
+	// mainPowLimit is the highest proof of work value a block can have on the main network, 2^224 - 1.
+	mainPowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224), big.NewInt(1))
+
+	// regressionPowLimit is the highest proof of work value a block can have on the regression
+	// test network, 2^255 - 1.
+	regressionPowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(1))
+)
+
+// Checkpoint is the hash of a known good block at a height.
+type Checkpoint struct {
+	Height uint64 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// NetworkParams defines the consensus rules of a bitcoin network. They are passed to the
+// difficulty, subsidy and header validation functions to select the network's rules.
+type NetworkParams struct {
+	// Name is a human readable name for the network.
+	Name string
+
+	// GenesisHeader is the header of the first block of the network.
+	GenesisHeader *BlockHeader
+
+	// PowLimit is the highest proof of work value a block can have, and PowLimitBits is
+	// the same value in compact form.
+	PowLimit     *big.Int
+	PowLimitBits uint32
+
+	// UAHFHeight is the height of the block the emergency difficulty adjustment activates
+	// after, it is used until the block after DAAHeight when the CW-144 difficulty
+	// adjustment takes over.
+	UAHFHeight uint64
+	DAAHeight  uint64
+
+	// TargetTimespan is the time that should elapse between legacy difficulty retargets,
+	// and TargetTimePerBlock the time that should elapse between blocks.
+	TargetTimespan     time.Duration
+	TargetTimePerBlock time.Duration
+
+	// RetargetAdjustmentFactor limits how far the difficulty can change at a legacy
+	// difficulty retarget.
+	RetargetAdjustmentFactor int64
+
+	// ReduceMinDifficulty allows a block to be mined at the minimum difficulty when
+	// MinDiffReductionTime has elapsed without a block being found.
+	ReduceMinDifficulty  bool
+	MinDiffReductionTime time.Duration
+
+	// NoDifficultyAdjustment keeps the difficulty the same for every block.
+	NoDifficultyAdjustment bool
+
+	// SubsidyHalvingInterval is the number of blocks between each halving of the block subsidy.
+	SubsidyHalvingInterval uint64
+
+	// GenesisActivationHeight is the height the Genesis upgrade activated at, restoring the
+	// original bitcoin protocol and removing the default limits.
+	GenesisActivationHeight uint64
+
+	// Checkpoints are known good blocks, in height order.
+	Checkpoints []Checkpoint
+
+	// RuleChangeActivationThreshold is the number of blocks in a MinerConfirmationWindow which
+	// must signal for a version bits deployment for it to lock in.
+	RuleChangeActivationThreshold uint64
+	MinerConfirmationWindow       uint64
+
+	// Deployments are the version bits deployments defined for the network.
+	Deployments []Deployment
+}
+
+// Deployment returns the version bits deployment with the name provided, or nil if the
+// network does not define it.
+func (p *NetworkParams) Deployment(name string) *Deployment {
+	for i := range p.Deployments {
+		if p.Deployments[i].Name == name {
+			return &p.Deployments[i]
+		}
+	}
+	return nil
+}
+
+// GenesisHash returns the hash of the genesis block of the network.
+func (p *NetworkParams) GenesisHash() string {
+	return p.GenesisHeader.Hash().String()
+}
+
+// BlockSubsidy returns the number of satoshis a miner can claim for finding the block at height,
+// on top of the transaction fees.
+func (p *NetworkParams) BlockSubsidy(height uint64) uint64 {
+	halvings := height / p.SubsidyHalvingInterval
+	if halvings >= 64 {
+		return 0
+	}
+	return baseSubsidy >> halvings
+}
+
+// IsGenesisActive returns true if the Genesis upgrade rules apply to the block at height.
+func (p *NetworkParams) IsGenesisActive(height uint64) bool {
+	return height >= p.GenesisActivationHeight
+}
+
+// LastCheckpoint returns the highest checkpoint of the network, or nil if it has none.
+func (p *NetworkParams) LastCheckpoint() *Checkpoint {
+	if len(p.Checkpoints) == 0 {
+		return nil
+	}
+	return &p.Checkpoints[len(p.Checkpoints)-1]
+}
+
+// DifficultyToHashrate returns the estimated hashrate, in hashes per second, of the network
+// when blocks are being found every targetSeconds at the difficulty provided.
+//
+// Difficulty here is relative to the easiest target of the network, so a block of difficulty 1
+// takes 2^256 / PowLimitBits hashes on average to find.
+func (p *NetworkParams) DifficultyToHashrate(difficulty, targetSeconds float64) float64 {
+	target, err := NewTargetFromCompact(p.PowLimitBits)
+	if err != nil {
+		return 0
+	}
+	t, _ := new(big.Float).SetInt(target.Int()).Float64()
+
+	return difficulty * (math.Pow(2, 256) / t) / targetSeconds
+}
+
+// genesisMerkleRoot is the merkle root of the genesis block of every network, they share the
+// same coinbase transaction.
+var genesisMerkleRoot = []byte{
+	0x4a, 0x5e, 0x1e, 0x4b, 0xaa, 0xb8, 0x9f, 0x3a, 0x32, 0x51, 0x8a, 0x88, 0xc3, 0x1b, 0xc8, 0x7f,
+	0x61, 0x8f, 0x76, 0x67, 0x3e, 0x2c, 0xc7, 0x7a, 0xb2, 0x12, 0x7b, 0x7a, 0xfd, 0xed, 0xa3, 0x3b,
+}
+
+// genesisHeader returns a genesis block header with the time, bits and nonce provided.
+func genesisHeader(time, bits, nonce uint32) *BlockHeader {
+	b := make([]byte, 4)
+	b[0], b[1], b[2], b[3] = byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits)
+	return &BlockHeader{
+		Version:        1,
+		Time:           time,
+		Nonce:          nonce,
+		HashPrevBlock:  make([]byte, 32),
+		HashMerkleRoot: genesisMerkleRoot,
+		Bits:           b,
+	}
+}
+
+// MainNet defines the consensus rules of the main network.
+var MainNet = &NetworkParams{
+	Name:                          "mainnet",
+	GenesisHeader:                 genesisHeader(1231006505, 0x1d00ffff, 2083236893),
+	PowLimit:                      mainPowLimit,
+	PowLimitBits:                  0x1d00ffff,
+	UAHFHeight:                    478558,
+	DAAHeight:                     504031,
+	TargetTimespan:                time.Hour * 24 * 14,
+	TargetTimePerBlock:            time.Minute * 10,
+	RetargetAdjustmentFactor:      4,
+	SubsidyHalvingInterval:        210000,
+	GenesisActivationHeight:       620538,
+	RuleChangeActivationThreshold: 1916,
+	MinerConfirmationWindow:       2016,
+	Deployments: []Deployment{
+		{Name: DeploymentTestDummy, Bit: 28, StartTime: time.Unix(1199145601, 0), Timeout: time.Unix(1230767999, 0)},
+		{Name: DeploymentCSV, Bit: 0, StartTime: time.Unix(1462060800, 0), Timeout: time.Unix(1493596800, 0)},
+	},
+	Checkpoints: []Checkpoint{
+		{Height: 11111, Hash: "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d"},
+		{Height: 33333, Hash: "000000002dd5588a74784eaa7ab0507a18ad16a236e7b1ce69f00d7ddfb5d0a6"},
+		{Height: 74000, Hash: "0000000000573993a3c9e41ce34471c079dcf5f52a0e824a81e7f953b8661a20"},
+		{Height: 105000, Hash: "00000000000291ce28027faea320c8d2b054b2e0fe44a773f3eefb151d6bdc97"},
+		{Height: 134444, Hash: "00000000000005b12ffd4cd315cd34ffd4a594f430ac814c91184a0d42d2b0fe"},
+		{Height: 168000, Hash: "000000000000099e61ea72015e79632f216fe6cb33d7899acb35b75c8303b763"},
+		{Height: 193000, Hash: "000000000000059f452a5f7340de6682a977387c17010ff6e6c3bd83ca8b1317"},
+		{Height: 210000, Hash: "000000000000048b95347e83192f69cf0366076336c639f9b7228e9ba171342e"},
+		{Height: 216116, Hash: "00000000000001b4f4b433e81ee46494af945cf96014816a4e2370f11b23df4e"},
+		{Height: 225430, Hash: "00000000000001c108384350f74090433e7fcf79a606b8e797f065b130575932"},
+		{Height: 250000, Hash: "000000000000003887df1f29024b06fc2200b55f8af8f35453d7be294df2d214"},
+		{Height: 267300, Hash: "000000000000000a83fbd660e918f218bf37edd92b748ad940483c7c116179ac"},
+		{Height: 279000, Hash: "0000000000000001ae8c72a0b0c301f67e3afca10e819efa9041e458e9bd7e40"},
+		{Height: 300255, Hash: "0000000000000000162804527c6e9b9f0563a280525f9d08c12041def0a0f3b2"},
+		{Height: 319400, Hash: "000000000000000021c6052e9becade189495d1c539aa37c58917305fd15f13b"},
+		{Height: 343185, Hash: "0000000000000000072b8bf361d01a6ba7d445dd024203fafc78768ed4368554"},
+		{Height: 352940, Hash: "000000000000000010755df42dba556bb72be6a32f3ce0b6941ce4430152c9ff"},
+		{Height: 382320, Hash: "00000000000000000a8dc6ed5b133d0eb2fd6af56203e4159789b092defd8ab2"},
+		{Height: 400000, Hash: "000000000000000004ec466ce4732fe6f1ed1cddc2ed4b328fff5224276e3f6f"},
+		{Height: 430000, Hash: "000000000000000001868b2bb3a285f3cc6b33ea234eb70facf4dcdf22186b87"},
+		{Height: 470000, Hash: "0000000000000000006c539c722e280a0769abd510af0073430159d71e6d7589"},
+		{Height: 510000, Hash: "00000000000000000367922b6457e21d591ef86b360d78a598b14c2f1f6b0e04"},
+		{Height: 552979, Hash: "0000000000000000015648768ac1b788a83187d706f858919fcc5c096b76fbf2"},
+		{Height: 556767, Hash: "000000000000000001d956714215d96ffc00e0afda4cd0a96c96f8d802b1662b"},
+		{Height: 600000, Hash: "00000000000000000866448ef293f900812d4af8e08cbe7ef62888eee9d29c4c"},
+		{Height: 650000, Hash: "00000000000000000310c17bbb4f3f8e5371a41ec2cee36a39876042019b725b"},
+		{Height: 700000, Hash: "00000000000000000e155235fd83a8757c44c6299e63104fb12632368f3f0cc9"},
+		{Height: 750000, Hash: "000000000000000006296f1e5437dd6c01b9b5471691a89a9c7d8e9f06920da5"},
+		{Height: 800000, Hash: "000000000000000000ad9056924410005d91b57f100bce345944e5caf56e8565"},
+		{Height: 850000, Hash: "0000000000000000039302a65227ab75fd93904ebe2e62421d1c66b15808b23b"},
+		{Height: 868500, Hash: "00000000000000000a4c8747ee369c2f4645cf7b55db534851fdc1a040f74de4"},
+	},
+}
+
+// TestNet defines the consensus rules of the test network, version 3.
+var TestNet = &NetworkParams{
+	Name:                          "testnet",
+	GenesisHeader:                 genesisHeader(1296688602, 0x1d00ffff, 414098458),
+	PowLimit:                      mainPowLimit,
+	PowLimitBits:                  0x1d00ffff,
+	UAHFHeight:                    1155875,
+	DAAHeight:                     1188697,
+	TargetTimespan:                time.Hour * 24 * 14,
+	TargetTimePerBlock:            time.Minute * 10,
+	RetargetAdjustmentFactor:      4,
+	ReduceMinDifficulty:           true,
+	MinDiffReductionTime:          time.Minute * 20,
+	SubsidyHalvingInterval:        210000,
+	GenesisActivationHeight:       1344302,
+	RuleChangeActivationThreshold: 1512,
+	MinerConfirmationWindow:       2016,
+	Deployments: []Deployment{
+		{Name: DeploymentTestDummy, Bit: 28, StartTime: time.Unix(1199145601, 0), Timeout: time.Unix(1230767999, 0)},
+		{Name: DeploymentCSV, Bit: 0, StartTime: time.Unix(1456790400, 0), Timeout: time.Unix(1493596800, 0)},
+	},
+	Checkpoints: []Checkpoint{
+		{Height: 546, Hash: "000000002a936ca763904c3c35fce2f3556c559c0214345d31b1bcebf76acb70"},
+		{Height: 100000, Hash: "00000000009e2958c15ff9290d571bf9459e93b19765c6801ddeccadbb160a1e"},
+		{Height: 200000, Hash: "0000000000287bffd321963ef05feab753ebe274e1d78b2fd4e2bfe9ad3aa6f2"},
+		{Height: 300001, Hash: "0000000000004829474748f3d1bc8fcf893c88be255e6d7f571c548aff57abf4"},
+		{Height: 400002, Hash: "0000000005e2c73b8ecb82ae2dbc2e8274614ebad7172b53528aba7501f5a089"},
+		{Height: 500011, Hash: "00000000000929f63977fbac92ff570a9bd9e7715401ee96f2848f7b07750b02"},
+		{Height: 600002, Hash: "000000000001f471389afd6ee94dcace5ccc44adc18e8bff402443f034b07240"},
+		{Height: 700000, Hash: "000000000000406178b12a4dea3b27e13b3c4fe4510994fd667d7c1e6a3f4dc1"},
+		{Height: 800010, Hash: "000000000017ed35296433190b6829db01e657d80631d43f5983fa403bfdb4c1"},
+		{Height: 900000, Hash: "0000000000356f8d8924556e765b7a94aaebc6b5c8685dcfa2b1ee8b41acd89b"},
+		{Height: 1000007, Hash: "00000000001ccb893d8a1f25b70ad173ce955e5f50124261bbbc50379a612ddf"},
+		{Height: 1100000, Hash: "00000000001c2fb9880485b1f3d7b0ffa9fabdfd0cf16e29b122bb6275c73db0"},
+		{Height: 1200000, Hash: "00000000d91bdbb5394bcf457c0f0b7a7e43eb978e2d881b6c2a4c2756abc558"},
+		{Height: 1300000, Hash: "00000000000000f7569d4d0af19d8d0b59bb0b1a989caf0f552afb5c00d38fbf"},
+		{Height: 1400000, Hash: "000000000000008f84faa5afa3e30bce81599108f932eabdf9ee3d39bb225e5b"},
+		{Height: 1500000, Hash: "00000000000005a00d805e3555e53f18c6276cb5ddc90a3ceeaeaf03bb2fdbea"},
+		{Height: 1600000, Hash: "000000000000133137efc60aab38163c0d032d651826ccbda90b169f3bcec6dd"},
+	},
+}
+
+// STN defines the consensus rules of the scaling test network. It shares its genesis block
+// with the test network.
+var STN = &NetworkParams{
+	Name:                          "stn",
+	GenesisHeader:                 genesisHeader(1296688602, 0x1d00ffff, 414098458),
+	PowLimit:                      mainPowLimit,
+	PowLimitBits:                  0x1d00ffff,
+	UAHFHeight:                    15,
+	DAAHeight:                     2200,
+	TargetTimespan:                time.Hour * 24 * 14,
+	TargetTimePerBlock:            time.Minute * 10,
+	RetargetAdjustmentFactor:      4,
+	SubsidyHalvingInterval:        210000,
+	GenesisActivationHeight:       100,
+	RuleChangeActivationThreshold: 1512,
+	MinerConfirmationWindow:       2016,
+}
+
+// RegTest defines the consensus rules of the regression test network.
+var RegTest = &NetworkParams{
+	Name:                          "regtest",
+	GenesisHeader:                 genesisHeader(1296688602, 0x207fffff, 2),
+	PowLimit:                      regressionPowLimit,
+	PowLimitBits:                  0x207fffff,
+	TargetTimespan:                time.Hour * 24 * 14,
+	TargetTimePerBlock:            time.Minute * 10,
+	RetargetAdjustmentFactor:      4,
+	ReduceMinDifficulty:           true,
+	MinDiffReductionTime:          time.Minute * 20,
+	NoDifficultyAdjustment:        true,
+	SubsidyHalvingInterval:        150,
+	GenesisActivationHeight:       10000,
+	RuleChangeActivationThreshold: 108,
+	MinerConfirmationWindow:       144,
+	Deployments: []Deployment{
+		{Name: DeploymentTestDummy, Bit: 28, StartTime: time.Unix(0, 0), Timeout: time.Unix(999999999999, 0)},
+		{Name: DeploymentCSV, Bit: 0, StartTime: time.Unix(0, 0), Timeout: time.Unix(999999999999, 0)},
+	},
+}
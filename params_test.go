@@ -0,0 +1,133 @@
+package bc_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestNetworkParams_Genesis(t *testing.T) {
+	tests := map[string]struct {
+		params *bc.NetworkParams
+		hash   string
+	}{
+		"mainnet": {
+			params: bc.MainNet,
+			hash:   "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+		},
+		"testnet": {
+			params: bc.TestNet,
+			hash:   "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		},
+		"stn": {
+			params: bc.STN,
+			hash:   "000000000933ea01ad0ee984209779baaec3ced90fa3f408719526f8d77f4943",
+		},
+		"regtest": {
+			params: bc.RegTest,
+			hash:   "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.hash, test.params.GenesisHash())
+			require.True(t, test.params.GenesisHeader.Valid())
+			require.Equal(t, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+				test.params.GenesisHeader.HashMerkleRootStr())
+		})
+	}
+}
+
+func TestNetworkParams_Checkpoints(t *testing.T) {
+	for _, params := range []*bc.NetworkParams{bc.MainNet, bc.TestNet, bc.STN, bc.RegTest} {
+		t.Run(params.Name, func(t *testing.T) {
+			for i := 1; i < len(params.Checkpoints); i++ {
+				require.Greater(t, params.Checkpoints[i].Height, params.Checkpoints[i-1].Height)
+			}
+			for _, cp := range params.Checkpoints {
+				require.Len(t, cp.Hash, 64)
+			}
+		})
+	}
+
+	require.Equal(t, &bc.Checkpoint{
+		Height: 11111,
+		Hash:   "0000000069e244f73d78e8fd29ba2fd2ed618bd6fa2ee92559f542fdb26e7c1d",
+	}, &bc.MainNet.Checkpoints[0])
+	require.Equal(t, uint64(868500), bc.MainNet.LastCheckpoint().Height)
+	require.Nil(t, bc.RegTest.LastCheckpoint())
+}
+
+func TestNetworkParams_BlockSubsidy(t *testing.T) {
+	tests := map[string]struct {
+		params  *bc.NetworkParams
+		height  uint64
+		subsidy uint64
+	}{
+		"mainnet genesis": {
+			params:  bc.MainNet,
+			height:  0,
+			subsidy: 5000000000,
+		},
+		"mainnet before first halving": {
+			params:  bc.MainNet,
+			height:  209999,
+			subsidy: 5000000000,
+		},
+		"mainnet first halving": {
+			params:  bc.MainNet,
+			height:  210000,
+			subsidy: 2500000000,
+		},
+		"mainnet fourth halving": {
+			params:  bc.MainNet,
+			height:  840000,
+			subsidy: 312500000,
+		},
+		"mainnet last satoshi": {
+			params:  bc.MainNet,
+			height:  210000 * 32,
+			subsidy: 1,
+		},
+		"mainnet no subsidy": {
+			params:  bc.MainNet,
+			height:  210000 * 33,
+			subsidy: 0,
+		},
+		"mainnet 64 halvings": {
+			params:  bc.MainNet,
+			height:  210000 * 64,
+			subsidy: 0,
+		},
+		"regtest first halving": {
+			params:  bc.RegTest,
+			height:  150,
+			subsidy: 2500000000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.subsidy, test.params.BlockSubsidy(test.height))
+		})
+	}
+}
+
+func TestNetworkParams_IsGenesisActive(t *testing.T) {
+	require.False(t, bc.MainNet.IsGenesisActive(620537))
+	require.True(t, bc.MainNet.IsGenesisActive(620538))
+	require.False(t, bc.TestNet.IsGenesisActive(1344301))
+	require.True(t, bc.TestNet.IsGenesisActive(1344302))
+	require.True(t, bc.STN.IsGenesisActive(100))
+	require.True(t, bc.RegTest.IsGenesisActive(10000))
+}
+
+func TestNetworkParams_DifficultyToHashrate(t *testing.T) {
+	require.Equal(t, "13.50 TH/s", bc.HumanHash(bc.MainNet.DifficultyToHashrate(22000, 7)))
+	require.Equal(t, "6.29 kH/s", bc.HumanHash(bc.RegTest.DifficultyToHashrate(22000, 7)))
+	require.Equal(t, bc.DifficultyToHashrate("BSV", 22000, 7), bc.MainNet.DifficultyToHashrate(22000, 7))
+	require.Equal(t, bc.DifficultyToHashrate("RSV", 22000, 7), bc.RegTest.DifficultyToHashrate(22000, 7))
+}
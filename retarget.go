@@ -0,0 +1,287 @@
+package bc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+const (
+	// daaWindow is the number of blocks the CW-144 difficulty adjustment averages work over.
+	daaWindow = 144
+	// edaWindow is the number of blocks the emergency difficulty adjustment measures time over.
+	edaWindow = 6
+	// edaTrigger is the time the last edaWindow blocks must take to trigger an emergency
+	// difficulty adjustment.
+	edaTrigger = 12 * time.Hour
+	// medianTimeBlocks is the number of blocks used to calculate the median time past.
+	medianTimeBlocks = 11
+)
+
+// DifficultyAlgorithm is a method used to calculate the difficulty of the next block.
+type DifficultyAlgorithm int
+
+// The difficulty adjustment algorithms bitcoin has used.
+const (
+	// DifficultyLegacy retargets the difficulty every 2016 blocks.
+	DifficultyLegacy DifficultyAlgorithm = iota
+	// DifficultyEDA is the legacy algorithm with the emergency difficulty adjustment,
+	// which lowers the difficulty when blocks are found too slowly, added in August 2017.
+	DifficultyEDA
+	// DifficultyDAA is the CW-144 difficulty adjustment used since November 2017,
+	// which retargets every block.
+	DifficultyDAA
+)
+
+// String returns the name of the algorithm.
+func (d DifficultyAlgorithm) String() string {
+	switch d {
+	case DifficultyLegacy:
+		return "legacy"
+	case DifficultyEDA:
+		return "eda"
+	case DifficultyDAA:
+		return "daa"
+	}
+	return "unknown"
+}
+
+// DifficultyAlgorithm returns the algorithm used to calculate the difficulty of the block
+// after the block at height.
+func (p *NetworkParams) DifficultyAlgorithm(height uint64) DifficultyAlgorithm {
+	switch {
+	case height >= p.DAAHeight:
+		return DifficultyDAA
+	case height >= p.UAHFHeight:
+		return DifficultyEDA
+	}
+	return DifficultyLegacy
+}
+
+// blocksPerRetarget returns the number of blocks between legacy difficulty retargets.
+func (p *NetworkParams) blocksPerRetarget() uint64 {
+	return uint64(p.TargetTimespan / p.TargetTimePerBlock)
+}
+
+// NextWorkRequired returns the bits the network's difficulty rules require of header, which
+// must be the next block after the tip of chain.
+func NextWorkRequired(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	header *BlockHeader) ([]byte, error) {
+	height, _, err := chain.ChainTip(ctx)
+	if err != nil {
+		return nil, err
+	}
+	last, err := chain.BlockHeaderByHeight(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+
+	bits, err := nextWorkRequired(ctx, chain, params, height, last, int64(header.Time))
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, bits)
+	return b, nil
+}
+
+func nextWorkRequired(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams, height uint64,
+	last *BlockHeader, newBlockTime int64) (uint32, error) {
+	if params.NoDifficultyAdjustment {
+		return compactBits(last), nil
+	}
+
+	algorithm := params.DifficultyAlgorithm(height)
+	if algorithm != DifficultyDAA {
+		return legacyWorkRequired(ctx, chain, params, height, last, newBlockTime, algorithm)
+	}
+
+	if params.ReduceMinDifficulty && newBlockTime > int64(last.Time)+int64(params.MinDiffReductionTime/time.Second) {
+		return params.PowLimitBits, nil
+	}
+	if height < daaWindow+2 {
+		return 0, fmt.Errorf("difficulty adjustment at height %d needs %d previous blocks", height+1, daaWindow+2)
+	}
+
+	lastHeight, lastTime, err := suitableBlock(ctx, chain, height)
+	if err != nil {
+		return 0, err
+	}
+	firstHeight, firstTime, err := suitableBlock(ctx, chain, height-daaWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	// the work done between the suitable blocks.
+	var chainWork ChainWork
+	for h := firstHeight + 1; h <= lastHeight; h++ {
+		bh, err := chain.BlockHeaderByHeight(ctx, h)
+		if err != nil {
+			return 0, err
+		}
+		target, err := NewTargetFromBits(bh.Bits)
+		if err != nil {
+			return 0, err
+		}
+		chainWork = chainWork.Add(target.Work())
+	}
+
+	// bound the time taken to avoid difficulty cliffs.
+	spacing := int64(params.TargetTimePerBlock / time.Second)
+	duration := lastTime - firstTime
+	if duration > 288*spacing {
+		duration = 288 * spacing
+	} else if duration < 72*spacing {
+		duration = 72 * spacing
+	}
+
+	// target = (2^256 - work) / work, where work is the work projected over a block.
+	work := chainWork.Int()
+	projected := work.Mul(work, big.NewInt(spacing))
+	projected.Div(projected, big.NewInt(duration))
+	target := new(big.Int).Lsh(big.NewInt(1), 256)
+	target.Sub(target, projected)
+	target.Div(target, projected)
+	if target.Cmp(params.PowLimit) > 0 {
+		target.Set(params.PowLimit)
+	}
+
+	return Target{n: target}.Compact(), nil
+}
+
+// legacyWorkRequired calculates the difficulty using the original 2016 block retarget, along
+// with the emergency difficulty adjustment when it is active.
+func legacyWorkRequired(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams, height uint64,
+	last *BlockHeader, newBlockTime int64, algorithm DifficultyAlgorithm) (uint32, error) {
+	lastBits := compactBits(last)
+	interval := params.blocksPerRetarget()
+
+	if (height+1)%interval != 0 {
+		if params.ReduceMinDifficulty {
+			// allow a minimum difficulty block when no block has been found for a while,
+			// otherwise use the difficulty of the last block mined without it.
+			if newBlockTime > int64(last.Time)+int64(params.MinDiffReductionTime/time.Second) {
+				return params.PowLimitBits, nil
+			}
+			return prevTestNetBits(ctx, chain, params, height, lastBits)
+		}
+
+		if algorithm == DifficultyEDA {
+			t, err := NewTargetFromCompact(lastBits)
+			if err != nil {
+				return 0, err
+			}
+			target := t.Int()
+			if target.Cmp(params.PowLimit) == 0 {
+				return params.PowLimitBits, nil
+			}
+			if height < edaWindow {
+				return 0, fmt.Errorf("emergency difficulty adjustment at height %d needs %d previous blocks", height+1, edaWindow)
+			}
+			lastMTP, err := MedianTimePastAtHeight(ctx, chain, height)
+			if err != nil {
+				return 0, err
+			}
+			firstMTP, err := MedianTimePastAtHeight(ctx, chain, height-edaWindow)
+			if err != nil {
+				return 0, err
+			}
+
+			// lower the difficulty by 20% when the last 6 blocks took 12 hours or more.
+			if lastMTP.Sub(firstMTP) >= edaTrigger {
+				target.Add(target, new(big.Int).Rsh(target, 2))
+				if target.Cmp(params.PowLimit) > 0 {
+					return params.PowLimitBits, nil
+				}
+				return Target{n: target}.Compact(), nil
+			}
+		}
+
+		return lastBits, nil
+	}
+
+	first, err := chain.BlockHeaderByHeight(ctx, height-(interval-1))
+	if err != nil {
+		return 0, err
+	}
+
+	// limit how far the difficulty can change.
+	timespan := int64(params.TargetTimespan / time.Second)
+	actual := int64(last.Time) - int64(first.Time)
+	if minTimespan := timespan / params.RetargetAdjustmentFactor; actual < minTimespan {
+		actual = minTimespan
+	} else if maxTimespan := timespan * params.RetargetAdjustmentFactor; actual > maxTimespan {
+		actual = maxTimespan
+	}
+
+	t, err := NewTargetFromCompact(lastBits)
+	if err != nil {
+		return 0, err
+	}
+	target := t.Int()
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(timespan))
+	if target.Cmp(params.PowLimit) > 0 {
+		target.Set(params.PowLimit)
+	}
+
+	return Target{n: target}.Compact(), nil
+}
+
+// prevTestNetBits returns the bits of the last block before height, inclusive, which was not
+// mined at the minimum difficulty, or a retarget block.
+func prevTestNetBits(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams, height uint64,
+	bits uint32) (uint32, error) {
+	interval := params.blocksPerRetarget()
+	for height%interval != 0 && bits == params.PowLimitBits {
+		height--
+		bh, err := chain.BlockHeaderByHeight(ctx, height)
+		if errors.Is(err, ErrHeaderNotFound) {
+			return params.PowLimitBits, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		bits = compactBits(bh)
+	}
+	return bits, nil
+}
+
+// suitableBlock returns the height and time of the block with the median time of the block
+// at height and its two parents.
+func suitableBlock(ctx context.Context, chain HeightIndexedBlockHeaderChain, height uint64) (uint64, int64, error) {
+	type block struct {
+		height uint64
+		time   int64
+	}
+	var blocks [3]block
+	for i := range blocks {
+		bh, err := chain.BlockHeaderByHeight(ctx, height-2+uint64(i))
+		if err != nil {
+			return 0, 0, err
+		}
+		blocks[i] = block{height: height - 2 + uint64(i), time: int64(bh.Time)}
+	}
+
+	// sort the blocks the same way the node does, so blocks with equal times resolve the same.
+	if blocks[0].time > blocks[2].time {
+		blocks[0], blocks[2] = blocks[2], blocks[0]
+	}
+	if blocks[0].time > blocks[1].time {
+		blocks[0], blocks[1] = blocks[1], blocks[0]
+	}
+	if blocks[1].time > blocks[2].time {
+		blocks[1], blocks[2] = blocks[2], blocks[1]
+	}
+
+	return blocks[1].height, blocks[1].time, nil
+}
+
+// compactBits returns the bits of a block header as a uint32.
+func compactBits(bh *BlockHeader) uint32 {
+	return binary.BigEndian.Uint32(bh.Bits)
+}
@@ -0,0 +1,266 @@
+package bc_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// syntheticChain builds n headers with the bits provided, the time of each is given by
+// timeAt. They do not carry proof of work.
+func syntheticChain(n int, bits uint32, timeAt func(height int) uint32) []*bc.BlockHeader {
+	hh := make([]*bc.BlockHeader, n)
+	for i := range hh {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, bits)
+		hh[i] = &bc.BlockHeader{
+			Version:        1,
+			Time:           timeAt(i),
+			Nonce:          uint32(i),
+			HashPrevBlock:  make([]byte, 32),
+			HashMerkleRoot: make([]byte, 32),
+			Bits:           b,
+		}
+	}
+	return hh
+}
+
+func spaced(spacing uint32) func(int) uint32 {
+	return func(height int) uint32 {
+		return 1500000000 + uint32(height)*spacing
+	}
+}
+
+func bitsOf(bits uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, bits)
+	return b
+}
+
+func TestNextWorkRequired_MainNetHeaders(t *testing.T) {
+	ctx := context.Background()
+	hh := mainnetHeaders(t, 4033)
+	chain := indexedChainOf(hh)
+
+	for height := 1; height < len(hh); height++ {
+		chain.headers = hh[:height]
+		bits, err := bc.NextWorkRequired(ctx, chain, bc.MainNet, hh[height])
+		require.NoError(t, err)
+		require.Equal(t, hh[height].Bits, bits, "height %d", height)
+	}
+}
+
+func TestNextWorkRequired(t *testing.T) {
+	ctx := context.Background()
+
+	eda := *bc.MainNet
+	eda.UAHFHeight, eda.DAAHeight = 0, 1000000
+	daa := *bc.MainNet
+	daa.UAHFHeight, daa.DAAHeight = 0, 0
+	testnetDAA := *bc.TestNet
+	testnetDAA.UAHFHeight, testnetDAA.DAAHeight = 0, 0
+
+	// the last 17 blocks were found 3 hours apart.
+	slow := func(height int) uint32 {
+		if height < 3 {
+			return spaced(600)(height)
+		}
+		return spaced(600)(3) + uint32(height-3)*3*60*60
+	}
+	// the last block was mined at the minimum difficulty.
+	minDiff := syntheticChain(100, 0x1b0404cb, spaced(600))
+	minDiff[99].Bits = bitsOf(0x1d00ffff)
+
+	tests := map[string]struct {
+		params  *bc.NetworkParams
+		chain   []*bc.BlockHeader
+		time    uint32
+		expBits uint32
+	}{
+		"legacy keeps the difficulty between retargets": {
+			params:  bc.MainNet,
+			chain:   syntheticChain(1000, 0x1b0404cb, spaced(300)),
+			expBits: 0x1b0404cb,
+		},
+		"legacy retarget": {
+			params:  bc.MainNet,
+			chain:   syntheticChain(2016, 0x1b0404cb, spaced(300)),
+			expBits: 0x1b020224,
+		},
+		"legacy retarget limited to a quarter of the target": {
+			params:  bc.MainNet,
+			chain:   syntheticChain(2016, 0x1b0404cb, spaced(60)),
+			expBits: 0x1b010132,
+		},
+		"legacy retarget limited to four times the target": {
+			params:  bc.MainNet,
+			chain:   syntheticChain(2016, 0x1b0404cb, spaced(6000)),
+			expBits: 0x1b10132c,
+		},
+		"legacy retarget limited to the pow limit": {
+			params:  bc.MainNet,
+			chain:   syntheticChain(2016, 0x1d00ffff, spaced(6000)),
+			expBits: 0x1d00ffff,
+		},
+		"eda keeps the difficulty when blocks are on time": {
+			params:  &eda,
+			chain:   syntheticChain(20, 0x1b0404cb, spaced(600)),
+			expBits: 0x1b0404cb,
+		},
+		"eda lowers the difficulty when 6 blocks take 12 hours": {
+			params:  &eda,
+			chain:   syntheticChain(20, 0x1b0404cb, slow),
+			expBits: 0x1b0505fd,
+		},
+		"eda does not go beyond the pow limit": {
+			params:  &eda,
+			chain:   syntheticChain(20, 0x1d00ffff, slow),
+			expBits: 0x1d00ffff,
+		},
+		"daa keeps the difficulty when blocks are on time": {
+			params:  &daa,
+			chain:   syntheticChain(200, 0x1b0404cb, spaced(600)),
+			expBits: 0x1b0404cb,
+		},
+		"daa raises the difficulty when blocks are fast": {
+			params:  &daa,
+			chain:   syntheticChain(200, 0x1b0404cb, spaced(450)),
+			expBits: 0x1b030398,
+		},
+		"daa limits the adjustment when blocks are very fast": {
+			params:  &daa,
+			chain:   syntheticChain(200, 0x1b0404cb, spaced(60)),
+			expBits: 0x1b020265,
+		},
+		"daa limits the adjustment when blocks are very slow": {
+			params:  &daa,
+			chain:   syntheticChain(200, 0x1b0404cb, spaced(6000)),
+			expBits: 0x1b080996,
+		},
+		"testnet allows a minimum difficulty block after 20 minutes": {
+			params:  bc.TestNet,
+			chain:   syntheticChain(100, 0x1b0404cb, spaced(600)),
+			time:    spaced(600)(99) + 20*60 + 1,
+			expBits: 0x1d00ffff,
+		},
+		"testnet returns to the difficulty before minimum difficulty blocks": {
+			params:  bc.TestNet,
+			chain:   minDiff,
+			time:    spaced(600)(99) + 20*60,
+			expBits: 0x1b0404cb,
+		},
+		"testnet daa allows a minimum difficulty block after 20 minutes": {
+			params:  &testnetDAA,
+			chain:   syntheticChain(200, 0x1b0404cb, spaced(600)),
+			time:    spaced(600)(199) + 20*60 + 1,
+			expBits: 0x1d00ffff,
+		},
+		"regtest keeps the difficulty": {
+			params:  bc.RegTest,
+			chain:   syntheticChain(2016, 0x207fffff, spaced(6000)),
+			expBits: 0x207fffff,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			last := test.chain[len(test.chain)-1]
+			next := &bc.BlockHeader{Time: last.Time + 600}
+			if test.time != 0 {
+				next.Time = test.time
+			}
+
+			bits, err := bc.NextWorkRequired(ctx, indexedChainOf(test.chain), test.params, next)
+			require.NoError(t, err)
+			require.Equal(t, bitsOf(test.expBits), bits)
+		})
+	}
+}
+
+func TestNetworkParams_DifficultyAlgorithm(t *testing.T) {
+	tests := map[string]struct {
+		height uint64
+		exp    bc.DifficultyAlgorithm
+	}{
+		"tip before the uahf height": {height: 478557, exp: bc.DifficultyLegacy},
+		"tip at the uahf height":     {height: 478558, exp: bc.DifficultyEDA},
+		"tip before the daa height":  {height: 504030, exp: bc.DifficultyEDA},
+		"tip at the daa height":      {height: 504031, exp: bc.DifficultyDAA},
+		"tip after the daa height":   {height: 504032, exp: bc.DifficultyDAA},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.exp, bc.MainNet.DifficultyAlgorithm(test.height))
+		})
+	}
+}
+
+// mainnetHeadersFrom loads the mainnet headers in file, the first being at the height from
+// and the last at to. The test is skipped if the file has not been fetched.
+func mainnetHeadersFrom(t *testing.T, file string, from, to uint64) []*bc.BlockHeader {
+	t.Helper()
+	b, err := data.HeadersData.Load(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("%s is missing, fetch it with MAINNET_RPC set to a mainnet node and go generate ./testing/data", file)
+	}
+	require.NoError(t, err)
+	require.Len(t, b, int(to-from+1)*80)
+
+	hh := make([]*bc.BlockHeader, to-from+1)
+	for i := range hh {
+		hh[i], err = bc.NewBlockHeaderFromBytes(b[i*80 : (i+1)*80])
+		require.NoError(t, err)
+	}
+	return hh
+}
+
+func TestNextWorkRequired_MainNet(t *testing.T) {
+	ctx := context.Background()
+	tests := map[string]struct {
+		file     string
+		from, to uint64
+		// first is the height of the first block whose bits are checked, the headers before
+		// it are those its difficulty is calculated from.
+		first uint64
+	}{
+		"legacy retarget": {
+			file:  "mainnet_30240_32300.bin",
+			from:  30240,
+			to:    32300,
+			first: 32256,
+		},
+		"uahf activation": {
+			file:  "mainnet_478540_478600.bin",
+			from:  478540,
+			to:    478600,
+			first: 478558,
+		},
+		"daa activation": {
+			file:  "mainnet_501984_504040.bin",
+			from:  501984,
+			to:    504040,
+			first: 504000,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			hh := mainnetHeadersFrom(t, test.file, test.from, test.to)
+			for height := test.first; height <= test.to; height++ {
+				chain := indexedChainFrom(test.from, hh[:height-test.from])
+				bh := hh[height-test.from]
+				bits, err := bc.NextWorkRequired(ctx, chain, bc.MainNet, bh)
+				require.NoError(t, err, "block %d", height)
+				require.Equal(t, bh.Bits, bits, "block %d", height)
+			}
+		})
+	}
+}
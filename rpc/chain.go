@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// codeInvalidParameter is returned by the node for a height above its tip.
+const codeInvalidParameter = -8
+
+// NodeBlockHeader returns the header with the hash provided along with the chain information
+// the node holds for it. bc.ErrHeaderNotFound is returned if the node does not know of it.
+func (c *Client) NodeBlockHeader(ctx context.Context, blockHash string) (*bc.NodeBlockHeader, error) {
+	var h bc.NodeBlockHeader
+	if err := c.call(ctx, &h, "getblockheader", blockHash, true); err != nil {
+		return nil, headerError(err, blockHash)
+	}
+	return &h, nil
+}
+
+// BlockHeader returns the header with the hash provided. bc.ErrHeaderNotFound is returned if
+// the node does not know of it and bc.ErrNotOnLongestChain if it is not on the longest chain.
+func (c *Client) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	h, err := c.NodeBlockHeader(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if h.Confirmations < 0 {
+		return nil, errors.Wrap(bc.ErrNotOnLongestChain, blockHash)
+	}
+	return h.Header, nil
+}
+
+// BlockHeight returns the height of the header with the hash provided, on the longest chain.
+func (c *Client) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	h, err := c.NodeBlockHeader(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	if h.Confirmations < 0 {
+		return 0, errors.Wrap(bc.ErrNotOnLongestChain, blockHash)
+	}
+	return h.Height, nil
+}
+
+// ChainTip returns the height and hash of the tip of the node's longest chain.
+func (c *Client) ChainTip(ctx context.Context) (uint64, string, error) {
+	var info struct {
+		Blocks        uint64 `json:"blocks"`
+		BestBlockHash string `json:"bestblockhash"`
+	}
+	if err := c.call(ctx, &info, "getblockchaininfo"); err != nil {
+		return 0, "", err
+	}
+	return info.Blocks, info.BestBlockHash, nil
+}
+
+// BlockHeaderByHeight returns the header at the height provided on the longest chain.
+// bc.ErrHeaderNotFound is returned if the height is above the tip of the node.
+func (c *Client) BlockHeaderByHeight(ctx context.Context, height uint64) (*bc.BlockHeader, error) {
+	var hash string
+	if err := c.call(ctx, &hash, "getblockhash", height); err != nil {
+		return nil, heightError(err, height)
+	}
+	return c.BlockHeader(ctx, hash)
+}
+
+// BlockHeaders returns the headers with the hashes provided, making a single batched call to
+// the node. Errors are returned as they are by BlockHeader, for the first header which fails.
+func (c *Client) BlockHeaders(ctx context.Context, blockHashes ...string) ([]*bc.BlockHeader, error) {
+	hh := make([]bc.NodeBlockHeader, len(blockHashes))
+	calls := make([]*call, len(blockHashes))
+	for i, hash := range blockHashes {
+		calls[i] = &call{method: "getblockheader", params: []interface{}{hash, true}, result: &hh[i]}
+	}
+	if err := c.batch(ctx, calls); err != nil {
+		return nil, err
+	}
+
+	headers := make([]*bc.BlockHeader, len(blockHashes))
+	for i, cl := range calls {
+		if cl.err != nil {
+			return nil, headerError(cl.err, blockHashes[i])
+		}
+		if hh[i].Confirmations < 0 {
+			return nil, errors.Wrap(bc.ErrNotOnLongestChain, blockHashes[i])
+		}
+		headers[i] = hh[i].Header
+	}
+	return headers, nil
+}
+
+// BlockHeadersByHeight returns the headers from the height from to the height to, inclusive,
+// on the longest chain. It makes two batched calls to the node, one for the hashes of the
+// headers and one for the headers.
+func (c *Client) BlockHeadersByHeight(ctx context.Context, from, to uint64) ([]*bc.BlockHeader, error) {
+	if from > to {
+		return nil, errors.Errorf("from height %d is above to height %d", from, to)
+	}
+	hashes := make([]string, to-from+1)
+	calls := make([]*call, len(hashes))
+	for i := range hashes {
+		calls[i] = &call{method: "getblockhash", params: []interface{}{from + uint64(i)}, result: &hashes[i]}
+	}
+	if err := c.batch(ctx, calls); err != nil {
+		return nil, err
+	}
+	for i, cl := range calls {
+		if cl.err != nil {
+			return nil, heightError(cl.err, from+uint64(i))
+		}
+	}
+
+	return c.BlockHeaders(ctx, hashes...)
+}
+
+// headerError maps the node's error for an unknown block hash onto bc.ErrHeaderNotFound.
+func headerError(err error, blockHash string) error {
+	if isCode(err, codeInvalidAddressOrKey) {
+		return errors.Wrap(bc.ErrHeaderNotFound, blockHash)
+	}
+	return errors.Wrapf(err, "failed to get header %s", blockHash)
+}
+
+// heightError maps the node's error for a height above its tip onto bc.ErrHeaderNotFound.
+func heightError(err error, height uint64) error {
+	if isCode(err, codeInvalidParameter) {
+		return errors.Wrapf(bc.ErrHeaderNotFound, "height %d", height)
+	}
+	return errors.Wrapf(err, "failed to get header at height %d", height)
+}
@@ -0,0 +1,238 @@
+// Package rpc provides a client for the JSON-RPC interface of a bitcoin node.
+//
+// The Client implements bc.HeightIndexedBlockHeaderChain, spv.TxStore and spv.MerkleProofStore,
+// so it can be supplied directly to spv.NewEnvelopeCreator, spv.NewPaymentVerifier and
+// spv.NewMerkleProofVerifier.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The error codes returned by the node which the client maps to errors of its own.
+const (
+	codeInvalidAddressOrKey = -5
+	codeMethodNotFound      = -32601
+)
+
+var (
+	// ErrUnauthorised is returned when the node rejects the credentials of the client.
+	ErrUnauthorised = errors.New("node rejected the rpc credentials")
+
+	// ErrTxNotFound is returned when the node does not know of a transaction.
+	ErrTxNotFound = errors.New("transaction not found")
+
+	// ErrInvalidProof is returned when the node returns a merkle proof which cannot be read,
+	// or which does not prove the transaction requested.
+	ErrInvalidProof = errors.New("node returned an invalid merkle proof")
+)
+
+// An Error is an error returned by the node in response to a call.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error returns the error message and code of the node.
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type clientOptions struct {
+	httpClient *http.Client
+	user       string
+	password   string
+	timeout    time.Duration
+}
+
+// ClientOpt defines a functional option that is used to modify the behaviour of a Client.
+type ClientOpt func(*clientOptions)
+
+// WithBasicAuth sets the rpc user and password of the node.
+func WithBasicAuth(user, password string) ClientOpt {
+	return func(o *clientOptions) {
+		o.user = user
+		o.password = password
+	}
+}
+
+// WithHTTPClient sets the http.Client used to call the node, http.DefaultClient is used
+// by default.
+func WithHTTPClient(c *http.Client) ClientOpt {
+	return func(o *clientOptions) {
+		o.httpClient = c
+	}
+}
+
+// WithTimeout limits the time each call to the node can take, including the calls of a batch.
+// Calls are only limited by the context they are given by default.
+func WithTimeout(d time.Duration) ClientOpt {
+	return func(o *clientOptions) {
+		o.timeout = d
+	}
+}
+
+// A Client calls the JSON-RPC interface of a bitcoin node. It is safe for concurrent use.
+type Client struct {
+	// id is first so it is aligned for atomic access on 32 bit platforms.
+	id   uint64
+	url  string
+	opts *clientOptions
+}
+
+// NewClient returns a Client for the node with the rpc url provided, such as
+// http://localhost:8332.
+func NewClient(url string, opts ...ClientOpt) (*Client, error) {
+	if url == "" {
+		return nil, errors.New("rpc url must be provided")
+	}
+	o := &clientOptions{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &Client{url: url, opts: o}, nil
+}
+
+type request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type response struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// A call is a method of the node to call with its params, and the value its result is
+// decoded into.
+type call struct {
+	method string
+	params []interface{}
+	result interface{}
+	err    error
+}
+
+// call calls a method of the node and decodes its result into result.
+func (c *Client) call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	calls := []*call{{method: method, params: params, result: result}}
+	if err := c.do(ctx, calls, false); err != nil {
+		return err
+	}
+	return calls[0].err
+}
+
+// batch makes the calls in a single request to the node, recording the error of each call
+// against it. An error is returned if the request fails.
+func (c *Client) batch(ctx context.Context, calls []*call) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	return c.do(ctx, calls, true)
+}
+
+func (c *Client) do(ctx context.Context, calls []*call, batch bool) error {
+	if c.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.timeout)
+		defer cancel()
+	}
+
+	reqs := make([]request, len(calls))
+	byID := make(map[uint64]*call, len(calls))
+	for i, cl := range calls {
+		params := cl.params
+		if params == nil {
+			params = []interface{}{}
+		}
+		reqs[i] = request{
+			JSONRPC: "1.0",
+			ID:      atomic.AddUint64(&c.id, 1),
+			Method:  cl.method,
+			Params:  params,
+		}
+		byID[reqs[i].ID] = cl
+	}
+
+	var body interface{} = reqs
+	if !batch {
+		body = reqs[0]
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode rpc request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to create rpc request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.opts.user != "" || c.opts.password != "" {
+		req.SetBasicAuth(c.opts.user, c.opts.password)
+	}
+
+	resp, err := c.opts.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call %s", calls[0].method)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return ErrUnauthorised
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read rpc response")
+	}
+
+	// the node returns the errors of single calls with an error status and a json body.
+	var resps []response
+	if batch {
+		err = json.Unmarshal(raw, &resps)
+	} else {
+		resps = make([]response, 1)
+		err = json.Unmarshal(raw, &resps[0])
+	}
+	if err != nil {
+		return errors.Errorf("unexpected rpc response, status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+
+	for _, r := range resps {
+		cl, ok := byID[r.ID]
+		if !ok {
+			return errors.Errorf("rpc response has unexpected id %d", r.ID)
+		}
+		delete(byID, r.ID)
+		if r.Error != nil {
+			cl.err = r.Error
+			continue
+		}
+		if cl.result != nil {
+			if err := json.Unmarshal(r.Result, cl.result); err != nil {
+				cl.err = errors.Wrapf(err, "failed to decode %s result", cl.method)
+			}
+		}
+	}
+	for _, cl := range byID {
+		cl.err = errors.Errorf("no rpc response for %s", cl.method)
+	}
+
+	return nil
+}
+
+// isCode returns true if err is an error returned by the node with the code provided.
+func isCode(err error, code int) bool {
+	var rpcErr *Error
+	return errors.As(err, &rpcErr) && rpcErr.Code == code
+}
@@ -0,0 +1,530 @@
+package rpc_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/rpc"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// the client can be supplied to the spv package.
+var (
+	_ bc.HeightIndexedBlockHeaderChain = (*rpc.Client)(nil)
+	_ spv.TxStore                      = (*rpc.Client)(nil)
+	_ spv.MerkleProofStore             = (*rpc.Client)(nil)
+)
+
+const genesisCoinbase = "01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+type rpcRequest struct {
+	ID     uint64            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     uint64      `json:"id"`
+	Result interface{} `json:"result"`
+	Error  *rpc.Error  `json:"error"`
+}
+
+// fakeNode is a stand in for the rpc interface of a node, holding the first headers of
+// mainnet, a stale header, the genesis coinbase and a block of transactions to prove.
+type fakeNode struct {
+	t       *testing.T
+	headers []*bc.BlockHeader
+	heights map[string]uint64
+	stale   *bc.BlockHeader
+	txs     map[string]string
+	block   *bc.BlockHeader
+	txids   []chainhash.Hash
+	proof2  bool
+	delay   time.Duration
+	calls   int
+}
+
+func newFakeNode(t *testing.T) *fakeNode {
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+
+	n := &fakeNode{t: t, heights: map[string]uint64{}, txs: map[string]string{}}
+	for i := 0; i < 200; i++ {
+		bh, err := bc.NewBlockHeaderFromBytes(b[i*80 : (i+1)*80])
+		require.NoError(t, err)
+		n.headers = append(n.headers, bh)
+		n.heights[bh.Hash().String()] = uint64(i)
+	}
+	stale := *n.headers[150]
+	stale.Nonce++
+	n.stale = &stale
+
+	tx, err := bt.NewTxFromString(genesisCoinbase)
+	require.NoError(t, err)
+	n.txs[tx.TxID()] = genesisCoinbase
+
+	// a block of 11 transactions, so the tree has a duplicated node at each level above the
+	// transactions.
+	for i := 0; i < 11; i++ {
+		n.txids = append(n.txids, chainhash.DoubleHashH([]byte{byte(i)}))
+	}
+	root := treeHash(n.txids, treeHeight(len(n.txids)), 0)
+	prev := n.headers[199].Hash()
+	n.block = &bc.BlockHeader{
+		Version:        1,
+		HashPrevBlock:  bt.ReverseBytes(prev[:]),
+		HashMerkleRoot: bt.ReverseBytes(root[:]),
+		Time:           1231469665,
+		Bits:           []byte{0x20, 0x7f, 0xff, 0xff},
+	}
+
+	return n
+}
+
+func (n *fakeNode) start(user, password string) *httptest.Server {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if n.delay > 0 {
+			time.Sleep(n.delay)
+		}
+
+		var raw json.RawMessage
+		require.NoError(n.t, json.NewDecoder(r.Body).Decode(&raw))
+		if raw[0] == '[' {
+			var reqs []rpcRequest
+			require.NoError(n.t, json.Unmarshal(raw, &reqs))
+			// answer out of order, as the node may.
+			resps := make([]rpcResponse, len(reqs))
+			for i, req := range reqs {
+				resps[len(reqs)-1-i] = n.handle(req)
+			}
+			require.NoError(n.t, json.NewEncoder(w).Encode(resps))
+			return
+		}
+
+		var req rpcRequest
+		require.NoError(n.t, json.Unmarshal(raw, &req))
+		resp := n.handle(req)
+		switch {
+		case resp.Error == nil:
+		case resp.Error.Code == -32601:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		require.NoError(n.t, json.NewEncoder(w).Encode(resp))
+	}))
+	n.t.Cleanup(s.Close)
+	return s
+}
+
+func (n *fakeNode) handle(req rpcRequest) rpcResponse {
+	n.calls++
+	resp := rpcResponse{ID: req.ID}
+	notFound := &rpc.Error{Code: -5, Message: "not found"}
+
+	var str string
+	switch req.Method {
+	case "getblockchaininfo":
+		tip := len(n.headers) - 1
+		resp.Result = map[string]interface{}{"blocks": tip, "bestblockhash": n.headers[tip].Hash().String()}
+	case "getblockhash":
+		var height int
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &height))
+		if height >= len(n.headers) {
+			resp.Error = &rpc.Error{Code: -8, Message: "Block height out of range"}
+			break
+		}
+		resp.Result = n.headers[height].Hash().String()
+	case "getblockheader":
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &str))
+		switch {
+		case str == n.stale.Hash().String():
+			resp.Result = &bc.NodeBlockHeader{Header: n.stale, Height: 150, Confirmations: -1}
+		case str == n.block.Hash().String():
+			resp.Result = &bc.NodeBlockHeader{Header: n.block, Height: 200, Confirmations: 1}
+		default:
+			height, ok := n.heights[str]
+			if !ok {
+				resp.Error = notFound
+				break
+			}
+			resp.Result = &bc.NodeBlockHeader{
+				Header:        n.headers[height],
+				Height:        height,
+				Confirmations: int64(len(n.headers)) - int64(height),
+			}
+		}
+	case "getrawtransaction":
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &str))
+		tx, ok := n.txs[str]
+		if !ok {
+			resp.Error = notFound
+			break
+		}
+		resp.Result = tx
+	case "getmerkleproof2":
+		if !n.proof2 {
+			resp.Error = &rpc.Error{Code: -32601, Message: "Method not found"}
+			break
+		}
+		require.NoError(n.t, json.Unmarshal(req.Params[1], &str))
+		index := n.indexOf(str)
+		if index < 0 {
+			resp.Error = notFound
+			break
+		}
+		resp.Result = &bc.MerkleProof{
+			Index:  uint64(index),
+			TxOrID: str,
+			Target: n.block.Hash().String(),
+			Nodes:  branch(n.txids, index),
+		}
+	case "gettxoutproof":
+		var txids []string
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &txids))
+		index := n.indexOf(txids[0])
+		if index < 0 {
+			resp.Error = notFound
+			break
+		}
+		resp.Result = hex.EncodeToString(merkleBlock(n.block, n.txids, index))
+	default:
+		resp.Error = &rpc.Error{Code: -32601, Message: "Method not found"}
+	}
+
+	return resp
+}
+
+func (n *fakeNode) indexOf(txID string) int {
+	for i, hash := range n.txids {
+		if hash.String() == txID {
+			return i
+		}
+	}
+	return -1
+}
+
+func treeWidth(txs int, height uint) int {
+	return (txs + (1 << height) - 1) >> height
+}
+
+func treeHeight(txs int) uint {
+	var height uint
+	for treeWidth(txs, height) > 1 {
+		height++
+	}
+	return height
+}
+
+func treeHash(txids []chainhash.Hash, height uint, pos int) chainhash.Hash {
+	if height == 0 {
+		return txids[pos]
+	}
+	left := treeHash(txids, height-1, pos*2)
+	right := left
+	if pos*2+1 < treeWidth(len(txids), height-1) {
+		right = treeHash(txids, height-1, pos*2+1)
+	}
+	return chainhash.DoubleHashH(append(left.CloneBytes(), right[:]...))
+}
+
+// branch returns the merkle proof nodes of the transaction at index.
+func branch(txids []chainhash.Hash, index int) []string {
+	var nodes []string
+	for height := uint(0); height < treeHeight(len(txids)); height++ {
+		pos := index >> height
+		switch {
+		case pos%2 == 1:
+			nodes = append(nodes, treeHash(txids, height, pos-1).String())
+		case pos+1 < treeWidth(len(txids), height):
+			nodes = append(nodes, treeHash(txids, height, pos+1).String())
+		default:
+			nodes = append(nodes, "*")
+		}
+	}
+	return nodes
+}
+
+// merkleBlock returns the serialised merkle block, as specified by BIP37, matching the
+// transaction at index.
+func merkleBlock(bh *bc.BlockHeader, txids []chainhash.Hash, index int) []byte {
+	var bits []bool
+	var hashes []chainhash.Hash
+	var build func(height uint, pos int)
+	build = func(height uint, pos int) {
+		parent := index>>height == pos
+		bits = append(bits, parent)
+		if height == 0 || !parent {
+			hashes = append(hashes, treeHash(txids, height, pos))
+			return
+		}
+		build(height-1, pos*2)
+		if pos*2+1 < treeWidth(len(txids), height-1) {
+			build(height-1, pos*2+1)
+		}
+	}
+	build(treeHeight(len(txids)), 0)
+
+	b := bh.Bytes()
+	txs := make([]byte, 4)
+	binary.LittleEndian.PutUint32(txs, uint32(len(txids)))
+	b = append(b, txs...)
+	b = append(b, bt.VarInt(len(hashes)).Bytes()...)
+	for _, hash := range hashes {
+		b = append(b, hash[:]...)
+	}
+	flags := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			flags[i/8] |= 1 << (i % 8)
+		}
+	}
+	b = append(b, bt.VarInt(len(flags)).Bytes()...)
+	return append(b, flags...)
+}
+
+func TestClient_BlockHeaderChain(t *testing.T) {
+	ctx := context.Background()
+	n := newFakeNode(t)
+	s := n.start("user", "pass")
+	c, err := rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"))
+	require.NoError(t, err)
+
+	bh, err := c.BlockHeader(ctx, "00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048")
+	require.NoError(t, err)
+	require.Equal(t, n.headers[1].String(), bh.String())
+
+	height, err := c.BlockHeight(ctx, n.headers[123].Hash().String())
+	require.NoError(t, err)
+	require.Equal(t, uint64(123), height)
+
+	height, hash, err := c.ChainTip(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(199), height)
+	require.Equal(t, n.headers[199].Hash().String(), hash)
+
+	bh, err = c.BlockHeaderByHeight(ctx, 42)
+	require.NoError(t, err)
+	require.Equal(t, n.headers[42].String(), bh.String())
+
+	confs, err := bc.Confirmations(ctx, c, n.headers[190].Hash().String())
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), confs)
+
+	tests := map[string]struct {
+		fn     func() error
+		expErr error
+	}{
+		"unknown header": {
+			fn: func() error {
+				_, err := c.BlockHeader(ctx, "0000000000000000000000000000000000000000000000000000000000000001")
+				return err
+			},
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"stale header": {
+			fn: func() error {
+				_, err := c.BlockHeader(ctx, n.stale.Hash().String())
+				return err
+			},
+			expErr: bc.ErrNotOnLongestChain,
+		},
+		"height of a stale header": {
+			fn: func() error {
+				_, err := c.BlockHeight(ctx, n.stale.Hash().String())
+				return err
+			},
+			expErr: bc.ErrNotOnLongestChain,
+		},
+		"height above the tip": {
+			fn: func() error {
+				_, err := c.BlockHeaderByHeight(ctx, 200)
+				return err
+			},
+			expErr: bc.ErrHeaderNotFound,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.ErrorIs(t, test.fn(), test.expErr)
+		})
+	}
+}
+
+func TestClient_Batch(t *testing.T) {
+	ctx := context.Background()
+	n := newFakeNode(t)
+	s := n.start("user", "pass")
+	c, err := rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"))
+	require.NoError(t, err)
+
+	hh, err := c.BlockHeadersByHeight(ctx, 10, 109)
+	require.NoError(t, err)
+	require.Len(t, hh, 100)
+	for i, bh := range hh {
+		require.Equal(t, n.headers[10+i].String(), bh.String())
+	}
+	require.Equal(t, 200, n.calls)
+
+	_, err = c.BlockHeadersByHeight(ctx, 150, 200)
+	require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+
+	_, err = c.BlockHeaders(ctx, n.headers[3].Hash().String(), n.stale.Hash().String())
+	require.ErrorIs(t, err, bc.ErrNotOnLongestChain)
+}
+
+func TestClient_Tx(t *testing.T) {
+	ctx := context.Background()
+	n := newFakeNode(t)
+	s := n.start("user", "pass")
+	c, err := rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"))
+	require.NoError(t, err)
+
+	tx, err := c.Tx(ctx, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+	require.NoError(t, err)
+	require.Equal(t, genesisCoinbase, tx.String())
+
+	_, err = c.Tx(ctx, "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098")
+	require.ErrorIs(t, err, rpc.ErrTxNotFound)
+}
+
+func TestClient_MerkleProof(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		proof2 bool
+	}{
+		"from getmerkleproof2": {
+			proof2: true,
+		},
+		"converted from gettxoutproof": {
+			proof2: false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			n := newFakeNode(t)
+			n.proof2 = test.proof2
+			s := n.start("user", "pass")
+			c, err := rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"))
+			require.NoError(t, err)
+
+			v, err := spv.NewMerkleProofVerifier(c)
+			require.NoError(t, err)
+
+			for i, txid := range n.txids {
+				proof, err := c.MerkleProof(ctx, txid.String())
+				require.NoError(t, err)
+				require.Equal(t, uint64(i), proof.Index)
+				require.Equal(t, n.block.Hash().String(), proof.Target)
+				require.Equal(t, branch(n.txids, i), proof.Nodes)
+
+				valid, _, err := v.VerifyMerkleProofJSON(ctx, proof)
+				require.NoError(t, err)
+				require.True(t, valid)
+			}
+
+			_, err = c.MerkleProof(ctx, "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+			require.ErrorIs(t, err, rpc.ErrTxNotFound)
+		})
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	ctx := context.Background()
+	n := newFakeNode(t)
+	s := n.start("user", "pass")
+
+	c, err := rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "wrong"))
+	require.NoError(t, err)
+	_, _, err = c.ChainTip(ctx)
+	require.ErrorIs(t, err, rpc.ErrUnauthorised)
+
+	n.delay = 100 * time.Millisecond
+	c, err = rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"), rpc.WithTimeout(10*time.Millisecond))
+	require.NoError(t, err)
+	_, _, err = c.ChainTip(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	c, err = rpc.NewClient(s.URL, rpc.WithBasicAuth("user", "pass"))
+	require.NoError(t, err)
+	_, _, err = c.ChainTip(cctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = rpc.NewClient("")
+	require.Error(t, err)
+}
+
+func TestMerkleProof_InvalidMerkleBlock(t *testing.T) {
+	ctx := context.Background()
+	n := newFakeNode(t)
+	valid := merkleBlock(n.block, n.txids, 3)
+
+	tests := map[string]struct {
+		block string
+	}{
+		"not hex": {
+			block: "zz",
+		},
+		"too short": {
+			block: hex.EncodeToString(valid[:83]),
+		},
+		"truncated hashes": {
+			block: hex.EncodeToString(valid[:120]),
+		},
+		"trailing bytes": {
+			block: hex.EncodeToString(append(append([]byte{}, valid...), 0)),
+		},
+		"wrong merkle root": {
+			block: func() string {
+				bh := *n.block
+				bh.HashMerkleRoot = n.headers[1].HashMerkleRoot
+				return hex.EncodeToString(merkleBlock(&bh, n.txids, 3))
+			}(),
+		},
+		"another transaction": {
+			block: hex.EncodeToString(merkleBlock(n.block, n.txids, 4)),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req rpcRequest
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				resp := rpcResponse{ID: req.ID, Result: test.block}
+				if req.Method == "getmerkleproof2" {
+					resp = rpcResponse{ID: req.ID, Error: &rpc.Error{Code: -32601, Message: "Method not found"}}
+					w.WriteHeader(http.StatusNotFound)
+				}
+				require.NoError(t, json.NewEncoder(w).Encode(resp))
+			}))
+			defer s.Close()
+
+			c, err := rpc.NewClient(s.URL)
+			require.NoError(t, err)
+			_, err = c.MerkleProof(ctx, n.txids[3].String())
+			require.ErrorIs(t, err, rpc.ErrInvalidProof)
+		})
+	}
+}
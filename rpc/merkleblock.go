@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"encoding/binary"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-p2p/chaincfg/chainhash"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+/*
+Merkle block layout, as returned by gettxoutproof
+
+Field 						Purpose 															Size (Bytes)
+----------------------------------------------------------------------------------------------------
+block header 				the header of the block holding the transactions 					80
+transactions 				number of transactions in the block, little endian 					4
+hash count 					number of hashes in the partial merkle tree 						VarInt
+hashes 						the hashes of the partial merkle tree, depth first 					32 * hash count
+flag byte count 			number of bytes of flags 											VarInt
+flags 						a bit per node of the tree, set when it is, or is a parent of, 		flag byte count
+							a matched transaction
+*/
+
+// partialTree walks the partial merkle tree of a merkle block, as specified by BIP37.
+type partialTree struct {
+	txs    uint32
+	hashes []chainhash.Hash
+	flags  []byte
+	bits   int
+	used   int
+	match  chainhash.Hash
+	index  uint64
+	found  bool
+	nodes  []string
+}
+
+// width returns the number of nodes of the tree at a height, where the transactions are at
+// height 0.
+func (t *partialTree) width(height uint) uint64 {
+	return (uint64(t.txs) + (1 << height) - 1) >> height
+}
+
+// walk returns the hash of the node at height and pos, and whether the matched transaction is
+// below it. The sibling hashes on the path from the matched transaction are recorded as it
+// returns up the tree.
+func (t *partialTree) walk(height uint, pos uint64) (chainhash.Hash, bool, error) {
+	if t.bits >= len(t.flags)*8 {
+		return chainhash.Hash{}, false, errors.Wrap(ErrInvalidProof, "ran out of flags")
+	}
+	parent := t.flags[t.bits/8]&(1<<(t.bits%8)) != 0
+	t.bits++
+
+	if height == 0 || !parent {
+		if t.used >= len(t.hashes) {
+			return chainhash.Hash{}, false, errors.Wrap(ErrInvalidProof, "ran out of hashes")
+		}
+		hash := t.hashes[t.used]
+		t.used++
+		matched := height == 0 && parent && hash == t.match
+		if matched {
+			if t.found {
+				return chainhash.Hash{}, false, errors.Wrap(ErrInvalidProof, "transaction matched twice")
+			}
+			t.found = true
+			t.index = pos
+		}
+		return hash, matched, nil
+	}
+
+	left, inLeft, err := t.walk(height-1, pos*2)
+	if err != nil {
+		return chainhash.Hash{}, false, err
+	}
+	right, inRight := left, false
+	duplicate := pos*2+1 >= t.width(height-1)
+	if !duplicate {
+		if right, inRight, err = t.walk(height-1, pos*2+1); err != nil {
+			return chainhash.Hash{}, false, err
+		}
+		// identical children allow a tree with a different number of transactions to have
+		// the same root, CVE-2012-2459.
+		if right == left {
+			return chainhash.Hash{}, false, errors.Wrap(ErrInvalidProof, "identical sibling hashes")
+		}
+	}
+
+	switch {
+	case inLeft && duplicate:
+		t.nodes = append(t.nodes, "*")
+	case inLeft:
+		t.nodes = append(t.nodes, right.String())
+	case inRight:
+		t.nodes = append(t.nodes, left.String())
+	}
+
+	var b [chainhash.HashSize * 2]byte
+	copy(b[:chainhash.HashSize], left[:])
+	copy(b[chainhash.HashSize:], right[:])
+	return chainhash.DoubleHashH(b[:]), inLeft || inRight, nil
+}
+
+// merkleProofFromMerkleBlock converts a serialised merkle block, as returned by gettxoutproof,
+// into a merkle proof of the transaction provided targeting the hash of the block.
+func merkleProofFromMerkleBlock(b []byte, txID string) (*bc.MerkleProof, error) {
+	match, err := chainhash.NewHashFromStr(txID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid txid %s", txID)
+	}
+	if len(b) < bc.BlockHeaderSize+4 {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block too short")
+	}
+	header, err := bc.NewHeaderFromBytes(b[:bc.BlockHeaderSize])
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProof, err.Error())
+	}
+	offset := bc.BlockHeaderSize
+
+	t := &partialTree{
+		txs:   binary.LittleEndian.Uint32(b[offset : offset+4]),
+		match: *match,
+	}
+	offset += 4
+	if t.txs == 0 {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block has no transactions")
+	}
+
+	if offset >= len(b) {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block hashes missing")
+	}
+	count, size := bt.NewVarIntFromBytes(b[offset:])
+	offset += size
+	if uint64(len(b)-offset) < uint64(count)*chainhash.HashSize {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block hashes truncated")
+	}
+	t.hashes = make([]chainhash.Hash, count)
+	for i := range t.hashes {
+		copy(t.hashes[i][:], b[offset:offset+chainhash.HashSize])
+		offset += chainhash.HashSize
+	}
+
+	if offset >= len(b) {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block flags missing")
+	}
+	flagBytes, size := bt.NewVarIntFromBytes(b[offset:])
+	offset += size
+	if uint64(len(b)-offset) != uint64(flagBytes) {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block flags truncated or followed by trailing bytes")
+	}
+	t.flags = b[offset:]
+
+	var height uint
+	for t.width(height) > 1 {
+		height++
+	}
+	root, _, err := t.walk(height, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !t.found {
+		return nil, errors.Wrapf(ErrInvalidProof, "merkle block does not match %s", txID)
+	}
+	if t.used != len(t.hashes) {
+		return nil, errors.Wrap(ErrInvalidProof, "merkle block has unused hashes")
+	}
+	if merkleRoot := header.MerkleRoot(); root != merkleRoot {
+		return nil, errors.Wrapf(ErrInvalidProof, "merkle block root %s does not match its header", root)
+	}
+
+	blockHash := header.Hash()
+	return &bc.MerkleProof{
+		Index:  t.index,
+		TxOrID: txID,
+		Target: blockHash.String(),
+		Nodes:  t.nodes,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// Tx returns the transaction with the id provided. ErrTxNotFound is returned if the node does
+// not know of it, the node must run with -txindex to find transactions which are not in its
+// mempool or wallet.
+func (c *Client) Tx(ctx context.Context, txID string) (*bt.Tx, error) {
+	var raw string
+	if err := c.call(ctx, &raw, "getrawtransaction", txID, false); err != nil {
+		return nil, txError(err, txID)
+	}
+	tx, err := bt.NewTxFromString(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read transaction %s", txID)
+	}
+	if tx.TxID() != txID {
+		return nil, errors.Errorf("node returned transaction %s for %s", tx.TxID(), txID)
+	}
+	return tx, nil
+}
+
+// MerkleProof returns a merkle proof, targeting the block hash, of the transaction with the
+// id provided. It is taken from getmerkleproof2 when the node supports it, otherwise the
+// partial merkle tree returned by gettxoutproof is converted into a merkle proof.
+//
+// ErrTxNotFound is returned if the node does not know of the transaction or it is not in a
+// block, and ErrInvalidProof if the node returns a proof which does not prove it.
+func (c *Client) MerkleProof(ctx context.Context, txID string) (*bc.MerkleProof, error) {
+	var proof bc.MerkleProof
+	err := c.call(ctx, &proof, "getmerkleproof2", "", txID)
+	if err == nil {
+		if proof.TxOrID != txID {
+			return nil, errors.Wrapf(ErrInvalidProof, "proof is for %s, not %s", proof.TxOrID, txID)
+		}
+		return &proof, nil
+	}
+	if !isCode(err, codeMethodNotFound) {
+		return nil, txError(err, txID)
+	}
+
+	var raw string
+	if err = c.call(ctx, &raw, "gettxoutproof", []string{txID}); err != nil {
+		return nil, txError(err, txID)
+	}
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidProof, err.Error())
+	}
+	return merkleProofFromMerkleBlock(b, txID)
+}
+
+// txError maps the node's error for an unknown transaction onto ErrTxNotFound.
+func txError(err error, txID string) error {
+	if isCode(err, codeInvalidAddressOrKey) {
+		return errors.Wrap(ErrTxNotFound, txID)
+	}
+	return errors.Wrapf(err, "failed to get transaction %s", txID)
+}
@@ -5,9 +5,8 @@
 package spv
 
 import (
-	"github.com/libsv/go-bk/crypto"
 	"github.com/libsv/go-bt/v2"
-	"github.com/libsv/go-bt/v2/bscript"
+	"github.com/pkg/errors"
 
 	"github.com/libsv/go-bc"
 )
@@ -22,6 +21,9 @@ const (
 type Payment struct {
 	PaymentTx *bt.Tx
 	Ancestry  []byte
+	// Graph is the ancestry as a TxGraph, which any ancestry format can be converted to.
+	// It is verified instead of Ancestry when set.
+	Graph *TxGraph
 }
 
 // binaryChunk is a clear way to pass around chunks while keeping their type explicit.
@@ -35,97 +37,125 @@ type extendedInput struct {
 	vin   int
 }
 
-type ancestry struct {
-	Tx            *bt.Tx
-	Proof         []byte
-	MapiResponses []*bc.MapiCallback
-}
-
-// parseAncestry creates a new struct from the bytes of a txContext.
-func parseAncestry(b []byte) (map[[32]byte]*ancestry, error) {
-
+// parseAncestry creates a TxGraph from the bytes of a txContext, rejecting it if it
+// exceeds the limits provided.
+func parseAncestry(b []byte, l Limits) (*TxGraph, error) {
+	if len(b) == 0 {
+		return nil, errors.Wrap(ErrTruncatedAncestry, "no version")
+	}
+	if err := l.size(len(b)); err != nil {
+		return nil, err
+	}
 	if b[0] != 1 { // the first byte is the version number.
 		return nil, ErrUnsupporredVersion
 	}
-	offset := uint64(1)
-	total := uint64(len(b))
-	aa := make(map[[32]byte]*ancestry)
+	r := bc.NewDecoder(b, ErrTruncatedAncestry)
+	if _, err := r.Byte("version"); err != nil {
+		return nil, err
+	}
+	g := NewTxGraph()
 
-	var TxID [32]byte
+	// current is the tx which the proof and mapi responses which follow it are for.
+	var current *TxNode
 
-	if total == offset {
+	if r.Remaining() == 0 {
 		return nil, ErrCannotCalculateFeePaid
 	}
 
 	// first Data must be a Tx
-	if b[offset] != 1 {
+	if next, _ := r.Peek("chunk type"); next != flagTx {
 		return nil, ErrTipTxConfirmed
 	}
 
-	for total > offset {
-		chunk, size := parseChunk(b, offset)
-		offset += size
+	var txs int
+	for r.Remaining() > 0 {
+		offset := r.Offset()
+		chunk, err := parseChunk(r)
+		if err != nil {
+			return nil, err
+		}
 		switch chunk.ContentType {
 		case flagTx:
-			hash := crypto.Sha256d(chunk.Data)
-			copy(TxID[:], bt.ReverseBytes(hash)) // fixed size array from slice.
-			tx, err := bt.NewTxFromBytes(chunk.Data)
-			if err != nil {
+			// the count is checked before the tx is parsed, which is where the work is.
+			txs++
+			if err := l.txs(txs); err != nil {
 				return nil, err
 			}
+			tx, err := newTxFromBytes(chunk.Data)
+			if err != nil {
+				return nil, errors.WithMessagef(err, "tx at offset %d", offset)
+			}
 			if len(tx.Inputs) == 0 {
 				return nil, ErrNoTxInputsToVerify
 			}
-			aa[TxID] = &ancestry{
-				Tx: tx,
+			current = &TxNode{Tx: tx}
+			if err := g.Add(current); err != nil {
+				return nil, errors.WithMessagef(err, "tx at offset %d", offset)
 			}
 		case flagProof:
-			aa[TxID].Proof = chunk.Data
+			current.Proof = chunk.Data
 		case flagMapi:
-			callBacks, err := parseMapiCallbacks(chunk.Data)
+			callBacks, err := parseMapiCallbacks(chunk.Data, l)
 			if err != nil {
-				return nil, err
+				return nil, errors.WithMessagef(err, "mapi responses at offset %d", offset)
 			}
-			aa[TxID].MapiResponses = callBacks
+			current.MapiResponses = callBacks
 		default:
 			continue
 		}
 	}
-	return aa, nil
+	return g, nil
 }
 
-func parseChunk(b []byte, start uint64) (binaryChunk, uint64) {
-	offset := start
-	typeOfNextData := b[offset]
-	offset++
-	l, size := bt.NewVarIntFromBytes(b[offset:])
-	offset += uint64(size)
-	chunk := binaryChunk{
-		ContentType: typeOfNextData,
-		Data:        b[offset : offset+uint64(l)],
+// newTxFromBytes parses a transaction with bc.NewTxFromBytes, the error returned wraps
+// ErrMalformedTx.
+func newTxFromBytes(b []byte) (*bt.Tx, error) {
+	tx, err := bc.NewTxFromBytes(b)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedTx, err.Error())
 	}
-	offset += uint64(l)
-	return chunk, offset - start
+	return tx, nil
 }
 
-func parseMapiCallbacks(b []byte) ([]*bc.MapiCallback, error) {
+// parseChunk reads the next chunk, its type followed by its length prefixed data.
+func parseChunk(r *bc.Decoder) (binaryChunk, error) {
+	typeOfNextData, err := r.Byte("chunk type")
+	if err != nil {
+		return binaryChunk{}, err
+	}
+	data, err := r.VarBytes("chunk")
+	if err != nil {
+		return binaryChunk{}, err
+	}
+	return binaryChunk{
+		ContentType: typeOfNextData,
+		Data:        data,
+	}, nil
+}
+
+func parseMapiCallbacks(b []byte, lim Limits) ([]*bc.MapiCallback, error) {
 	if len(b) == 0 {
 		return nil, ErrTriedToParseZeroBytes
 	}
-	var internalOffset uint64
-	allBinary := uint64(len(b))
-	numOfMapiResponses := b[internalOffset]
+	r := bc.NewDecoder(b, ErrTruncatedAncestry)
+	numOfMapiResponses, err := r.Byte("mapi response count")
+	if err != nil {
+		return nil, err
+	}
 	if numOfMapiResponses == 0 && len(b) == 1 {
 		return nil, ErrTriedToParseZeroBytes
 	}
-	internalOffset++
 
 	var responses = [][]byte{}
-	for allBinary > internalOffset {
-		l, size := bt.NewVarIntFromBytes(b[internalOffset:])
-		internalOffset += uint64(size)
-		response := b[internalOffset : internalOffset+uint64(l)]
-		internalOffset += uint64(l)
+	for r.Remaining() > 0 {
+		// the count is checked before the responses are parsed.
+		if err := lim.mapiResponses(len(responses) + 1); err != nil {
+			return nil, err
+		}
+		response, err := r.VarBytes("mapi response")
+		if err != nil {
+			return nil, err
+		}
 		responses = append(responses, response)
 	}
 
@@ -139,8 +169,3 @@ func parseMapiCallbacks(b []byte) ([]*bc.MapiCallback, error) {
 	}
 	return mapiResponses, nil
 }
-
-func verifyInputOutputPair(_ *bt.Tx, _ *bscript.Script, _ *bscript.Script) bool {
-	// TODO script interpreter.
-	return true
-}
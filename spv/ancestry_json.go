@@ -42,59 +42,73 @@ func (e *AncestryJSON) ParentTx(txID string) (*bt.Tx, error) {
 	return bt.NewTxFromString(env.RawTx)
 }
 
-// Bytes takes a TxAncestry struct and returns the serialised binary format.
+// TxGraph converts the ancestry to a TxGraph, holding each tx once however many times it is
+// nested. ErrCyclicAncestry is returned if a tx is nested beneath itself.
+func (e *AncestryJSON) TxGraph() (*TxGraph, error) {
+	g, _, _, err := ancestryJSONGraph(e)
+	return g, err
+}
+
+// Bytes takes a TxAncestry struct and returns the serialised binary format. The output is
+// canonical, each ancestor is written once, before its own ancestors, with parents in the
+// order of the inputs spending them, so the same ancestry always gives the same bytes.
 func (e *AncestryJSON) Bytes() ([]byte, error) {
 	ancestryBinary := make([]byte, 0)
 	ancestryBinary = append(ancestryBinary, 1) // Binary format version 1
-	binary, err := serialiseInputs(e.Parents)
+	// the tx the ancestry is for is not part of it, but its parents are, whether it has a proof or not.
+	root := &AncestryJSON{RawTx: e.RawTx, Parents: e.Parents}
+	order, err := canonicalOrder(root)
 	if err != nil {
 		return nil, err
 	}
-	ancestryBinary = append(ancestryBinary, binary...)
+	for _, input := range order[1:] {
+		binary, err := serialiseInput(input)
+		if err != nil {
+			return nil, err
+		}
+		ancestryBinary = append(ancestryBinary, binary...)
+	}
 	return ancestryBinary, nil
 }
 
-func serialiseInputs(parents map[string]*AncestryJSON) ([]byte, error) {
+// serialiseInput returns the binary format of a single ancestor, its tx followed by any mapi
+// responses and merkle proof.
+func serialiseInput(input *AncestryJSON) ([]byte, error) {
 	binary := make([]byte, 0)
-	for _, input := range parents {
-		currentTx, err := hex.DecodeString(input.RawTx)
-		if err != nil {
-			return nil, err
-		}
-		dataLength := bt.VarInt(uint64(len(currentTx)))
-		binary = append(binary, flagTx)                // first data will always be a rawTx.
-		binary = append(binary, dataLength.Bytes()...) // of this length.
-		binary = append(binary, currentTx...)          // the data.
-		if input.MapiResponses != nil && len(input.MapiResponses) > 0 {
-			binary = append(binary, flagMapi) // next data will be a mapi response.
-			numMapis := bt.VarInt(uint64(len(input.MapiResponses)))
-			binary = append(binary, numMapis.Bytes()...) // number of mapi reponses which follow
-			for _, mapiResponse := range input.MapiResponses {
-				mapiR, err := mapiResponse.Bytes()
-				if err != nil {
-					return nil, err
-				}
-				dataLength := bt.VarInt(uint64(len(mapiR)))
-				binary = append(binary, dataLength.Bytes()...) // of this length.
-				binary = append(binary, mapiR...)              // the data.
-			}
-		}
-		if input.Proof != nil {
-			proof, err := input.Proof.Bytes()
-			if err != nil {
-				return nil, errors.Wrap(err, "Failed to serialise this input's proof struct")
-			}
-			proofLength := bt.VarInt(uint64(len(proof)))
-			binary = append(binary, flagProof)              // it's going to be a proof.
-			binary = append(binary, proofLength.Bytes()...) // of this length.
-			binary = append(binary, proof...)               // the data.
-		} else if input.HasParents() {
-			parentsBinary, err := serialiseInputs(input.Parents)
+	currentTx, err := hex.DecodeString(input.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	dataLength := bt.VarInt(uint64(len(currentTx)))
+	binary = append(binary, flagTx)                // first data will always be a rawTx.
+	binary = append(binary, dataLength.Bytes()...) // of this length.
+	binary = append(binary, currentTx...)          // the data.
+	if input.MapiResponses != nil && len(input.MapiResponses) > 0 {
+		numMapis := bt.VarInt(uint64(len(input.MapiResponses)))
+		mapis := numMapis.Bytes() // number of mapi reponses which follow
+		for _, mapiResponse := range input.MapiResponses {
+			mapiR, err := mapiResponse.Bytes()
 			if err != nil {
 				return nil, err
 			}
-			binary = append(binary, parentsBinary...)
+			dataLength := bt.VarInt(uint64(len(mapiR)))
+			mapis = append(mapis, dataLength.Bytes()...) // of this length.
+			mapis = append(mapis, mapiR...)              // the data.
+		}
+		mapisLength := bt.VarInt(uint64(len(mapis)))
+		binary = append(binary, flagMapi)               // next data will be the mapi responses.
+		binary = append(binary, mapisLength.Bytes()...) // of this length.
+		binary = append(binary, mapis...)               // the data.
+	}
+	if input.Proof != nil {
+		proof, err := input.Proof.Bytes()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to serialise this input's proof struct")
 		}
+		proofLength := bt.VarInt(uint64(len(proof)))
+		binary = append(binary, flagProof)              // it's going to be a proof.
+		binary = append(binary, proofLength.Bytes()...) // of this length.
+		binary = append(binary, proof...)               // the data.
 	}
 	return binary, nil
 }
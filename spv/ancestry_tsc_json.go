@@ -5,6 +5,7 @@ import (
 
 	"github.com/libsv/go-bc"
 	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
 )
 
 const (
@@ -25,20 +26,23 @@ type TSCAncestryJSON struct {
 }
 
 // NewAncestryJSONFromBytes is a way to create the JSON format for Ancestry from the binary format.
-func NewAncestryJSONFromBytes(b []byte) (TSCAncestriesJSON, error) {
-	ancestry, err := parseAncestry(b)
+// It is rejected with ErrLimitExceeded if it exceeds the limits set, DefaultLimits by default.
+func NewAncestryJSONFromBytes(b []byte, opts ...DecodeOpt) (TSCAncestriesJSON, error) {
+	o := newDecodeOptions(opts)
+	g, err := parseAncestry(b, o.limits)
 	if err != nil {
 		return nil, err
 	}
+	// the ancestors are kept in the order of the binary, so encode back to the same bytes.
 	ancestors := make([]TSCAncestryJSON, 0)
-	for _, ancestor := range ancestry {
+	for _, ancestor := range g.Nodes() {
 		rawTx := ancestor.Tx.String()
 		a := TSCAncestryJSON{
 			RawTx:         rawTx,
 			MapiResponses: ancestor.MapiResponses,
 		}
 		if ancestor.Proof != nil {
-			mpb, err := parseBinaryMerkleProof(ancestor.Proof)
+			mpb, err := parseBinaryMerkleProof(ancestor.Proof, o.limits)
 			if err != nil {
 				return nil, err
 			}
@@ -55,6 +59,23 @@ func NewAncestryJSONFromBytes(b []byte) (TSCAncestriesJSON, error) {
 	return ancestors, nil
 }
 
+// TxGraph converts the ancestors to a TxGraph, ErrDuplicateTx is returned if a transaction
+// appears more than once.
+func (j TSCAncestriesJSON) TxGraph() (*TxGraph, error) {
+	g := NewTxGraph()
+	for i, ancestor := range j {
+		n, err := newTxNode(ancestor.RawTx, ancestor.Proof)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "ancestor %d", i)
+		}
+		n.MapiResponses = ancestor.MapiResponses
+		if err := g.Add(n); err != nil {
+			return nil, errors.WithMessagef(err, "ancestor %d", i)
+		}
+	}
+	return g, nil
+}
+
 // Bytes takes an AncestryJSON and returns the serialised bytes.
 func (j TSCAncestriesJSON) Bytes() ([]byte, error) {
 	binaryTxContext := make([]byte, 0)
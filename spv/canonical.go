@@ -0,0 +1,139 @@
+package spv
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+)
+
+// canonicalOrder returns the txs of the ancestry a, starting with a, so it always serialises
+// to the same bytes however it was built. Each tx is returned once, before any of its
+// ancestors, with parents ordered by the inputs of the tx spending them. The parents of a tx
+// with a merkle proof are not needed to verify it, so are left out.
+//
+// A tx is the parent of any other in the ancestry spending it, wherever either is nested, so
+// an ancestor shared by two txs is ordered the same whether it is given under one or both.
+// Any txs which are not ancestors of a follow its ancestors, ordered by txid.
+func canonicalOrder(a *AncestryJSON) ([]*AncestryJSON, error) {
+	g, from, roots, err := ancestryJSONGraph(a)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := g.order(roots)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) < g.Len() {
+		ordered := make(map[[32]byte]bool, len(ids))
+		for _, id := range ids {
+			ordered[id] = true
+		}
+		var others [][32]byte
+		for _, id := range g.ids {
+			if !ordered[id] {
+				others = append(others, id)
+			}
+		}
+		sort.Slice(others, func(i, j int) bool {
+			return bytes.Compare(others[i][:], others[j][:]) < 0
+		})
+		if ids, err = g.order(append(roots, others...)); err != nil {
+			return nil, err
+		}
+	}
+	order := make([]*AncestryJSON, 0, len(ids))
+	for _, id := range ids {
+		order = append(order, from[id])
+	}
+	return order, nil
+}
+
+// ancestryJSONGraph converts the ancestry a, and those nested in it, to a TxGraph, returning
+// with it the ancestry each tx was converted from, and the id of a. A tx which appears more
+// than once, as an ancestor shared by two txs can, is added once, but a tx nested beneath
+// itself is rejected with ErrCyclicAncestry. The parents of a tx with a merkle proof are not
+// needed to verify it so are left out.
+//
+// The tx an ancestry is for can be left out, the ids of its parents, ordered by their key,
+// are then returned in place of its id.
+func ancestryJSONGraph(a *AncestryJSON) (*TxGraph, map[[32]byte]*AncestryJSON, [][32]byte, error) {
+	g := NewTxGraph()
+	from := make(map[[32]byte]*AncestryJSON)
+	nested := make(map[[32]byte]bool)
+	var add func(a *AncestryJSON) ([32]byte, error)
+	add = func(a *AncestryJSON) ([32]byte, error) {
+		n, err := newTxNode(a.RawTx, a.Proof)
+		if err != nil {
+			return [32]byte{}, errors.WithMessagef(err, "failed to decode raw tx %s", a.TxID)
+		}
+		id := txGraphID(n.Tx)
+		if nested[id] {
+			return id, errors.Wrapf(ErrCyclicAncestry, "tx %s is its own ancestor", n.Tx.TxID())
+		}
+		if _, ok := from[id]; ok {
+			return id, nil
+		}
+		for i := range a.MapiResponses {
+			n.MapiResponses = append(n.MapiResponses, &a.MapiResponses[i])
+		}
+		if err := g.Add(n); err != nil {
+			return id, err
+		}
+		from[id] = a
+		if a.Proof != nil {
+			return id, nil
+		}
+		nested[id] = true
+		for _, parent := range orderedParents(a.Parents, n.Tx) {
+			if _, err := add(parent); err != nil {
+				return id, err
+			}
+		}
+		delete(nested, id)
+		return id, nil
+	}
+
+	parents := []*AncestryJSON{a}
+	if a.RawTx == "" {
+		parents = orderedParents(a.Parents, bt.NewTx())
+	}
+	roots := make([][32]byte, 0, len(parents))
+	for _, parent := range parents {
+		id, err := add(parent)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		roots = append(roots, id)
+	}
+	return g, from, roots, nil
+}
+
+// orderedParents returns the parents of tx in the order of the inputs spending them. Any
+// parents not spent by an input follow, ordered by their key. Parents without a tx, as
+// decoders leave for inputs whose parents are not in the ancestry, are skipped.
+func orderedParents(parents map[string]*AncestryJSON, tx *bt.Tx) []*AncestryJSON {
+	ordered := make([]*AncestryJSON, 0, len(parents))
+	added := make(map[string]bool, len(parents))
+	for _, input := range tx.Inputs {
+		id := input.PreviousTxIDStr()
+		parent, ok := parents[id]
+		if !ok || parent == nil || parent.RawTx == "" || added[id] {
+			continue
+		}
+		added[id] = true
+		ordered = append(ordered, parent)
+	}
+	keys := make([]string, 0)
+	for id, parent := range parents {
+		if parent != nil && parent.RawTx != "" && !added[id] {
+			keys = append(keys, id)
+		}
+	}
+	sort.Strings(keys)
+	for _, id := range keys {
+		ordered = append(ordered, parents[id])
+	}
+	return ordered
+}
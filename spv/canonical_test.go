@@ -0,0 +1,159 @@
+package spv_test
+
+import (
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/libsv/go-bt/v2/bscript"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc/spv"
+)
+
+// spend returns a tx spending the first output of each of parents, or of an unknown tx if
+// there are none, with outputs outputs.
+func spend(t *testing.T, outputs int, parents ...*bt.Tx) *bt.Tx {
+	tx := bt.NewTx()
+	if len(parents) == 0 {
+		require.NoError(t, tx.From("4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", 0, "51", 1000))
+	}
+	for _, parent := range parents {
+		require.NoError(t, tx.From(parent.TxID(), 0, "51", parent.Outputs[0].Satoshis))
+	}
+	for i := 0; i < outputs; i++ {
+		tx.AddOutput(&bt.Output{Satoshis: uint64(100 + i), LockingScript: bscript.NewFromBytes([]byte{0x51})})
+	}
+	return tx
+}
+
+func ancestryOf(tx *bt.Tx, parents ...*spv.AncestryJSON) *spv.AncestryJSON {
+	a := &spv.AncestryJSON{TxID: tx.TxID(), RawTx: tx.String()}
+	if len(parents) > 0 {
+		a.Parents = make(map[string]*spv.AncestryJSON)
+		for _, parent := range parents {
+			a.Parents[parent.TxID] = parent
+		}
+	}
+	return a
+}
+
+func TestAncestryJSON_Bytes_Canonical(t *testing.T) {
+	// g is a grandparent shared by a and b, y is a parent of both x and the payment.
+	g := spend(t, 2)
+	a := spend(t, 1, g)
+	b := spend(t, 2, g)
+	y := spend(t, 1)
+	x := spend(t, 1, y)
+
+	tests := map[string]struct {
+		ancestry func() *spv.AncestryJSON
+		expTxs   []*bt.Tx
+		expErr   error
+	}{
+		"shared ancestor written once": {
+			ancestry: func() *spv.AncestryJSON {
+				return ancestryOf(spend(t, 1, a, b), ancestryOf(a, ancestryOf(g)), ancestryOf(b, ancestryOf(g)))
+			},
+			expTxs: []*bt.Tx{a, b, g},
+		},
+		"parents in input order": {
+			ancestry: func() *spv.AncestryJSON {
+				return ancestryOf(spend(t, 1, b, a), ancestryOf(a, ancestryOf(g)), ancestryOf(b, ancestryOf(g)))
+			},
+			expTxs: []*bt.Tx{b, a, g},
+		},
+		"child before its parent": {
+			ancestry: func() *spv.AncestryJSON {
+				return ancestryOf(spend(t, 1, y, x), ancestryOf(y), ancestryOf(x, ancestryOf(y)))
+			},
+			expTxs: []*bt.Tx{x, y},
+		},
+		"tx which is its own ancestor": {
+			ancestry: func() *spv.AncestryJSON {
+				aa := ancestryOf(a)
+				ga := ancestryOf(g, aa)
+				aa.Parents = map[string]*spv.AncestryJSON{g.TxID(): ga}
+				return ancestryOf(spend(t, 1, a), aa)
+			},
+			expErr: spv.ErrCyclicAncestry,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ancestry := test.ancestry()
+			exp, err := ancestry.Bytes()
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			// maps are iterated in a random order, so each run would differ were they used.
+			for i := 0; i < 50; i++ {
+				b, err := test.ancestry().Bytes()
+				require.NoError(t, err)
+				require.Equal(t, exp, b)
+			}
+
+			tsc, err := spv.NewAncestryJSONFromBytes(exp)
+			require.NoError(t, err)
+			txs := make([]string, 0, len(tsc))
+			for _, a := range tsc {
+				txs = append(txs, a.RawTx)
+			}
+			expTxs := make([]string, 0, len(test.expTxs))
+			for _, tx := range test.expTxs {
+				expTxs = append(expTxs, tx.String())
+			}
+			require.Equal(t, expTxs, txs)
+
+			b, err := tsc.Bytes()
+			require.NoError(t, err)
+			require.Equal(t, exp, b)
+		})
+	}
+}
+
+func TestEnvelope_Bytes_Canonical(t *testing.T) {
+	grandparent := spend(t, 2)
+	parent1 := spend(t, 1, grandparent)
+	parent2 := spend(t, 2, grandparent)
+	envelope := func() *spv.Envelope {
+		envelopeOf := func(tx *bt.Tx, parents ...*spv.Envelope) *spv.Envelope {
+			e := &spv.Envelope{TxID: tx.TxID(), RawTx: tx.String(), Parents: map[string]*spv.Envelope{}}
+			for _, parent := range parents {
+				e.Parents[parent.TxID] = parent
+			}
+			return e
+		}
+		return envelopeOf(spend(t, 1, parent1, parent2),
+			envelopeOf(parent1, envelopeOf(grandparent)), envelopeOf(parent2, envelopeOf(grandparent)))
+	}
+
+	crunchyNut, err := envelope().CrunchyNutBytes()
+	require.NoError(t, err)
+	specialK, err := envelope().SpecialKBytes()
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		b, err := envelope().CrunchyNutBytes()
+		require.NoError(t, err)
+		require.Equal(t, *crunchyNut, *b)
+
+		b, err = envelope().SpecialKBytes()
+		require.NoError(t, err)
+		require.Equal(t, *specialK, *b)
+	}
+
+	e, err := spv.NewCrunchyNutEnvelopeFromBytes(*crunchyNut)
+	require.NoError(t, err)
+	b, err := e.CrunchyNutBytes()
+	require.NoError(t, err)
+	require.Equal(t, *crunchyNut, *b)
+
+	e, err = spv.NewSpecialKEnvelopeFromBytes(*specialK)
+	require.NoError(t, err)
+	b, err = e.SpecialKBytes()
+	require.NoError(t, err)
+	require.Equal(t, *specialK, *b)
+}
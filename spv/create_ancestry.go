@@ -10,6 +10,12 @@ import (
 
 // CreateTxAncestry builds and returns an spv.TxAncestry for the provided tx.
 func (c *creator) CreateTxAncestry(ctx context.Context, tx *bt.Tx) (*AncestryJSON, error) {
+	return c.createTxAncestry(ctx, tx, make(map[string]*AncestryJSON))
+}
+
+// createTxAncestry builds the ancestry of tx, built holds the ancestries already built so an
+// ancestor shared by several txs is only fetched once, and the same ancestry given for each.
+func (c *creator) createTxAncestry(ctx context.Context, tx *bt.Tx, built map[string]*AncestryJSON) (*AncestryJSON, error) {
 	if len(tx.Inputs) == 0 {
 		return nil, ErrNoTxInputs
 	}
@@ -28,6 +34,10 @@ func (c *creator) CreateTxAncestry(ctx context.Context, tx *bt.Tx) (*AncestryJSO
 		if _, ok := ancestry.Parents[pTxID]; ok {
 			continue
 		}
+		if parent, ok := built[pTxID]; ok {
+			ancestry.Parents[pTxID] = parent
+			continue
+		}
 
 		// Build a *bt.Tx from its TxID and recursively call this function building
 		// for inputs without proofs, until a parent with a Merkle Proof is found.
@@ -51,15 +61,17 @@ func (c *creator) CreateTxAncestry(ctx context.Context, tx *bt.Tx) (*AncestryJSO
 				TxID:  pTxID,
 				Proof: mp,
 			}
+			built[pTxID] = ancestry.Parents[pTxID]
 			continue
 		}
 
-		pEnvelope, err := c.CreateTxAncestry(ctx, pTx)
+		pEnvelope, err := c.createTxAncestry(ctx, pTx, built)
 		if err != nil {
 			return nil, err
 		}
 
 		ancestry.Parents[pTxID] = pEnvelope
+		built[pTxID] = pEnvelope
 	}
 
 	return ancestry, nil
@@ -2,7 +2,6 @@ package spv
 
 import (
 	"encoding/hex"
-	"fmt"
 	"sync"
 
 	"github.com/libsv/go-bt/v2"
@@ -41,147 +40,195 @@ func (e *Envelope) ParentTX(txID string) (*bt.Tx, error) {
 	return bt.NewTxFromString(env.RawTx)
 }
 
+// TxGraph converts the envelope to a TxGraph, holding each tx once however many times it is
+// nested. ErrCyclicAncestry is returned if a tx is nested beneath itself.
+func (e *Envelope) TxGraph() (*TxGraph, error) {
+	g, _, _, err := ancestryJSONGraph(e.ancestryJSON(map[*Envelope]*AncestryJSON{}))
+	return g, err
+}
+
 // CrunchyNutBytes takes an spvEnvelope struct and returns a pointer to the serialised bytes.
+// The output is canonical, parents follow the tx spending them in the order of its inputs.
+// As the format nests parents within each child, an ancestor shared by two txs is written
+// for each of them.
 func (e *Envelope) CrunchyNutBytes() (*[]byte, error) {
 	flake := make([]byte, 0)
 
 	// Binary format version 1
 	flake = append(flake, 1)
 
-	initialTx := map[string]*Envelope{
-		e.TxID: {
-			TxID:          e.TxID,
-			RawTx:         e.RawTx,
-			Proof:         e.Proof,
-			MapiResponses: e.MapiResponses,
-			Parents:       e.Parents,
-		},
-	}
-
-	err := serialiseCrunchyNutInputs(initialTx, &flake)
-	if err != nil {
-		fmt.Println(err)
+	if err := serialiseCrunchyNutInputs(e.ancestryJSON(map[*Envelope]*AncestryJSON{}), &flake, map[*AncestryJSON]bool{}); err != nil {
+		return nil, err
 	}
 	return &flake, nil
 }
 
-// serialiseCrunchyNutInputs is a recursive input serialiser for spv Envelopes.
-func serialiseCrunchyNutInputs(parents map[string]*Envelope, flake *[]byte) error {
-	for _, input := range parents {
-		currentTx, err := hex.DecodeString(input.RawTx)
-		if err != nil {
-			fmt.Print(err)
-		}
-		dataLength := bt.VarInt(uint64(len(currentTx)))
-		*flake = append(*flake, flagTx)                // first data will always be a rawTx.
-		*flake = append(*flake, dataLength.Bytes()...) // of this length.
-		*flake = append(*flake, currentTx...)          // the data.
-		if input.MapiResponses != nil && len(input.MapiResponses) > 0 {
-			for _, mapiResponse := range input.MapiResponses {
-				mapiR, err := mapiResponse.Bytes()
-				if err != nil {
-					return err
-				}
-				dataLength := bt.VarInt(uint64(len(mapiR)))
-				*flake = append(*flake, flagMapi)              // next data will be a mapi response.
-				*flake = append(*flake, dataLength.Bytes()...) // of this length.
-				*flake = append(*flake, mapiR...)              // the data.
-			}
-		}
-		if input.Proof != nil {
-			proof, err := input.Proof.Bytes()
+// serialiseCrunchyNutInputs is a recursive input serialiser for spv Envelopes, path holds the
+// envelopes being serialised so a malformed envelope which is its own parent is rejected.
+func serialiseCrunchyNutInputs(input *AncestryJSON, flake *[]byte, path map[*AncestryJSON]bool) error {
+	if path[input] {
+		return errors.Wrapf(ErrCyclicAncestry, "tx %s is its own ancestor", input.TxID)
+	}
+	currentTx, err := hex.DecodeString(input.RawTx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode raw tx %s", input.TxID)
+	}
+	dataLength := bt.VarInt(uint64(len(currentTx)))
+	*flake = append(*flake, flagTx)                // first data will always be a rawTx.
+	*flake = append(*flake, dataLength.Bytes()...) // of this length.
+	*flake = append(*flake, currentTx...)          // the data.
+	if input.MapiResponses != nil && len(input.MapiResponses) > 0 {
+		for _, mapiResponse := range input.MapiResponses {
+			mapiR, err := mapiResponse.Bytes()
 			if err != nil {
-				return errors.Wrap(err, "Failed to serialise this input's proof struct")
+				return err
 			}
-			proofLength := bt.VarInt(uint64(len(proof)))
-			*flake = append(*flake, flagProof)              // it's going to be a proof.
-			*flake = append(*flake, proofLength.Bytes()...) // of this length.
-			*flake = append(*flake, proof...)               // the data.
-		} else if input.HasParents() {
-			return serialiseCrunchyNutInputs(input.Parents, flake)
+			dataLength := bt.VarInt(uint64(len(mapiR)))
+			*flake = append(*flake, flagMapi)              // next data will be a mapi response.
+			*flake = append(*flake, dataLength.Bytes()...) // of this length.
+			*flake = append(*flake, mapiR...)              // the data.
 		}
 	}
+	if input.Proof != nil {
+		proof, err := input.Proof.Bytes()
+		if err != nil {
+			return errors.Wrap(err, "Failed to serialise this input's proof struct")
+		}
+		proofLength := bt.VarInt(uint64(len(proof)))
+		*flake = append(*flake, flagProof)              // it's going to be a proof.
+		*flake = append(*flake, proofLength.Bytes()...) // of this length.
+		*flake = append(*flake, proof...)               // the data.
+		return nil
+	}
+	if !input.HasParents() {
+		return nil
+	}
+	tx, err := newTxFromBytes(currentTx)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to decode raw tx %s", input.TxID)
+	}
+	path[input] = true
+	for _, parent := range orderedParents(input.Parents, tx) {
+		if err := serialiseCrunchyNutInputs(parent, flake, path); err != nil {
+			return err
+		}
+	}
+	delete(path, input)
 	return nil
 }
 
 // NewCrunchyNutEnvelopeFromBytes will encode an spv envelope byte slice into the Envelope structure.
-func NewCrunchyNutEnvelopeFromBytes(b []byte) (*Envelope, error) {
+// It is rejected with ErrLimitExceeded if it exceeds the limits set, DefaultLimits by default.
+func NewCrunchyNutEnvelopeFromBytes(b []byte, opts ...DecodeOpt) (*Envelope, error) {
 	var envelope Envelope
-	var offset uint64
+	o := newDecodeOptions(opts)
+	if err := o.limits.size(len(b)); err != nil {
+		return nil, err
+	}
+	r := bc.NewDecoder(b, ErrTruncatedAncestry)
 
 	// the first byte is the version number.
-	version := b[offset]
+	version, err := r.Byte("version")
+	if err != nil {
+		return nil, err
+	}
 	if version != 1 {
 		return nil, errors.New("We can only handle version 1 of the SPV Envelope Binary format")
 	}
-	offset++
-	parseCrunchyNutFlakesRecursively(b, &offset, &envelope)
+	if err := parseCrunchyNutFlakesRecursively(r, &envelope, &envelopeDecoder{limits: o.limits, depth: 1}); err != nil {
+		return nil, err
+	}
 	return &envelope, nil
 }
 
+// envelopeDecoder tracks what has been decoded of an envelope to keep it within its limits.
+type envelopeDecoder struct {
+	limits Limits
+	txs    int
+	// depth is the depth of the envelope being decoded, the payment being 1.
+	depth int
+}
+
 // parseCrunchyNutChunksRecursively will identify the next chunk of data's type and length,
 // and pull out the stream into the appropriate struct.
-func parseCrunchyNutFlakesRecursively(b []byte, offset *uint64, eCurrent *Envelope) {
-	typeOfNextData := b[*offset]
-	*offset++
-	l, size := bt.NewVarIntFromBytes(b[*offset:])
-	*offset += uint64(size)
-	switch typeOfNextData {
-	case flagTx:
-		tx, err := bt.NewTxFromBytes(b[*offset : *offset+uint64(l)])
+func parseCrunchyNutFlakesRecursively(r *bc.Decoder, eCurrent *Envelope, d *envelopeDecoder) error {
+	for {
+		offset := r.Offset()
+		chunk, err := parseChunk(r)
 		if err != nil {
-			fmt.Println(err)
-		}
-		txid := tx.TxID()
-		inputs := map[string]*Envelope{}
-		for _, input := range tx.Inputs {
-			inputs[input.PreviousTxIDStr()] = &Envelope{}
+			return err
 		}
-		eCurrent.TxID = txid
-		eCurrent.RawTx = tx.String()
-		*offset += uint64(l)
-		if uint64(len(b)) > *offset && b[*offset] != flagTx {
-			parseCrunchyNutFlakesRecursively(b, offset, eCurrent)
-		} else {
-			eCurrent.Parents = inputs
-		}
-		for _, input := range inputs {
-			if uint64(len(b)) > *offset {
-				parseCrunchyNutFlakesRecursively(b, offset, input)
+		switch chunk.ContentType {
+		case flagTx:
+			d.txs++
+			if err := d.limits.txs(d.txs); err != nil {
+				return err
+			}
+			tx, err := newTxFromBytes(chunk.Data)
+			if err != nil {
+				return errors.WithMessagef(err, "tx at offset %d", offset)
+			}
+			txid := tx.TxID()
+			// parents follow in the order of the inputs spending them.
+			inputs := map[string]*Envelope{}
+			ids := make([]string, 0, len(tx.Inputs))
+			for _, input := range tx.Inputs {
+				id := input.PreviousTxIDStr()
+				if _, ok := inputs[id]; !ok {
+					inputs[id] = &Envelope{}
+					ids = append(ids, id)
+				}
+			}
+			eCurrent.TxID = txid
+			eCurrent.RawTx = tx.String()
+			if next, err := r.Peek("chunk type"); err == nil && next != flagTx {
+				if err := parseCrunchyNutFlakesRecursively(r, eCurrent, d); err != nil {
+					return err
+				}
+			} else {
+				eCurrent.Parents = inputs
+			}
+			d.depth++
+			for _, id := range ids {
+				if r.Remaining() > 0 {
+					if err := d.limits.depth(d.depth); err != nil {
+						return err
+					}
+					if err := parseCrunchyNutFlakesRecursively(r, inputs[id], d); err != nil {
+						return err
+					}
+				}
+			}
+			d.depth--
+		case flagProof:
+			binaryProof, err := parseBinaryMerkleProof(chunk.Data, d.limits)
+			if err != nil {
+				return errors.WithMessagef(err, "merkle proof at offset %d", offset)
+			}
+			proof := bc.MerkleProof{
+				Index:      binaryProof.index,
+				TxOrID:     binaryProof.txOrID,
+				Target:     binaryProof.target,
+				Nodes:      binaryProof.nodes,
+				TargetType: flagType(binaryProof.flags),
+				// ignoring proofType and compositeType for this version.
+			}
+			eCurrent.Proof = &proof
+		case flagMapi:
+			if err := d.limits.mapiResponses(len(eCurrent.MapiResponses) + 1); err != nil {
+				return err
+			}
+			mapiResponse, err := bc.NewMapiCallbackFromBytes(chunk.Data)
+			if err != nil {
+				return errors.Wrapf(err, "mapi response at offset %d", offset)
 			}
-		}
-	case flagProof:
-		binaryProof, err := parseBinaryMerkleProof(b[*offset : *offset+uint64(l)])
-		if err != nil {
-			fmt.Println(err)
-		}
-		proof := bc.MerkleProof{
-			Index:      binaryProof.index,
-			TxOrID:     binaryProof.txOrID,
-			Target:     binaryProof.target,
-			Nodes:      binaryProof.nodes,
-			TargetType: flagType(binaryProof.flags),
-			// ignoring proofType and compositeType for this version.
-		}
-		eCurrent.Proof = &proof
-		*offset += uint64(l)
-	case flagMapi:
-		mapiResponse, err := bc.NewMapiCallbackFromBytes(b[*offset : *offset+uint64(l)])
-		if err != nil {
-			fmt.Println(err)
-		}
-		if eCurrent.MapiResponses != nil {
 			eCurrent.MapiResponses = append(eCurrent.MapiResponses, *mapiResponse)
-		} else {
-			eCurrent.MapiResponses = []bc.MapiCallback{*mapiResponse}
+		default:
+			// unknown data types are skipped.
+		}
+		if r.Remaining() == 0 {
+			return nil
 		}
-		*offset += uint64(l)
-	default:
-		fmt.Printf("Unknown data type: %v, used for: %v", typeOfNextData, b[*offset:*offset+uint64(l)])
-		*offset += uint64(l)
-	}
-	if uint64(len(b)) > *offset {
-		parseCrunchyNutFlakesRecursively(b, offset, eCurrent)
 	}
 }
 
@@ -200,134 +247,150 @@ func flagType(flags byte) string {
 }
 
 // SpecialKBytes takes an spvEnvelope struct and returns a pointer to the serialised bytes.
+// The output is canonical, each tx is written once, before its own ancestors, with parents
+// in the order of the inputs spending them.
 func (e *Envelope) SpecialKBytes() (*[]byte, error) {
 	flake := make([]byte, 0)
 
 	// Binary format version 1
 	flake = append(flake, 1)
 
-	initialTx := map[string]*Envelope{
-		e.TxID: {
-			TxID:          e.TxID,
-			RawTx:         e.RawTx,
-			Proof:         e.Proof,
-			MapiResponses: e.MapiResponses,
-			Parents:       e.Parents,
-		},
-	}
-
-	err := serialiseSpecialKInputs(initialTx, &flake)
+	order, err := canonicalOrder(e.ancestryJSON(map[*Envelope]*AncestryJSON{}))
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
+	}
+	for _, input := range order {
+		if err := serialiseSpecialKInput(input, &flake); err != nil {
+			return nil, err
+		}
 	}
 	return &flake, nil
 }
 
-// serialiseSpecialKInputs is a recursive input serialiser for spv Envelopes.
-func serialiseSpecialKInputs(parents map[string]*Envelope, flake *[]byte) error {
-	for _, input := range parents {
-		currentTx, err := hex.DecodeString(input.RawTx)
+// serialiseSpecialKInput serialises the tx, proof and mapi responses of a single envelope.
+func serialiseSpecialKInput(input *AncestryJSON, flake *[]byte) error {
+	currentTx, err := hex.DecodeString(input.RawTx)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode raw tx %s", input.TxID)
+	}
+	// the transaction itself
+	dataLength := bt.VarInt(uint64(len(currentTx)))
+	*flake = append(*flake, dataLength.Bytes()...) // of this length.
+	*flake = append(*flake, currentTx...)          // the data.
+
+	// proof or zero
+	if input.Proof == nil {
+		*flake = append(*flake, 0)
+	} else {
+		proof, err := input.Proof.Bytes()
 		if err != nil {
-			fmt.Print(err)
-		}
-		// the transaction itself
-		dataLength := bt.VarInt(uint64(len(currentTx)))
-		*flake = append(*flake, dataLength.Bytes()...) // of this length.
-		*flake = append(*flake, currentTx...)          // the data.
-
-		// proof or zero
-		if input.Proof == nil {
-			*flake = append(*flake, 0)
-		} else {
-			proof, err := input.Proof.Bytes()
-			if err != nil {
-				return errors.Wrap(err, "Failed to serialise this input's proof struct")
-			}
-			proofLength := bt.VarInt(uint64(len(proof)))
-			*flake = append(*flake, proofLength.Bytes()...) // of this length.
-			*flake = append(*flake, proof...)               // the data.
+			return errors.Wrap(err, "Failed to serialise this input's proof struct")
 		}
+		proofLength := bt.VarInt(uint64(len(proof)))
+		*flake = append(*flake, proofLength.Bytes()...) // of this length.
+		*flake = append(*flake, proof...)               // the data.
+	}
 
-		if input.MapiResponses == nil || len(input.MapiResponses) == 0 {
-			*flake = append(*flake, 0)
-		} else {
-			numOfMapiResponses := bt.VarInt(uint64(len(input.MapiResponses)))
-			var mapiResponsesBinary []byte
-			mapiResponsesBinary = append(mapiResponsesBinary, numOfMapiResponses.Bytes()...) // this many mapi responses follow
-			for _, mapiResponse := range input.MapiResponses {
-				mapiR, err := mapiResponse.Bytes()
-				if err != nil {
-					return err
-				}
-				dataLength := bt.VarInt(uint64(len(mapiR)))
-				mapiResponsesBinary = append(mapiResponsesBinary, dataLength.Bytes()...) // of this length.
-				mapiResponsesBinary = append(mapiResponsesBinary, mapiR...)              // the data.
-			}
-			fullDataLength := bt.VarInt(uint64(len(mapiResponsesBinary)))
-			*flake = append(*flake, fullDataLength.Bytes()...)
-			*flake = append(*flake, mapiResponsesBinary...)
+	if input.MapiResponses == nil || len(input.MapiResponses) == 0 {
+		*flake = append(*flake, 0)
+		return nil
+	}
+	numOfMapiResponses := bt.VarInt(uint64(len(input.MapiResponses)))
+	var mapiResponsesBinary []byte
+	mapiResponsesBinary = append(mapiResponsesBinary, numOfMapiResponses.Bytes()...) // this many mapi responses follow
+	for _, mapiResponse := range input.MapiResponses {
+		mapiR, err := mapiResponse.Bytes()
+		if err != nil {
+			return err
 		}
+		dataLength := bt.VarInt(uint64(len(mapiR)))
+		mapiResponsesBinary = append(mapiResponsesBinary, dataLength.Bytes()...) // of this length.
+		mapiResponsesBinary = append(mapiResponsesBinary, mapiR...)              // the data.
+	}
+	fullDataLength := bt.VarInt(uint64(len(mapiResponsesBinary)))
+	*flake = append(*flake, fullDataLength.Bytes()...)
+	*flake = append(*flake, mapiResponsesBinary...)
+	return nil
+}
 
-		if input.Proof == nil && input.HasParents() {
-			return serialiseSpecialKInputs(input.Parents, flake)
+// ancestryJSON returns the envelope as an AncestryJSON, which has the same fields, so both
+// are serialised in the same canonical order. seen holds those already converted.
+func (e *Envelope) ancestryJSON(seen map[*Envelope]*AncestryJSON) *AncestryJSON {
+	if a, ok := seen[e]; ok {
+		return a
+	}
+	a := &AncestryJSON{
+		TxID:          e.TxID,
+		RawTx:         e.RawTx,
+		Proof:         e.Proof,
+		MapiResponses: e.MapiResponses,
+	}
+	seen[e] = a
+	if e.Parents != nil {
+		a.Parents = make(map[string]*AncestryJSON, len(e.Parents))
+		for id, parent := range e.Parents {
+			if parent != nil {
+				a.Parents[id] = parent.ancestryJSON(seen)
+			}
 		}
 	}
-	return nil
+	return a
 }
 
 // NewSpecialKEnvelopeFromBytes will encode an spv envelope byte slice into the Envelope structure.
-func NewSpecialKEnvelopeFromBytes(b []byte) (*Envelope, error) {
-	allBinary := uint64(len(b))
+// It is rejected with ErrLimitExceeded if it exceeds the limits set, DefaultLimits by default.
+func NewSpecialKEnvelopeFromBytes(b []byte, opts ...DecodeOpt) (*Envelope, error) {
 	var envelope Envelope
-	var offset uint64
+	o := newDecodeOptions(opts)
+	if err := o.limits.size(len(b)); err != nil {
+		return nil, err
+	}
+	r := bc.NewDecoder(b, ErrTruncatedAncestry)
 
 	// the first byte is the version number.
-	version := b[offset]
+	version, err := r.Byte("version")
+	if err != nil {
+		return nil, err
+	}
 	if version != 1 {
 		return nil, errors.New("We can only handle version 1 of the SPV Envelope Binary format")
 	}
-	offset++
 
 	// split up the binary into flakes where each one is to be processed concurrently.
 	var flakes = [][]byte{}
-	for ok := true; ok; ok = allBinary > offset {
-		l, size := bt.NewVarIntFromBytes(b[offset:])
-		offset += uint64(size)
-		flake := b[offset : offset+uint64(l)]
-		offset += uint64(l)
+	for ok := true; ok; ok = r.Remaining() > 0 {
+		flake, err := r.VarBytes("flake")
+		if err != nil {
+			return nil, err
+		}
 		flakes = append(flakes, flake)
+		// each tx has a flake for it, its proof and its mapi responses.
+		if err := o.limits.txs((len(flakes) + 2) / 3); err != nil {
+			return nil, err
+		}
 	}
 
 	mapiCallbackChan := make(chan []bc.MapiCallback)
-	proofChan := make(chan *bc.MerkleProof)
-	txChan := make(chan *bt.Tx)
+	proofChan := make(chan specialKProof)
 	done := make(chan bool)
 
-	txs := make(map[string]*bt.Tx)
-	proofs := make(map[string]*bc.MerkleProof)
+	// txs are held in the order they were serialised, the flake of each tx being followed by
+	// those of its proof and mapi responses.
+	txs := make([]*bt.Tx, (len(flakes)+2)/3)
+	proofs := make(map[string]specialKProof)
 	mapiCallbacks := make(map[string][]bc.MapiCallback)
 
 	wg := sync.WaitGroup{}
+	// errs holds the error of each flake, so the first in the envelope is returned.
+	errs := make([]error, len(flakes))
 
 	// listen to these channels in perpetuity until we're done.
 	go func() {
 	L:
 		for {
 			select {
-			case tx := <-txChan:
-				txs[tx.TxID()] = tx
 			case proof := <-proofChan:
-				txid := proof.TxOrID
-				if txid != "" {
-					if len(txid) > 64 {
-						tr, err := bt.NewTxFromString(txid)
-						if err != nil {
-							fmt.Println(err)
-						}
-						txid = tr.TxID()
-					}
-					proofs[txid] = proof
-				}
+				proofs[proof.txid] = proof
 			case mcbs := <-mapiCallbackChan:
 				if len(mcbs) > 0 {
 					txid := (mcbs)[0].CallbackTxID
@@ -349,129 +412,131 @@ func NewSpecialKEnvelopeFromBytes(b []byte) (*Envelope, error) {
 			defer wg.Done()
 			switch idx % 3 {
 			case 2:
-				mcb, err := parseSpecialKMapi(flake)
+				mcb, err := parseSpecialKMapi(flake, o.limits)
 				if err != nil {
-					fmt.Println(err)
+					errs[idx] = errors.WithMessagef(err, "flake %d", idx)
+					return
 				}
 				mapiCallbackChan <- mcb
 			case 1:
-				proof, err := parseSpecialKProof(flake)
+				proof, err := parseSpecialKProof(flake, o.limits)
 				if err != nil {
-					fmt.Println(err)
+					errs[idx] = errors.WithMessagef(err, "flake %d", idx)
+					return
 				}
-				proofChan <- proof
+				if proof.TxOrID == "" {
+					return
+				}
+				txid, err := txidFromTxOrID(proof.TxOrID)
+				if err != nil {
+					errs[idx] = errors.WithMessagef(err, "flake %d", idx)
+					return
+				}
+				proofChan <- specialKProof{txid: txid, proof: proof, raw: flake}
 			case 0:
 				tx, err := parseSpecialKFlakeTx(flake)
 				if err != nil {
-					fmt.Println(err)
-				}
-				txid := tx.TxID()
-				if idx == 0 {
-					envelope.TxID = txid
-					envelope.RawTx = tx.String()
-					inputs := make(map[string]*Envelope)
-					for _, input := range tx.Inputs {
-						inputs[input.PreviousTxIDStr()] = &Envelope{}
-					}
-					envelope.Parents = inputs
+					errs[idx] = errors.WithMessagef(err, "flake %d", idx)
+					return
 				}
-				txChan <- tx
+				txs[idx/3] = tx
 			}
 		}(flake, idx)
 	}
 
 	wg.Wait()
 	done <- true
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	if txs[0] == nil {
+		return nil, errors.New("envelope has no tx")
+	}
 
 	// construct something useful
-	// iterate through all the transactions, addiong them to the struct's Parents
-	// if they're in the struct delete them, if they're not then leave them, and go one input deep.
-	// then run through again until they're all done.
-	// // keep building struct unless all txs are in the struct.
-	for ok := true; ok; ok = len(txs) > 0 {
-		// iterate through txs
-		for txid, tx := range txs {
-			proof := proofs[txid]
-			mapiCallback := mapiCallbacks[txid]
-			_ = searchParents(&txs, &envelope, txid, tx, proof, mapiCallback)
+	// the txs are added to a graph, so the parents of each are found by their id, and the depth
+	// of the envelope is checked before it is built. The first tx is the one the envelope is for.
+	g := NewTxGraph()
+	for idx, tx := range txs {
+		if tx == nil {
+			continue
+		}
+		n := &TxNode{Tx: tx, Proof: proofs[tx.TxID()].raw}
+		if err := g.Add(n); err != nil {
+			return nil, errors.WithMessagef(err, "flake %d", idx*3)
 		}
 	}
+	root := txGraphID(txs[0])
+	if _, err := g.depth(root, o.limits); err != nil {
+		return nil, err
+	}
+	ids, err := g.order([][32]byte{root})
+	if err != nil {
+		return nil, err
+	}
+	// a tx which is not an ancestor of the envelope tx is not in its order.
+	if len(ids) < g.Len() {
+		return nil, errors.Errorf("%d txs in the envelope are not ancestors of tx %s", g.Len()-len(ids), txs[0].TxID())
+	}
 
-	return &envelope, nil
-}
-
-func searchParents(txs *map[string]*bt.Tx, currentEnvelope *Envelope, txid string, tx *bt.Tx, p *bc.MerkleProof, m []bc.MapiCallback) bool {
-	// is this the route transaction, and do we know it?
-	if txid == currentEnvelope.TxID {
-		currentEnvelope.RawTx = tx.String()
-		if m != nil { // don't add proof unless it's a non empty struct.
-			currentEnvelope.MapiResponses = m
-		}
-		if p != nil { // don't add proof unless it's a non empty struct.
-			currentEnvelope.Proof = p
+	envelopes := make(map[[32]byte]*Envelope, len(ids))
+	envelopes[root] = &envelope
+	for _, id := range ids[1:] {
+		envelopes[id] = &Envelope{}
+	}
+	for _, id := range ids {
+		tx := g.nodes[id].Tx
+		e := envelopes[id]
+		e.TxID = tx.TxID()
+		e.RawTx = tx.String()
+		e.Proof = proofs[e.TxID].proof
+		e.MapiResponses = mapiCallbacks[e.TxID]
+		if e.Proof != nil {
+			continue
 		}
-		delete(*txs, txid)
-		return true
-	}
-	// iterate through inputs
-	for k := range currentEnvelope.Parents {
-		// if we find the correct place, add the tx.
-		if k == txid {
-			var nextEnvelope Envelope
-			nextEnvelope.TxID = txid
-			nextEnvelope.RawTx = tx.String()
-			if m != nil { // don't add proof unless it's a non empty struct.
-				nextEnvelope.MapiResponses = m
-			}
-			if p != nil { // don't add proof unless it's a non empty struct.
-				nextEnvelope.Proof = p
+		// parents not in the envelope are left empty.
+		e.Parents = make(map[string]*Envelope, len(tx.Inputs))
+		for _, input := range tx.Inputs {
+			var parentID [32]byte
+			copy(parentID[:], input.PreviousTxID())
+			if parent, ok := envelopes[parentID]; ok {
+				e.Parents[input.PreviousTxIDStr()] = parent
 			} else {
-				inputs := map[string]*Envelope{}
-				for _, input := range tx.Inputs {
-					inputs[input.PreviousTxIDStr()] = &Envelope{}
-				}
-				nextEnvelope.Parents = inputs
-				currentEnvelope.Parents[txid] = &nextEnvelope
+				e.Parents[input.PreviousTxIDStr()] = &Envelope{}
 			}
-			delete(*txs, txid)
-			return true
 		}
 	}
-	// if we didn't find it at this level, go one deeper into the struct to find a parent of a parent... etc.
-	if currentEnvelope.Parents != nil && len(currentEnvelope.Parents) > 0 {
-		for _, parent := range currentEnvelope.Parents {
-			// this means the context of the next iteration through will be from the parent here, which is one step deeper.
-			return searchParents(txs, parent, txid, tx, p, m)
-		}
-	}
-	return false
+
+	return &envelope, nil
 }
 
-// parseSpecialKFlakesRecursively will identify the next chunk of data's type and length,
-// and pull out the stream into the appropriate struct.
+// specialKProof is a merkle proof, its bytes, and the id of the tx it is for.
+type specialKProof struct {
+	txid  string
+	proof *bc.MerkleProof
+	raw   []byte
+}
+
+// parseSpecialKFlakeTx parses the tx of a flake.
 func parseSpecialKFlakeTx(b []byte) (*bt.Tx, error) {
 	if len(b) == 0 {
 		return nil, errors.New("tx bytes have no length")
 	}
-	tx, err := bt.NewTxFromBytes(b)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-	return tx, nil
+	return newTxFromBytes(b)
 }
 
-func parseSpecialKProof(b []byte) (*bc.MerkleProof, error) {
+func parseSpecialKProof(b []byte, l Limits) (*bc.MerkleProof, error) {
 	if len(b) == 0 {
 		return nil, errors.New("proof bytes have no length")
 	}
 	if b[0] == 0 && len(b) == 1 {
 		return nil, errors.New("proof number is 0")
 	}
-	binaryProof, err := parseBinaryMerkleProof(b)
+	binaryProof, err := parseBinaryMerkleProof(b, l)
 	if err != nil {
-		fmt.Println(err)
-		return nil, errors.New("couldn't parse the proof bytes")
+		return nil, errors.WithMessage(err, "couldn't parse the proof bytes")
 	}
 	proof := bc.MerkleProof{
 		Index:      binaryProof.index,
@@ -484,25 +549,28 @@ func parseSpecialKProof(b []byte) (*bc.MerkleProof, error) {
 	return &proof, nil
 }
 
-func parseSpecialKMapi(b []byte) ([]bc.MapiCallback, error) {
+func parseSpecialKMapi(b []byte, lim Limits) ([]bc.MapiCallback, error) {
 	if len(b) == 0 {
 		return nil, errors.New("There are no callback bytes")
 	}
-	var internalOffset uint64
-	allBinary := uint64(len(b))
-	numOfMapiResponses := b[internalOffset]
+	r := bc.NewDecoder(b, ErrTruncatedAncestry)
+	numOfMapiResponses, err := r.Byte("mapi response count")
+	if err != nil {
+		return nil, err
+	}
 	if numOfMapiResponses == 0 && len(b) == 1 {
 		return nil, errors.New("There are no callbacks")
 	}
-	internalOffset++
 
-	// split up the binary into flakes where each one is to be processed concurrently.
 	var responses = [][]byte{}
-	for ok := true; ok; ok = allBinary > internalOffset {
-		l, size := bt.NewVarIntFromBytes(b[internalOffset:])
-		internalOffset += uint64(size)
-		response := b[internalOffset : internalOffset+uint64(l)]
-		internalOffset += uint64(l)
+	for ok := true; ok; ok = r.Remaining() > 0 {
+		if err := lim.mapiResponses(len(responses) + 1); err != nil {
+			return nil, err
+		}
+		response, err := r.VarBytes("mapi response")
+		if err != nil {
+			return nil, err
+		}
 		responses = append(responses, response)
 	}
 
@@ -510,8 +578,7 @@ func parseSpecialKMapi(b []byte) ([]bc.MapiCallback, error) {
 	for _, response := range responses {
 		mapiResponse, err := bc.NewMapiCallbackFromBytes(response)
 		if err != nil {
-			fmt.Println(err)
-			return nil, errors.New("couldn't parse the callback bytes")
+			return nil, errors.Wrap(err, "couldn't parse the callback bytes")
 		}
 		mapiResponses = append(mapiResponses, *mapiResponse)
 	}
@@ -37,10 +37,15 @@ var (
 	// ErrFeePaidNotEnough returned when not enough fees have been paid.
 	ErrFeePaidNotEnough = errors.New("not enough fees paid")
 
-	// ErrCannotCalculateFeePaid returned when fee check is enabled but the tx has no parents.
+	// ErrCannotCalculateFeePaid returned when fee check is enabled but the tx has no parents, or is
+	// missing the parent of an input.
 	ErrCannotCalculateFeePaid = errors.New("no parents supplied in ancestry which means we cannot valdiate " +
 		"fees, either ensure parents are supplied or remove fee check")
 
+	// ErrNonFinalTx is returned when lock time verification is enabled and a transaction cannot
+	// be mined in the next block as its lock time has not passed.
+	ErrNonFinalTx = errors.New("transaction is not final, its lock time has not passed")
+
 	// ErrInvalidProof is returned if the merkle proof validation fails.
 	ErrInvalidProof = errors.New("invalid merkle proof, payment invalid")
 
@@ -65,6 +70,40 @@ var (
 	// ErrMissingRootInProof returns if there's a missing root in the proof.
 	ErrMissingRootInProof = errors.New("missing root in proof")
 
+	// ErrInvalidProofTarget returns if the block header a merkle proof targets does not have valid proof of work.
+	ErrInvalidProofTarget = errors.New("merkle proof target header has invalid proof of work")
+
+	// ErrProofTargetMismatch returns if the block header a merkle proof targets has a different merkle root
+	// to the header with its hash on the chain.
+	ErrProofTargetMismatch = errors.New("merkle proof target header does not match the header on the chain")
+
+	// ErrUntrustedProofTarget returns if a merkle proof targets a bare merkle root and proof targets are verified.
+	ErrUntrustedProofTarget = errors.New("merkle proof targets a merkle root which cannot be verified against the chain")
+
+	// ErrNotEnoughConfirmations returns if an anchor in the ancestry has fewer confirmations than required.
+	ErrNotEnoughConfirmations = errors.New("anchor transaction does not have enough confirmations")
+
+	// ErrMapiCallbackMismatch returns if a MAPI callback in the ancestry is not for its transaction or block.
+	ErrMapiCallbackMismatch = errors.New("mapi callback does not match its transaction or block")
+
+	// ErrLimitExceeded returns if an ancestry, or envelope, is larger or deeper than the Limits allow.
+	ErrLimitExceeded = errors.New("ancestry exceeds decoding limits")
+
+	// ErrTruncatedAncestry returns if a length in an ancestry runs past the end of it.
+	ErrTruncatedAncestry = errors.New("ancestry is truncated")
+
+	// ErrMalformedTx returns if a transaction in an ancestry, envelope or merkle proof cannot be parsed.
+	ErrMalformedTx = errors.New("transaction is malformed")
+
+	// ErrMalformedProof returns if a field of a binary merkle proof runs past the end of it.
+	ErrMalformedProof = errors.New("merkle proof is malformed")
+
+	// ErrDuplicateTx returns if a transaction appears more than once in an ancestry, or is added to a TxGraph twice.
+	ErrDuplicateTx = errors.New("transaction is already in the ancestry")
+
+	// ErrCyclicAncestry returns if a transaction in an ancestry is given as its own ancestor.
+	ErrCyclicAncestry = errors.New("ancestry is cyclic")
+
 	// ErrInvalidNodes returns if there is a * on the left hand side within the node array.
 	ErrInvalidNodes = errors.New("invalid nodes")
 )
@@ -0,0 +1,128 @@
+//go:build go1.18
+// +build go1.18
+
+package spv
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// ancestrySeeds returns the binary ancestries, and their payments, of the spv test data.
+func ancestrySeeds(t testing.TB) (ancestries [][]byte, payments []*bt.Tx) {
+	for _, file := range []string{"valid_3_nested.json", "valid_1000_nested.json"} {
+		testData := struct {
+			PaymentTx string `json:"paymentTx"`
+			Ancestry  string `json:"ancestors"`
+		}{}
+		bb, err := data.SpvBinaryData.Load(file)
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testData))
+		ancestry, err := hex.DecodeString(testData.Ancestry)
+		require.NoError(t, err)
+		tx, err := bt.NewTxFromString(testData.PaymentTx)
+		require.NoError(t, err)
+		ancestries = append(ancestries, ancestry)
+		payments = append(payments, tx)
+	}
+	for _, file := range []string{"valid.json", "valid_deep.json", "valid_merkle_proof_hex.json", "invalid_tx_indexing_oob.json"} {
+		testData := struct {
+			Envelope *AncestryJSON `json:"data"`
+		}{}
+		bb, err := data.SpvVerifyData.Load(file)
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testData))
+		ancestry, err := testData.Envelope.Bytes()
+		require.NoError(t, err)
+		tx, err := bt.NewTxFromString(testData.Envelope.RawTx)
+		require.NoError(t, err)
+		ancestries = append(ancestries, ancestry)
+		payments = append(payments, tx)
+	}
+	return ancestries, payments
+}
+
+func FuzzNewAncestryJSONFromBytes(f *testing.F) {
+	ancestries, _ := ancestrySeeds(f)
+	for _, ancestry := range ancestries {
+		f.Add(ancestry)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = NewAncestryJSONFromBytes(b)
+	})
+}
+
+func FuzzVerifyPayment(f *testing.F) {
+	ancestries, payments := ancestrySeeds(f)
+	for _, ancestry := range ancestries {
+		f.Add(ancestry)
+	}
+	v, err := NewPaymentVerifier(notFoundChain{}, VerifyLockTime(), VerifyFees(bt.NewFeeQuote()))
+	require.NoError(f, err)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, tx := range payments {
+			_, _ = v.VerifyPaymentReport(context.Background(), &Payment{PaymentTx: tx, Ancestry: b})
+		}
+	})
+}
+
+func FuzzParseBinaryMerkleProof(f *testing.F) {
+	ancestries, _ := ancestrySeeds(f)
+	for _, ancestry := range ancestries {
+		g, err := parseAncestry(ancestry, DefaultLimits())
+		require.NoError(f, err)
+		for _, a := range g.Nodes() {
+			if a.Proof != nil {
+				f.Add(a.Proof)
+			}
+		}
+	}
+	v, err := NewMerkleProofVerifier(notFoundChain{})
+	require.NoError(f, err)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = parseBinaryMerkleProof(b, DefaultLimits())
+		_, _ = v.VerifyMerkleProof(context.Background(), b)
+	})
+}
+
+func FuzzEnvelopeFromBytes(f *testing.F) {
+	for _, test := range tests {
+		for _, s := range []string{test.crunchyNutHexString, test.specialKHexString} {
+			b, err := hex.DecodeString(s)
+			require.NoError(f, err)
+			f.Add(b)
+		}
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		_, _ = NewCrunchyNutEnvelopeFromBytes(b)
+		_, _ = NewSpecialKEnvelopeFromBytes(b)
+	})
+}
+
+// notFoundChain is a bc.HeightIndexedBlockHeaderChain which has no headers.
+type notFoundChain struct{}
+
+func (notFoundChain) BlockHeader(context.Context, string) (*bc.BlockHeader, error) {
+	return nil, bc.ErrHeaderNotFound
+}
+
+func (notFoundChain) BlockHeight(context.Context, string) (uint64, error) {
+	return 0, bc.ErrHeaderNotFound
+}
+
+func (notFoundChain) BlockHeaderByHeight(context.Context, uint64) (*bc.BlockHeader, error) {
+	return nil, bc.ErrHeaderNotFound
+}
+
+func (notFoundChain) ChainTip(context.Context) (uint64, string, error) {
+	return 0, "", bc.ErrHeaderNotFound
+}
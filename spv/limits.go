@@ -0,0 +1,92 @@
+package spv
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Limits bound the ancestries and envelopes which are decoded, so a payload too large, or
+// too deep, to verify is rejected before any expensive work is done with it. A limit of 0
+// means there is no limit, start from DefaultLimits to change a single limit.
+type Limits struct {
+	// MaxSize is the most bytes an ancestry, or envelope, can be.
+	MaxSize int
+	// MaxTxs is the most transactions it can hold.
+	MaxTxs int
+	// MaxDepth is the most transactions in a chain from the payment to an ancestor, counting both.
+	MaxDepth int
+	// MaxMapiResponses is the most MAPI responses any transaction can have.
+	MaxMapiResponses int
+	// MaxProofNodes is the most nodes a merkle proof can have.
+	MaxProofNodes int
+}
+
+// DefaultLimits returns the limits used unless others are set. They allow ancestries far
+// larger than a payment needs, while refusing those which would take minutes to verify.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxSize:          64 * 1024 * 1024,
+		MaxTxs:           10000,
+		MaxDepth:         2000,
+		MaxMapiResponses: 16,
+		// a block of 2^64 transactions is the largest a proof index can describe.
+		MaxProofNodes: 64,
+	}
+}
+
+func (l Limits) size(n int) error {
+	if l.MaxSize > 0 && n > l.MaxSize {
+		return errors.Wrapf(ErrLimitExceeded, "%d bytes, at most %d allowed", n, l.MaxSize)
+	}
+	return nil
+}
+
+func (l Limits) txs(n int) error {
+	if l.MaxTxs > 0 && n > l.MaxTxs {
+		return errors.Wrapf(ErrLimitExceeded, "more than %d txs", l.MaxTxs)
+	}
+	return nil
+}
+
+func (l Limits) depth(n int) error {
+	if l.MaxDepth > 0 && n > l.MaxDepth {
+		return errors.Wrapf(ErrLimitExceeded, "ancestry deeper than %d txs", l.MaxDepth)
+	}
+	return nil
+}
+
+func (l Limits) mapiResponses(n int) error {
+	if l.MaxMapiResponses > 0 && n > l.MaxMapiResponses {
+		return errors.Wrapf(ErrLimitExceeded, "more than %d mapi responses", l.MaxMapiResponses)
+	}
+	return nil
+}
+
+func (l Limits) proofNodes(n uint64) error {
+	if l.MaxProofNodes > 0 && n > uint64(l.MaxProofNodes) {
+		return errors.Wrapf(ErrLimitExceeded, "%d merkle proof nodes, at most %d allowed", n, l.MaxProofNodes)
+	}
+	return nil
+}
+
+type decodeOptions struct {
+	limits Limits
+}
+
+// DecodeOpt defines a functional option that is used to modify the behaviour of the
+// ancestry and envelope decoders.
+type DecodeOpt func(*decodeOptions)
+
+// WithLimits sets the limits of what is decoded, DefaultLimits are used otherwise.
+func WithLimits(l Limits) DecodeOpt {
+	return func(o *decodeOptions) {
+		o.limits = l
+	}
+}
+
+func newDecodeOptions(opts []DecodeOpt) *decodeOptions {
+	o := &decodeOptions{limits: DefaultLimits()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
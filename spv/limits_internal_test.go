@@ -0,0 +1,64 @@
+package spv
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelopeFromBytes_Limits(t *testing.T) {
+	decoders := map[string]struct {
+		decode func([]byte, ...DecodeOpt) (*Envelope, error)
+		hex    string
+	}{
+		"crunchy nut": {
+			decode: NewCrunchyNutEnvelopeFromBytes,
+			hex:    tests["large"].crunchyNutHexString,
+		},
+		"special k": {
+			decode: NewSpecialKEnvelopeFromBytes,
+			hex:    tests["large"].specialKHexString,
+		},
+	}
+	limits := map[string]struct {
+		limit  func(*Limits)
+		expErr error
+	}{
+		"default limits pass": {
+			limit: func(*Limits) {},
+		},
+		"too large": {
+			limit:  func(l *Limits) { l.MaxSize = 100 },
+			expErr: ErrLimitExceeded,
+		},
+		"too many txs": {
+			limit:  func(l *Limits) { l.MaxTxs = 1 },
+			expErr: ErrLimitExceeded,
+		},
+		"too deep": {
+			limit:  func(l *Limits) { l.MaxDepth = 1 },
+			expErr: ErrLimitExceeded,
+		},
+	}
+
+	for name, decoder := range decoders {
+		b, err := hex.DecodeString(decoder.hex)
+		require.NoError(t, err)
+		for limitName, test := range limits {
+			t.Run(name+" "+limitName, func(t *testing.T) {
+				l := DefaultLimits()
+				test.limit(&l)
+				_, err := decoder.decode(b, WithLimits(l))
+				require.ErrorIs(t, err, test.expErr)
+				if test.expErr == nil {
+					require.NoError(t, err)
+				}
+			})
+		}
+		t.Run(name+" truncated", func(t *testing.T) {
+			_, err := decoder.decode(b[:len(b)/2])
+			require.ErrorIs(t, err, ErrTruncatedAncestry)
+		})
+	}
+}
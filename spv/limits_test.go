@@ -0,0 +1,145 @@
+package spv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+func TestVerifyPayment_Limits(t *testing.T) {
+	headers := &mockBlockHeaderClient{
+		blockHeaderFunc: func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+			bb, err := data.BlockHeaderData.Load(hash)
+			if err != nil {
+				return nil, err
+			}
+			return bc.NewBlockHeaderFromStr(string(bb[:160]))
+		},
+	}
+	limits := func(fn func(*spv.Limits)) spv.VerifyOpt {
+		l := spv.DefaultLimits()
+		fn(&l)
+		return spv.DecodeLimits(l)
+	}
+	withMapi := func(n int) func(*spv.AncestryJSON) {
+		return func(e *spv.AncestryJSON) {
+			for _, a := range anchors(e) {
+				for i := 0; i < n; i++ {
+					a.MapiResponses = append(a.MapiResponses, bc.MapiCallback{CallbackReason: "merkleProof"})
+				}
+			}
+		}
+	}
+
+	tests := map[string]struct {
+		payment func(t *testing.T) *spv.Payment
+		opts    []spv.VerifyOpt
+		expErr  error
+	}{
+		"default limits pass": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid_deep.json", withMapi(2))
+			},
+		},
+		"too large": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid.json", nil)
+			},
+			opts:   []spv.VerifyOpt{limits(func(l *spv.Limits) { l.MaxSize = 100 })},
+			expErr: spv.ErrLimitExceeded,
+		},
+		"too many txs": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid.json", nil)
+			},
+			opts:   []spv.VerifyOpt{limits(func(l *spv.Limits) { l.MaxTxs = 1 })},
+			expErr: spv.ErrLimitExceeded,
+		},
+		"too deep": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid_deep.json", nil)
+			},
+			opts:   []spv.VerifyOpt{limits(func(l *spv.Limits) { l.MaxDepth = 2 })},
+			expErr: spv.ErrLimitExceeded,
+		},
+		"too many mapi responses": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid.json", withMapi(2))
+			},
+			opts:   []spv.VerifyOpt{limits(func(l *spv.Limits) { l.MaxMapiResponses = 1 })},
+			expErr: spv.ErrLimitExceeded,
+		},
+		"too many proof nodes": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid.json", nil)
+			},
+			opts:   []spv.VerifyOpt{limits(func(l *spv.Limits) { l.MaxProofNodes = 1 })},
+			expErr: spv.ErrLimitExceeded,
+		},
+		"no limits": {
+			payment: func(t *testing.T) *spv.Payment {
+				return loadAncestry(t, "valid_deep.json", withMapi(2))
+			},
+			opts: []spv.VerifyOpt{spv.DecodeLimits(spv.Limits{})},
+		},
+		"truncated ancestry": {
+			payment: func(t *testing.T) *spv.Payment {
+				p := loadAncestry(t, "valid.json", nil)
+				p.Ancestry = p.Ancestry[:len(p.Ancestry)-1]
+				return p
+			},
+			expErr: spv.ErrTruncatedAncestry,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := spv.NewPaymentVerifier(headers)
+			require.NoError(t, err)
+
+			err = v.VerifyPayment(context.Background(), test.payment(t), test.opts...)
+			require.ErrorIs(t, err, test.expErr)
+			if test.expErr == nil {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestVerifyPayment_LimitsDepth(t *testing.T) {
+	testDataJSON := struct {
+		PaymentTx string `json:"paymentTx"`
+		Ancestry  string `json:"ancestors"`
+	}{}
+	bb, err := data.SpvBinaryData.Load("valid_1000_nested.json")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testDataJSON))
+	tx, err := bt.NewTxFromString(testDataJSON.PaymentTx)
+	require.NoError(t, err)
+	ancestry, err := hex.DecodeString(testDataJSON.Ancestry)
+	require.NoError(t, err)
+
+	// every chain through the ancestry is rejected once it is too deep, without looking up a header.
+	v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{})
+	require.NoError(t, err)
+	l := spv.DefaultLimits()
+	l.MaxDepth = 100
+	err = v.VerifyPayment(context.Background(), &spv.Payment{PaymentTx: tx, Ancestry: ancestry}, spv.DecodeLimits(l))
+	require.ErrorIs(t, err, spv.ErrLimitExceeded)
+
+	_, err = spv.NewAncestryJSONFromBytes(ancestry)
+	require.NoError(t, err)
+	l = spv.DefaultLimits()
+	l.MaxTxs = 100
+	_, err = spv.NewAncestryJSONFromBytes(ancestry, spv.WithLimits(l))
+	require.ErrorIs(t, err, spv.ErrLimitExceeded)
+}
@@ -0,0 +1,85 @@
+package spv
+
+import (
+	"context"
+	"time"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// lockTimeThreshold is the lock time below which it is a block height, and at or above
+// which it is a unix time.
+const lockTimeThreshold = 500000000
+
+// verifyLockTimes checks the unconfirmed transactions in the ancestry are final in the
+// block after the tip of the chain, recording the result in their reports.
+func (v *verifier) verifyLockTimes(ctx context.Context, g *TxGraph, reports map[[32]byte]*TxReport) {
+	var locked [][32]byte
+	for id, a := range g.nodes {
+		if a.Proof != nil {
+			continue
+		}
+		reports[id].LockTime = StatusPassed
+		if !isFinal(a.Tx, 0, time.Time{}) {
+			locked = append(locked, id)
+		}
+	}
+	if len(locked) == 0 {
+		return
+	}
+
+	fail := func(err error) {
+		for _, id := range locked {
+			reports[id].LockTime = StatusFailed
+			reports[id].fail(-1, ErrNonFinalTx, err)
+		}
+	}
+	chain, ok := v.bhc.(bc.HeightIndexedBlockHeaderChain)
+	if !ok {
+		fail(bc.ErrNoHeightIndex)
+		return
+	}
+	height, hash, err := chain.ChainTip(ctx)
+	if err != nil {
+		fail(err)
+		return
+	}
+	mtp, err := bc.MedianTimePast(ctx, chain, hash)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	for _, id := range locked {
+		if tx := g.nodes[id].Tx; !isFinal(tx, height+1, mtp) {
+			reports[id].LockTime = StatusFailed
+			reports[id].fail(-1, ErrNonFinalTx, errors.Errorf("lock time %d has not passed", tx.LockTime))
+		}
+	}
+}
+
+// isFinal returns true if tx can be mined in the block at height, whose parent has the
+// median time past mtp.
+func isFinal(tx *bt.Tx, height uint64, mtp time.Time) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+
+	cutoff := int64(height)
+	if tx.LockTime >= lockTimeThreshold {
+		cutoff = mtp.Unix()
+	}
+	if int64(tx.LockTime) < cutoff {
+		return true
+	}
+
+	for _, input := range tx.Inputs {
+		if input.SequenceNumber != bt.DefaultSequenceNumber {
+			return false
+		}
+	}
+	return true
+}
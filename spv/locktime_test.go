@@ -0,0 +1,153 @@
+package spv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// tipChain is a height indexed chain whose tip, at height, has the time provided. Other
+// headers are looked up in the test data.
+type tipChain struct {
+	mockBlockHeaderClient
+	height uint64
+	tip    *bc.BlockHeader
+}
+
+func (c *tipChain) BlockHeader(ctx context.Context, blockHash string) (*bc.BlockHeader, error) {
+	if blockHash == "tip" {
+		return c.tip, nil
+	}
+	return c.mockBlockHeaderClient.BlockHeader(ctx, blockHash)
+}
+
+func (c *tipChain) ChainTip(context.Context) (uint64, string, error) {
+	return c.height, "tip", nil
+}
+
+func (c *tipChain) BlockHeaderByHeight(context.Context, uint64) (*bc.BlockHeader, error) {
+	return nil, bc.ErrHeaderNotFound
+}
+
+func (c *tipChain) BlockHeight(context.Context, string) (uint64, error) {
+	return 0, bc.ErrHeaderNotFound
+}
+
+func TestVerifyPayment_LockTime(t *testing.T) {
+	testData := struct {
+		Envelope *spv.AncestryJSON `json:"data"`
+	}{}
+	bb, err := data.SpvVerifyData.Load("valid.json")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testData))
+	ancestry, err := testData.Envelope.Bytes()
+	require.NoError(t, err)
+	raw, err := hex.DecodeString(testData.Envelope.RawTx)
+	require.NoError(t, err)
+
+	mch := mockBlockHeaderClient{
+		blockHeaderFunc: func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+			bb, err := data.BlockHeaderData.Load(hash)
+			if err != nil {
+				return nil, err
+			}
+			return bc.NewBlockHeaderFromStr(string(bb[:160]))
+		},
+	}
+	chain := &tipChain{
+		mockBlockHeaderClient: mch,
+		height:                700000,
+		tip: &bc.BlockHeader{
+			Time:           1630000000,
+			HashPrevBlock:  make([]byte, 32),
+			HashMerkleRoot: make([]byte, 32),
+			Bits:           []byte{0x18, 0x0e, 0x1a, 0x5a},
+		},
+	}
+
+	tests := map[string]struct {
+		lockTime uint32
+		sequence uint32
+		bhc      bc.BlockHeaderChain
+		opts     []spv.VerifyOpt
+		expErr   error
+	}{
+		"no lock time passes": {
+			sequence: 0,
+			bhc:      chain,
+		},
+		"lock time with final sequence passes": {
+			lockTime: 700001,
+			sequence: bt.DefaultSequenceNumber,
+			bhc:      chain,
+		},
+		"height lock time at tip passes": {
+			lockTime: 700000,
+			sequence: 0,
+			bhc:      chain,
+		},
+		"height lock time after next block fails": {
+			lockTime: 700001,
+			sequence: 0,
+			bhc:      chain,
+			expErr:   spv.ErrNonFinalTx,
+		},
+		"height lock time after next block passes when disabled": {
+			lockTime: 700001,
+			sequence: 0,
+			bhc:      chain,
+			opts:     []spv.VerifyOpt{spv.NoVerifyLockTime()},
+		},
+		"time lock time before median time past passes": {
+			lockTime: 1629999999,
+			sequence: 0,
+			bhc:      chain,
+		},
+		"time lock time at median time past fails": {
+			lockTime: 1630000000,
+			sequence: 0,
+			bhc:      chain,
+			expErr:   spv.ErrNonFinalTx,
+		},
+		"lock time without a height index fails": {
+			lockTime: 700000,
+			sequence: 0,
+			bhc:      &mch,
+			expErr:   bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tx, err := bt.NewTxFromBytes(raw)
+			require.NoError(t, err)
+			tx.LockTime = test.lockTime
+			for _, input := range tx.Inputs {
+				input.SequenceNumber = test.sequence
+			}
+
+			// the signatures no longer match the modified tx.
+			v, err := spv.NewPaymentVerifier(test.bhc, spv.NoVerifyScript(), spv.VerifyLockTime())
+			require.NoError(t, err)
+
+			err = v.VerifyPayment(context.Background(), &spv.Payment{
+				PaymentTx: tx,
+				Ancestry:  ancestry,
+			}, test.opts...)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package spv
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoders_Malformed(t *testing.T) {
+	// a tx with one input whose script is 2^32 bytes long.
+	hugeScript := "01000000" + "01" + strings.Repeat("00", 36) + "feffffffff" + "ffffffff" + "00" + "00000000"
+	tests := map[string]struct {
+		decode func([]byte) error
+		hex    string
+		expErr error
+	}{
+		"empty ancestry": {
+			decode: func(b []byte) error { _, err := parseAncestry(b, DefaultLimits()); return err },
+			hex:    "",
+			expErr: ErrTruncatedAncestry,
+		},
+		"ancestry chunk length past the end": {
+			decode: func(b []byte) error { _, err := parseAncestry(b, DefaultLimits()); return err },
+			hex:    "0101ff",
+			expErr: ErrTruncatedAncestry,
+		},
+		"ancestry tx with a huge script": {
+			decode: func(b []byte) error { _, err := parseAncestry(b, DefaultLimits()); return err },
+			hex:    "0101" + hex.EncodeToString([]byte{byte(len(hugeScript) / 2)}) + hugeScript,
+			expErr: ErrMalformedTx,
+		},
+		"ancestry mapi response past the end": {
+			decode: func(b []byte) error { _, err := parseMapiCallbacks(b, DefaultLimits()); return err },
+			hex:    "0105aa",
+			expErr: ErrTruncatedAncestry,
+		},
+		"empty merkle proof": {
+			decode: func(b []byte) error { _, err := parseBinaryMerkleProof(b, DefaultLimits()); return err },
+			hex:    "",
+			expErr: ErrMalformedProof,
+		},
+		"merkle proof target past the end": {
+			decode: func(b []byte) error { _, err := parseBinaryMerkleProof(b, DefaultLimits()); return err },
+			hex:    "0000" + "0000000000000000000000000000000000000000000000000000000000000000" + "aa",
+			expErr: ErrMalformedProof,
+		},
+		"merkle proof node past the end": {
+			decode: func(b []byte) error { _, err := parseBinaryMerkleProof(b, DefaultLimits()); return err },
+			hex: "0000" + "0000000000000000000000000000000000000000000000000000000000000000" +
+				"0000000000000000000000000000000000000000000000000000000000000000" + "0200aa",
+			expErr: ErrMalformedProof,
+		},
+		"empty crunchy nut envelope": {
+			decode: func(b []byte) error { _, err := NewCrunchyNutEnvelopeFromBytes(b); return err },
+			hex:    "",
+			expErr: ErrTruncatedAncestry,
+		},
+		"crunchy nut envelope with only a version": {
+			decode: func(b []byte) error { _, err := NewCrunchyNutEnvelopeFromBytes(b); return err },
+			hex:    "01",
+			expErr: ErrTruncatedAncestry,
+		},
+		"crunchy nut envelope with a malformed tx": {
+			decode: func(b []byte) error { _, err := NewCrunchyNutEnvelopeFromBytes(b); return err },
+			hex:    "010102aaaa",
+			expErr: ErrMalformedTx,
+		},
+		"empty special k envelope": {
+			decode: func(b []byte) error { _, err := NewSpecialKEnvelopeFromBytes(b); return err },
+			hex:    "",
+			expErr: ErrTruncatedAncestry,
+		},
+		"special k envelope with a malformed tx": {
+			decode: func(b []byte) error { _, err := NewSpecialKEnvelopeFromBytes(b); return err },
+			hex:    "0102aaaa",
+			expErr: ErrMalformedTx,
+		},
+		"special k envelope with a malformed proof": {
+			decode: func(b []byte) error { _, err := NewSpecialKEnvelopeFromBytes(b); return err },
+			hex:    "01" + "00" + "020000",
+			expErr: ErrMalformedProof,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			b, err := hex.DecodeString(test.hex)
+			require.NoError(t, err)
+			require.ErrorIs(t, test.decode(b), test.expErr)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package spv
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// Status is the result of a check made of a transaction in a payment.
+type Status string
+
+// The results of a check.
+const (
+	// StatusSkipped is the status of a check which is switched off, or does not apply to the transaction.
+	StatusSkipped Status = "skipped"
+	// StatusNone is the proof status of a transaction without a merkle proof.
+	StatusNone Status = "none"
+	// StatusPassed is the status of a check which passed.
+	StatusPassed Status = "passed"
+	// StatusFailed is the status of a check which failed.
+	StatusFailed Status = "failed"
+	// StatusNotImplemented is the status of a check which is switched on but cannot be made
+	// yet, such as running the script of an input.
+	StatusNotImplemented Status = "not implemented"
+)
+
+// A TxError is a failure of a transaction in a payment. It matches, with errors.Is, the
+// sentinel error describing the failure, such as ErrInvalidProof, and any error which caused it.
+type TxError struct {
+	TxID string
+	// Vin is the index of the input which failed, or -1 if the failure is not of an input.
+	Vin int
+	// Err is the sentinel error describing the failure.
+	Err error
+	// Reason is the error which caused the failure, if any, such as the error returned by the
+	// bc.BlockHeaderChain for a proof which could not be verified.
+	Reason error
+}
+
+func (e *TxError) Error() string {
+	msg := fmt.Sprintf("tx %s", e.TxID)
+	if e.Vin >= 0 {
+		msg = fmt.Sprintf("%s input %d", msg, e.Vin)
+	}
+	msg = fmt.Sprintf("%s: %s", msg, e.Err)
+	if e.Reason != nil {
+		msg = fmt.Sprintf("%s: %s", msg, e.Reason)
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error describing the failure.
+func (e *TxError) Unwrap() error {
+	return e.Err
+}
+
+// Cause returns the sentinel error describing the failure, for use with errors.Cause.
+func (e *TxError) Cause() error {
+	return errors.Cause(e.Err)
+}
+
+// Is reports whether the error which caused the failure matches target.
+func (e *TxError) Is(target error) bool {
+	return e.Reason != nil && errors.Is(e.Reason, target)
+}
+
+// InputReport is the result of the checks made of an input of a transaction.
+type InputReport struct {
+	Vin                int
+	PreviousTxID       string
+	PreviousTxOutIndex uint32
+	// Script is the result of checking the unlocking script against the output it spends. The
+	// scripts are not run yet, so it is StatusNotImplemented unless the output does not exist.
+	Script Status
+}
+
+// TxReport is the result of the checks made of a transaction in a payment.
+type TxReport struct {
+	TxID string
+	// Proof is the result of verifying the merkle proof of the transaction, StatusNone if it
+	// has none.
+	Proof Status
+	// BlockHash is the hash of the block the proof targets.
+	BlockHash string
+	// Confirmations is the number of confirmations of the block the transaction is in, it is
+	// only known if the bc.BlockHeaderChain implements bc.HeightIndexedBlockHeaderChain.
+	Confirmations uint64
+	// LockTime is the result of checking an unconfirmed transaction is final.
+	LockTime Status
+	// Fees is the result of checking the fees paid, only the payment transaction is checked.
+	Fees   Status
+	Inputs []InputReport
+	// Mapi is the result of checking the MAPI callbacks of the transaction are for it, and
+	// for the block its proof targets. StatusNone if it has none. MAPI callbacks are not
+	// required, so a failure is reported in MapiErr but does not fail the payment.
+	Mapi          Status
+	MapiCallbacks []*bc.MapiCallback
+	MapiErr       error
+	// Errs are the failures of the transaction, each a *TxError.
+	Errs []error
+}
+
+func newTxReport(txID string) *TxReport {
+	return &TxReport{
+		TxID:     txID,
+		Proof:    StatusSkipped,
+		LockTime: StatusSkipped,
+		Fees:     StatusSkipped,
+		Mapi:     StatusNone,
+	}
+}
+
+// fail records a failure of the transaction, or of one of its inputs if vin is not -1.
+func (r *TxReport) fail(vin int, err, reason error) {
+	r.Errs = append(r.Errs, &TxError{TxID: r.TxID, Vin: vin, Err: err, Reason: reason})
+}
+
+// VerificationReport is the result of verifying every transaction of a payment.
+type VerificationReport struct {
+	// TxID is the id of the payment transaction.
+	TxID string
+	// Txs holds a report of every transaction, the payment transaction first followed by its
+	// ancestors in topological order, each before its own ancestors with parents in the order
+	// of the inputs spending them. Any other transactions in the ancestry follow.
+	Txs []*TxReport
+}
+
+// Tx returns the report of the transaction with the id provided, or nil if it is not in the payment.
+func (r *VerificationReport) Tx(txID string) *TxReport {
+	for _, tx := range r.Txs {
+		if tx.TxID == txID {
+			return tx
+		}
+	}
+	return nil
+}
+
+// Valid returns true if every transaction passed its checks.
+func (r *VerificationReport) Valid() bool {
+	return r.Err() == nil
+}
+
+// Err returns the first failure of the payment, taking the transactions in the order they are
+// reported, or nil if there is none.
+func (r *VerificationReport) Err() error {
+	for _, tx := range r.Txs {
+		if len(tx.Errs) > 0 {
+			return tx.Errs[0]
+		}
+	}
+	return nil
+}
+
+// Errs returns every failure of the payment.
+func (r *VerificationReport) Errs() []error {
+	var errs []error
+	for _, tx := range r.Txs {
+		errs = append(errs, tx.Errs...)
+	}
+	return errs
+}
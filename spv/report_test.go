@@ -0,0 +1,229 @@
+package spv_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+// loadAncestry loads a payment from the verify test data, modifying its ancestry with fn if it is set.
+func loadAncestry(t *testing.T, file string, fn func(*spv.AncestryJSON)) *spv.Payment {
+	t.Helper()
+	testData := struct {
+		Envelope *spv.AncestryJSON `json:"data"`
+	}{}
+	bb, err := data.SpvVerifyData.Load(file)
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testData))
+	if fn != nil {
+		fn(testData.Envelope)
+	}
+	ancestry, err := testData.Envelope.Bytes()
+	require.NoError(t, err)
+	tx, err := bt.NewTxFromString(testData.Envelope.RawTx)
+	require.NoError(t, err)
+	return &spv.Payment{PaymentTx: tx, Ancestry: ancestry}
+}
+
+// anchors returns the ancestors of a payment with a merkle proof, by txid.
+func anchors(e *spv.AncestryJSON) map[string]*spv.AncestryJSON {
+	aa := map[string]*spv.AncestryJSON{}
+	for txID, p := range e.Parents {
+		if p.IsAnchored() {
+			aa[txID] = p
+		}
+		for txID, a := range anchors(p) {
+			aa[txID] = a
+		}
+	}
+	return aa
+}
+
+func TestVerifyPaymentReport(t *testing.T) {
+	ctx := context.Background()
+	headers := mockBlockHeaderClient{
+		blockHeaderFunc: func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+			bb, err := data.BlockHeaderData.Load(hash)
+			if err != nil {
+				return nil, err
+			}
+			return bc.NewBlockHeaderFromStr(string(bb[:160]))
+		},
+	}
+	missing := mockBlockHeaderClient{
+		blockHeaderFunc: func(context.Context, string) (*bc.BlockHeader, error) {
+			return nil, bc.ErrHeaderNotFound
+		},
+	}
+
+	t.Run("valid payment", func(t *testing.T) {
+		var callbackTx string
+		p := loadAncestry(t, "valid.json", func(e *spv.AncestryJSON) {
+			aa := anchors(e)
+			for txID := range aa {
+				if callbackTx == "" || txID < callbackTx {
+					callbackTx = txID
+				}
+			}
+			aa[callbackTx].MapiResponses = []bc.MapiCallback{{CallbackTxID: e.TxID, CallbackReason: "merkleProof"}}
+		})
+		v, err := spv.NewPaymentVerifier(&heightChain{mockBlockHeaderClient: headers, height: 10, tip: 12})
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p)
+		require.NoError(t, err)
+		require.True(t, r.Valid())
+		require.NoError(t, r.Err())
+		require.Empty(t, r.Errs())
+		require.Equal(t, p.PaymentTx.TxID(), r.TxID)
+		require.Greater(t, len(r.Txs), 1)
+
+		payment := r.Txs[0]
+		require.Equal(t, p.PaymentTx.TxID(), payment.TxID)
+		require.Equal(t, spv.StatusNone, payment.Proof)
+		require.Len(t, payment.Inputs, len(p.PaymentTx.Inputs))
+		for _, input := range payment.Inputs {
+			// the scripts are not run, so are not reported as passing.
+			require.Equal(t, spv.StatusNotImplemented, input.Script)
+		}
+		// each tx is reported before its ancestors.
+		g, err := spv.NewTxGraphFromBytes(p.Ancestry)
+		require.NoError(t, err)
+		require.Len(t, r.Txs, g.Len()+1)
+		index := make(map[string]int, len(r.Txs))
+		for i, tx := range r.Txs {
+			index[tx.TxID] = i
+		}
+		for _, n := range g.Nodes() {
+			for _, parent := range g.Parents(n.Tx.TxID()) {
+				require.Less(t, index[n.Tx.TxID()], index[parent.Tx.TxID()])
+			}
+		}
+
+		anchor := r.Tx(callbackTx)
+		require.NotNil(t, anchor)
+		require.Equal(t, spv.StatusPassed, anchor.Proof)
+		require.NotEmpty(t, anchor.BlockHash)
+		require.Equal(t, uint64(3), anchor.Confirmations)
+		// a callback for another tx is reported without failing the payment.
+		require.Equal(t, spv.StatusFailed, anchor.Mapi)
+		require.ErrorIs(t, anchor.MapiErr, spv.ErrMapiCallbackMismatch)
+		require.Len(t, anchor.MapiCallbacks, 1)
+	})
+
+	t.Run("every failure is reported", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		v, err := spv.NewPaymentVerifier(&missing)
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p)
+		require.NoError(t, err)
+		require.False(t, r.Valid())
+
+		var failed int
+		for _, tx := range r.Txs {
+			if tx.Proof != spv.StatusFailed {
+				continue
+			}
+			failed++
+			require.Len(t, tx.Errs, 1)
+			var txErr *spv.TxError
+			require.True(t, errors.As(tx.Errs[0], &txErr))
+			require.Equal(t, tx.TxID, txErr.TxID)
+			require.Equal(t, -1, txErr.Vin)
+			require.ErrorIs(t, txErr, spv.ErrInvalidProof)
+			require.ErrorIs(t, txErr, bc.ErrHeaderNotFound)
+		}
+		require.Greater(t, failed, 0)
+		require.Len(t, r.Errs(), failed)
+
+		// VerifyPayment returns the first failure of the report.
+		err = v.VerifyPayment(ctx, p)
+		require.ErrorIs(t, err, spv.ErrInvalidProof)
+		require.EqualError(t, err, r.Err().Error())
+	})
+
+	t.Run("input failure", func(t *testing.T) {
+		p := loadAncestry(t, "invalid_tx_indexing_oob.json", nil)
+		v, err := spv.NewPaymentVerifier(&headers)
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p)
+		require.NoError(t, err)
+
+		var txErr *spv.TxError
+		require.True(t, errors.As(r.Err(), &txErr))
+		require.ErrorIs(t, txErr, spv.ErrInputRefsOutOfBoundsOutput)
+		require.Equal(t, p.PaymentTx.TxID(), txErr.TxID)
+		require.GreaterOrEqual(t, txErr.Vin, 0)
+		require.Equal(t, spv.StatusFailed, r.Txs[0].Inputs[txErr.Vin].Script)
+	})
+
+	t.Run("fees of an input with a missing parent", func(t *testing.T) {
+		var missingTxID string
+		p := loadAncestry(t, "valid.json", func(e *spv.AncestryJSON) {
+			for txID := range e.Parents {
+				if missingTxID == "" || txID < missingTxID {
+					missingTxID = txID
+				}
+			}
+			delete(e.Parents, missingTxID)
+		})
+		v, err := spv.NewPaymentVerifier(&headers, spv.VerifyFees(bt.NewFeeQuote()))
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p)
+		require.NoError(t, err)
+		require.Equal(t, spv.StatusFailed, r.Txs[0].Fees)
+
+		vin := -1
+		for i, input := range p.PaymentTx.Inputs {
+			if input.PreviousTxIDStr() == missingTxID {
+				vin = i
+				break
+			}
+		}
+		require.GreaterOrEqual(t, vin, 0)
+
+		var feeErr *spv.TxError
+		for _, err := range r.Txs[0].Errs {
+			var txErr *spv.TxError
+			if errors.As(err, &txErr) && errors.Is(txErr, spv.ErrCannotCalculateFeePaid) {
+				feeErr = txErr
+			}
+		}
+		require.NotNil(t, feeErr)
+		require.Equal(t, vin, feeErr.Vin)
+		require.False(t, errors.Is(feeErr, spv.ErrNoFeeQuoteSupplied))
+	})
+
+	t.Run("scripts not checked", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		v, err := spv.NewPaymentVerifier(&headers, spv.NoVerifyScript())
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p)
+		require.NoError(t, err)
+		for _, tx := range r.Txs {
+			for _, input := range tx.Inputs {
+				require.Equal(t, spv.StatusSkipped, input.Script)
+			}
+		}
+	})
+
+	t.Run("unparsable payment", func(t *testing.T) {
+		v, err := spv.NewPaymentVerifier(&headers)
+		require.NoError(t, err)
+		_, err = v.VerifyPaymentReport(ctx, nil)
+		require.ErrorIs(t, err, spv.ErrNilInitialPayment)
+	})
+}
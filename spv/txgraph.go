@@ -0,0 +1,249 @@
+package spv
+
+import (
+	"encoding/hex"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
+)
+
+// TxGraph is the ancestry of a payment as a graph of transactions. Each transaction is held
+// once, and is the parent of every transaction in the graph spending it, so an ancestor shared
+// by several transactions is not duplicated as it is in the nested ancestry formats.
+//
+// Every ancestry format can be converted to a TxGraph, which is what a payment is verified
+// against.
+type TxGraph struct {
+	nodes map[[32]byte]*TxNode
+	// ids are the ids of the nodes in the order they were added.
+	ids [][32]byte
+}
+
+// TxNode is a transaction in a TxGraph, with its merkle proof and MAPI responses.
+type TxNode struct {
+	Tx *bt.Tx
+	// Proof is the binary merkle proof of Tx, if it has one.
+	Proof         []byte
+	MapiResponses []*bc.MapiCallback
+}
+
+// NewTxGraph returns an empty TxGraph.
+func NewTxGraph() *TxGraph {
+	return &TxGraph{nodes: make(map[[32]byte]*TxNode)}
+}
+
+// NewTxGraphFromBytes converts a binary ancestry to a TxGraph. It is rejected with
+// ErrDuplicateTx if a transaction appears more than once, and with ErrLimitExceeded if it
+// exceeds the limits set, DefaultLimits by default.
+func NewTxGraphFromBytes(b []byte, opts ...DecodeOpt) (*TxGraph, error) {
+	return parseAncestry(b, newDecodeOptions(opts).limits)
+}
+
+// Add adds n to the graph, ErrDuplicateTx is returned if its transaction is already in it.
+func (g *TxGraph) Add(n *TxNode) error {
+	if n == nil || n.Tx == nil {
+		return errors.New("tx node has no tx")
+	}
+	id := txGraphID(n.Tx)
+	if _, ok := g.nodes[id]; ok {
+		return errors.Wrapf(ErrDuplicateTx, "tx %s", n.Tx.TxID())
+	}
+	g.nodes[id] = n
+	g.ids = append(g.ids, id)
+	return nil
+}
+
+// Len returns the number of transactions in the graph.
+func (g *TxGraph) Len() int {
+	return len(g.ids)
+}
+
+// Node returns the node of the transaction with id txID, or nil if it is not in the graph.
+func (g *TxGraph) Node(txID string) *TxNode {
+	b, err := hex.DecodeString(txID)
+	if err != nil || len(b) != 32 {
+		return nil
+	}
+	var id [32]byte
+	copy(id[:], b)
+	return g.nodes[id]
+}
+
+// Nodes returns the nodes of the graph in the order they were added.
+func (g *TxGraph) Nodes() []*TxNode {
+	nodes := make([]*TxNode, 0, len(g.ids))
+	for _, id := range g.ids {
+		nodes = append(nodes, g.nodes[id])
+	}
+	return nodes
+}
+
+// Parents returns the parents in the graph of the transaction with id txID, in the order of
+// the inputs spending them.
+func (g *TxGraph) Parents(txID string) []*TxNode {
+	n := g.Node(txID)
+	if n == nil {
+		return nil
+	}
+	parents := make([]*TxNode, 0, len(n.Tx.Inputs))
+	for _, id := range g.parents(n) {
+		parents = append(parents, g.nodes[id])
+	}
+	return parents
+}
+
+// TopologicalOrder returns the transaction with id txID, and its ancestors in the graph, each
+// before any of its own ancestors, with parents in the order of the inputs spending them. The
+// ancestors of a transaction with a merkle proof are not needed to verify it, so are left out.
+func (g *TxGraph) TopologicalOrder(txID string) ([]*TxNode, error) {
+	n := g.Node(txID)
+	if n == nil {
+		return nil, errors.Errorf("tx %s is not in the graph", txID)
+	}
+	ids, err := g.order([][32]byte{txGraphID(n.Tx)})
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*TxNode, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, g.nodes[id])
+	}
+	return nodes, nil
+}
+
+// clone returns a copy of the graph, sharing its nodes, which can be added to.
+func (g *TxGraph) clone() *TxGraph {
+	c := &TxGraph{
+		nodes: make(map[[32]byte]*TxNode, len(g.nodes)+1),
+		ids:   make([][32]byte, len(g.ids), len(g.ids)+1),
+	}
+	for id, n := range g.nodes {
+		c.nodes[id] = n
+	}
+	copy(c.ids, g.ids)
+	return c
+}
+
+// parents returns the ids of the parents of n in the graph, in the order of the inputs
+// spending them.
+func (g *TxGraph) parents(n *TxNode) [][32]byte {
+	var ids [][32]byte
+	added := make(map[[32]byte]bool)
+	for _, input := range n.Tx.Inputs {
+		var id [32]byte
+		copy(id[:], input.PreviousTxID())
+		if _, ok := g.nodes[id]; !ok || added[id] {
+			continue
+		}
+		added[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// order returns the ids of the roots, and their ancestors, in topological order. Each tx is
+// before any of its ancestors, with the roots, then the parents of each tx, in the order given
+// as far as that allows. Ancestors of a transaction with a merkle proof are left out.
+func (g *TxGraph) order(roots [][32]byte) ([][32]byte, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[[32]byte]int, len(g.ids))
+
+	// the reverse of a depth first post-order puts each tx before its ancestors. Roots, and
+	// parents, are visited last first, so once reversed the first is first.
+	post := make([][32]byte, 0, len(g.ids))
+	var visit func(id [32]byte) error
+	visit = func(id [32]byte) error {
+		switch state[id] {
+		case visiting:
+			return errors.Wrapf(ErrCyclicAncestry, "tx %s is its own ancestor", g.nodes[id].Tx.TxID())
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		n := g.nodes[id]
+		if n.Proof == nil {
+			parents := g.parents(n)
+			for i := len(parents) - 1; i >= 0; i-- {
+				if err := visit(parents[i]); err != nil {
+					return err
+				}
+			}
+		}
+		state[id] = visited
+		post = append(post, id)
+		return nil
+	}
+	for i := len(roots) - 1; i >= 0; i-- {
+		if err := visit(roots[i]); err != nil {
+			return nil, err
+		}
+	}
+	for i, j := 0, len(post)-1; i < j; i, j = i+1, j-1 {
+		post[i], post[j] = post[j], post[i]
+	}
+	return post, nil
+}
+
+// depth returns the number of transactions in the longest chain from the tx with id to an
+// ancestor in the graph, or an error once it is more than the limits allow. Ancestors of a
+// tx with a merkle proof are not needed to verify it so are not counted.
+func (g *TxGraph) depth(id [32]byte, l Limits) (int, error) {
+	depths := make(map[[32]byte]int, len(g.nodes))
+	var depth func(id [32]byte, d int) (int, error)
+	depth = func(id [32]byte, d int) (int, error) {
+		if err := l.depth(d); err != nil {
+			return 0, err
+		}
+		if n, ok := depths[id]; ok {
+			return n, l.depth(d + n - 1)
+		}
+		a := g.nodes[id]
+		n := 1
+		if a.Proof == nil {
+			for _, parentID := range g.parents(a) {
+				pn, err := depth(parentID, d+1)
+				if err != nil {
+					return 0, err
+				}
+				if pn+1 > n {
+					n = pn + 1
+				}
+			}
+		}
+		depths[id] = n
+		return n, nil
+	}
+	return depth(id, 1)
+}
+
+// newTxNode returns a node of the tx rawTx, with the binary format of proof if it has one.
+func newTxNode(rawTx string, proof *bc.MerkleProof) (*TxNode, error) {
+	b, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedTx, err.Error())
+	}
+	tx, err := newTxFromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	n := &TxNode{Tx: tx}
+	if proof != nil {
+		if n.Proof, err = proof.Bytes(); err != nil {
+			return nil, errors.Wrapf(err, "failed to serialise the proof of tx %s", tx.TxID())
+		}
+	}
+	return n, nil
+}
+
+// txGraphID returns the id of tx as it is keyed in a TxGraph.
+func txGraphID(tx *bt.Tx) [32]byte {
+	var id [32]byte
+	copy(id[:], tx.TxIDBytes())
+	return id
+}
@@ -0,0 +1,280 @@
+package spv_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libsv/go-bt/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
+)
+
+func TestTxGraph_Formats(t *testing.T) {
+	// g is a grandparent of the payment shared by its parents a and b.
+	g := spend(t, 2)
+	a := spend(t, 1, g)
+	b := spend(t, 2, g)
+	payment := spend(t, 1, a, b)
+	diamond := func() *spv.AncestryJSON {
+		return ancestryOf(payment, ancestryOf(a, ancestryOf(g)), ancestryOf(b, ancestryOf(g)))
+	}
+	ancestry, err := diamond().Bytes()
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		graph  func(t *testing.T) (*spv.TxGraph, error)
+		expTxs []*bt.Tx
+		expErr error
+	}{
+		"ancestry json": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				return diamond().TxGraph()
+			},
+			expTxs: []*bt.Tx{payment, a, g, b},
+		},
+		"envelope": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				e, err := spv.NewSpecialKEnvelopeFromBytes(specialKBytes(t, diamond()))
+				require.NoError(t, err)
+				return e.TxGraph()
+			},
+			expTxs: []*bt.Tx{payment, a, g, b},
+		},
+		"binary": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				return spv.NewTxGraphFromBytes(ancestry)
+			},
+			expTxs: []*bt.Tx{a, b, g},
+		},
+		"tsc json": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				tsc, err := spv.NewAncestryJSONFromBytes(ancestry)
+				require.NoError(t, err)
+				return tsc.TxGraph()
+			},
+			expTxs: []*bt.Tx{a, b, g},
+		},
+		"binary duplicate tx": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				return spv.NewTxGraphFromBytes(append(ancestry, ancestry[1:]...))
+			},
+			expErr: spv.ErrDuplicateTx,
+		},
+		"tsc json duplicate tx": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				tsc, err := spv.NewAncestryJSONFromBytes(ancestry)
+				require.NoError(t, err)
+				return append(tsc, tsc[0]).TxGraph()
+			},
+			expErr: spv.ErrDuplicateTx,
+		},
+		"tx nested beneath itself": {
+			graph: func(t *testing.T) (*spv.TxGraph, error) {
+				aa := ancestryOf(a)
+				aa.Parents = map[string]*spv.AncestryJSON{g.TxID(): ancestryOf(g, aa)}
+				return ancestryOf(payment, aa).TxGraph()
+			},
+			expErr: spv.ErrCyclicAncestry,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			graph, err := test.graph(t)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			txs := make([]string, 0, graph.Len())
+			for _, n := range graph.Nodes() {
+				txs = append(txs, n.Tx.TxID())
+			}
+			require.Equal(t, txIDs(test.expTxs...), txs)
+
+			// each tx is held once, and is the parent of both txs spending it.
+			require.Equal(t, txIDs(g), nodeIDs(graph.Parents(a.TxID())))
+			require.Equal(t, txIDs(g), nodeIDs(graph.Parents(b.TxID())))
+
+			order, err := graph.TopologicalOrder(a.TxID())
+			require.NoError(t, err)
+			require.Equal(t, txIDs(a, g), nodeIDs(order))
+			if graph.Node(payment.TxID()) != nil {
+				require.Equal(t, txIDs(a, b), nodeIDs(graph.Parents(payment.TxID())))
+				order, err := graph.TopologicalOrder(payment.TxID())
+				require.NoError(t, err)
+				require.Equal(t, txIDs(payment, a, b, g), nodeIDs(order))
+			}
+		})
+	}
+}
+
+func TestTxGraph_Add(t *testing.T) {
+	tx := spend(t, 1)
+	g := spv.NewTxGraph()
+	require.NoError(t, g.Add(&spv.TxNode{Tx: tx}))
+	require.ErrorIs(t, g.Add(&spv.TxNode{Tx: tx}), spv.ErrDuplicateTx)
+	require.Error(t, g.Add(&spv.TxNode{}))
+	require.Equal(t, 1, g.Len())
+	require.Equal(t, tx, g.Node(tx.TxID()).Tx)
+	require.Nil(t, g.Node(spend(t, 2).TxID()))
+
+	_, err := g.TopologicalOrder(spend(t, 2).TxID())
+	require.Error(t, err)
+}
+
+func TestTxGraph_SpecialKAnchoredParent(t *testing.T) {
+	g := spend(t, 1)
+	a := spend(t, 3)
+	b := spend(t, 2, g)
+	payment := spend(t, 1, a, b)
+	proof := &bc.MerkleProof{TxOrID: a.TxID(), Target: g.TxID(), TargetType: "blockhash", Nodes: []string{b.TxID()}}
+	anchored := ancestryOf(a)
+	anchored.Proof = proof
+
+	e, err := spv.NewSpecialKEnvelopeFromBytes(specialKBytes(t, ancestryOf(payment, anchored, ancestryOf(b, ancestryOf(g)))))
+	require.NoError(t, err)
+	graph, err := e.TxGraph()
+	require.NoError(t, err)
+
+	require.Equal(t, txIDs(payment, a, b, g), nodeIDs(graph.Nodes()))
+	require.Equal(t, txIDs(a, b), nodeIDs(graph.Parents(payment.TxID())))
+	exp, err := proof.Bytes()
+	require.NoError(t, err)
+	require.Equal(t, exp, graph.Node(a.TxID()).Proof)
+	require.Nil(t, graph.Node(b.TxID()).Proof)
+}
+
+func TestNewSpecialKEnvelopeFromBytes_Graph(t *testing.T) {
+	// chain is a chain of txs, each spending the one before it, which the payment spends the last of.
+	chain := []*bt.Tx{spend(t, 1)}
+	for len(chain) < 1000 {
+		chain = append(chain, spend(t, 1, chain[len(chain)-1]))
+	}
+	payment := spend(t, 1, chain[len(chain)-1])
+	// the chain is serialised oldest first, each tx before the one spending it.
+	reversed := append([]*bt.Tx{payment}, chain...)
+
+	tests := map[string]struct {
+		txs    []*bt.Tx
+		limits func(*spv.Limits)
+		expErr error
+	}{
+		"ancestors serialised before the txs spending them": {
+			txs: reversed,
+		},
+		"too deep": {
+			txs:    reversed,
+			limits: func(l *spv.Limits) { l.MaxDepth = 500 },
+			expErr: spv.ErrLimitExceeded,
+		},
+		"duplicate tx": {
+			txs:    []*bt.Tx{payment, chain[999], chain[998], chain[999]},
+			expErr: spv.ErrDuplicateTx,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			l := spv.DefaultLimits()
+			if test.limits != nil {
+				test.limits(&l)
+			}
+			e, err := spv.NewSpecialKEnvelopeFromBytes(specialKFlakes(test.txs...), spv.WithLimits(l))
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			// each tx has the tx it spends as its parent.
+			require.Equal(t, payment.TxID(), e.TxID)
+			for i := len(chain) - 1; i >= 0; i-- {
+				require.Len(t, e.Parents, 1)
+				e = e.Parents[chain[i].TxID()]
+				require.NotNil(t, e)
+				require.Equal(t, chain[i].TxID(), e.TxID)
+			}
+		})
+	}
+}
+
+func TestVerifyPayment_TxGraph(t *testing.T) {
+	headers := &mockBlockHeaderClient{
+		blockHeaderFunc: func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+			bb, err := data.BlockHeaderData.Load(hash)
+			if err != nil {
+				return nil, err
+			}
+			return bc.NewBlockHeaderFromStr(string(bb[:160]))
+		},
+	}
+	v, err := spv.NewPaymentVerifier(headers)
+	require.NoError(t, err)
+
+	for _, file := range []string{"valid.json", "valid_deep.json", "invalid_wrong_merkle_proof.json"} {
+		t.Run(file, func(t *testing.T) {
+			p := loadAncestry(t, file, nil)
+			exp, err := v.VerifyPaymentReport(context.Background(), p)
+			require.NoError(t, err)
+
+			g, err := spv.NewTxGraphFromBytes(p.Ancestry)
+			require.NoError(t, err)
+			n := g.Len()
+			report, err := v.VerifyPaymentReport(context.Background(), &spv.Payment{PaymentTx: p.PaymentTx, Graph: g})
+			require.NoError(t, err)
+			require.Equal(t, exp, report)
+			require.Equal(t, exp.Err(), v.VerifyPayment(context.Background(), &spv.Payment{PaymentTx: p.PaymentTx, Graph: g}))
+			// the payment is not added to the graph given.
+			require.Equal(t, n, g.Len())
+		})
+	}
+}
+
+func specialKBytes(t *testing.T, a *spv.AncestryJSON) []byte {
+	var e spv.Envelope
+	var convert func(a *spv.AncestryJSON) *spv.Envelope
+	convert = func(a *spv.AncestryJSON) *spv.Envelope {
+		e := &spv.Envelope{TxID: a.TxID, RawTx: a.RawTx, Proof: a.Proof, MapiResponses: a.MapiResponses, Parents: map[string]*spv.Envelope{}}
+		for id, parent := range a.Parents {
+			e.Parents[id] = convert(parent)
+		}
+		return e
+	}
+	e = *convert(a)
+	b, err := e.SpecialKBytes()
+	require.NoError(t, err)
+	return *b
+}
+
+// specialKFlakes returns the special k bytes of the txs in the order given, without proofs or
+// mapi responses.
+func specialKFlakes(txs ...*bt.Tx) []byte {
+	b := []byte{1}
+	for _, tx := range txs {
+		b = append(b, bt.VarInt(uint64(len(tx.Bytes()))).Bytes()...)
+		b = append(b, tx.Bytes()...)
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+func txIDs(txs ...*bt.Tx) []string {
+	ids := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		ids = append(ids, tx.TxID())
+	}
+	return ids
+}
+
+func nodeIDs(nodes []*spv.TxNode) []string {
+	ids := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		ids = append(ids, n.Tx.TxID())
+	}
+	return ids
+}
@@ -2,6 +2,7 @@ package spv
 
 import (
 	"context"
+	"runtime"
 
 	"github.com/libsv/go-bt/v2"
 	"github.com/pkg/errors"
@@ -15,6 +16,13 @@ type verifyOptions struct {
 	script   bool
 	fees     bool
 	feeQuote *bt.FeeQuote
+	lockTime bool
+	targets  bool
+	// minConfirmations is the confirmations each anchor must have.
+	minConfirmations uint64
+	confirmations    map[string]uint64
+	workers          int
+	limits           Limits
 }
 
 // clone will copy the verifyOptions to a new struct and return it.
@@ -24,6 +32,13 @@ func (v *verifyOptions) clone() *verifyOptions {
 		fees:     v.fees,
 		script:   v.script,
 		feeQuote: v.feeQuote,
+		lockTime: v.lockTime,
+		targets:  v.targets,
+
+		minConfirmations: v.minConfirmations,
+		confirmations:    v.confirmations,
+		workers:          v.workers,
+		limits:           v.limits,
 	}
 }
 
@@ -85,6 +100,87 @@ func NoVerifyScript() VerifyOpt {
 	}
 }
 
+// VerifyLockTime will ensure the payment transaction, and any unconfirmed transactions in
+// its ancestry, are final and so can be mined in the next block. A transaction is final
+// when its lock time has passed, measured against the median time past of the tip for a
+// time based lock, or all of its inputs have a final sequence number.
+//
+// The bc.BlockHeaderChain must implement bc.HeightIndexedBlockHeaderChain to check
+// transactions with a lock time set, bc.ErrNoHeightIndex is returned otherwise.
+func VerifyLockTime() VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.lockTime = true
+	}
+}
+
+// NoVerifyLockTime will switch off lock time verification and rely on
+// mAPI / node verification when the tx is broadcast.
+func NoVerifyLockTime() VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.lockTime = false
+	}
+}
+
+// VerifyProofTargets will ensure the block a merkle proof targets is on the longest chain.
+// A proof targeting a block header must have valid proof of work and the header must be
+// found in the bc.BlockHeaderChain, and a proof targeting a bare merkle root is rejected
+// with ErrUntrustedProofTarget, as the block it is the root of cannot be found.
+func VerifyProofTargets() VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.targets = true
+	}
+}
+
+// NoVerifyProofTargets will trust the block headers and merkle roots merkle proofs
+// target, so they are only checked when the target is a block hash. It should only be
+// used when proofs come from a trusted source.
+func NoVerifyProofTargets() VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.targets = false
+	}
+}
+
+// MinConfirmations will ensure each anchor in the ancestry, a transaction with a merkle
+// proof, is in a block with at least n confirmations, where the block at the tip of the
+// longest chain has one confirmation. A value of 0 switches the check off, it is off by default.
+//
+// The bc.BlockHeaderChain must implement bc.HeightIndexedBlockHeaderChain, bc.ErrNoHeightIndex
+// is returned otherwise, and anchors whose proofs target a merkle root are rejected as the
+// block they are in cannot be found.
+func MinConfirmations(n uint64) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.minConfirmations = n
+	}
+}
+
+// ReportConfirmations will record the confirmations of each anchor in the ancestry in
+// confirmations, keyed by txid, as its proof is verified. It should be passed to VerifyPayment
+// with a new map for each payment. The bc.BlockHeaderChain must implement
+// bc.HeightIndexedBlockHeaderChain.
+func ReportConfirmations(confirmations map[string]uint64) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.confirmations = confirmations
+	}
+}
+
+// Concurrency sets the number of transactions in an ancestry which have their proofs and
+// scripts verified at once, it defaults to the number of CPUs and 1 verifies them one at a
+// time. The bc.BlockHeaderChain must be safe for concurrent use when it is more than 1.
+func Concurrency(n int) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.workers = n
+	}
+}
+
+// DecodeLimits sets the limits of the ancestries, and merkle proofs, which are verified, it
+// defaults to DefaultLimits. A payment exceeding them is rejected with ErrLimitExceeded before
+// any of it is verified, so they should be kept when payments come from untrusted sources.
+func DecodeLimits(l Limits) VerifyOpt {
+	return func(opts *verifyOptions) {
+		opts.limits = l
+	}
+}
+
 // NoVerifySPV will turn off any spv validation for merkle proofs
 // and script validation. This is a helper method that is equivalent to
 // NoVerifyProofs && NoVerifyScripts.
@@ -112,6 +208,7 @@ func VerifySPV() VerifyOpt {
 // you are using, some may return a HeaderJSON response others may return the blockhash.
 type PaymentVerifier interface {
 	VerifyPayment(ctx context.Context, p *Payment, opts ...VerifyOpt) error
+	VerifyPaymentReport(ctx context.Context, p *Payment, opts ...VerifyOpt) (*VerificationReport, error)
 	MerkleProofVerifier
 }
 
@@ -133,12 +230,18 @@ type verifier struct {
 // opts control the global behaviour of the verifier and all options are enabled by default, they are:
 // - ancestry verification (proofs checked etc)
 // - fees checked, ensuring the root tx covers enough fees
-// - script verification which checks the script is correct (not currently implemented).
+// - script verification which checks the script is correct (not currently implemented)
+// - proof target verification, ensuring proofs target a block on the longest chain.
+//
+// Fee and lock time verification are disabled by default.
 func NewPaymentVerifier(bhc bc.BlockHeaderChain, opts ...VerifyOpt) (PaymentVerifier, error) {
 	o := &verifyOptions{
-		proofs: true,
-		fees:   false,
-		script: true,
+		proofs:  true,
+		fees:    false,
+		script:  true,
+		targets: true,
+		workers: runtime.NumCPU(),
+		limits:  DefaultLimits(),
 	}
 	for _, opt := range opts {
 		opt(o)
@@ -151,6 +254,8 @@ func NewPaymentVerifier(bhc bc.BlockHeaderChain, opts ...VerifyOpt) (PaymentVeri
 
 // NewMerkleProofVerifier creates a new spv.MerkleProofVerifer with the bc.BlockHeaderChain provided.
 // If no BlockHeaderChain implementation is provided, the setup will return an error.
-func NewMerkleProofVerifier(bhc bc.BlockHeaderChain) (MerkleProofVerifier, error) {
-	return NewPaymentVerifier(bhc)
+//
+// opts are as for NewPaymentVerifier, though only NoVerifyProofTargets affects merkle proofs.
+func NewMerkleProofVerifier(bhc bc.BlockHeaderChain, opts ...VerifyOpt) (MerkleProofVerifier, error) {
+	return NewPaymentVerifier(bhc, opts...)
 }
@@ -26,11 +26,18 @@ type MerkleProofValidation struct {
 	TxID         string
 	Valid        bool
 	IsLastInTree bool
+	// BlockHash is the hash of the block the proof targets, it is empty when the proof
+	// targets a merkle root.
+	BlockHash string
 }
 
 // VerifyMerkleProof verifies a Merkle Proof in standard byte format.
 func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*MerkleProofValidation, error) {
-	mpb, err := parseBinaryMerkleProof(proof)
+	return v.verifyMerkleProof(ctx, proof, v.opts)
+}
+
+func (v *verifier) verifyMerkleProof(ctx context.Context, proof []byte, o *verifyOptions) (*MerkleProofValidation, error) {
+	mpb, err := parseBinaryMerkleProof(proof, o.limits)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +56,7 @@ func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*Merkle
 		TxID: txid,
 	}
 
-	var merkleRoot string
+	var merkleRoot, blockHash string
 	switch mpb.flags & targetTypeFlags {
 	// if bits 1 and 2 of flags are NOT set, target should contain a block hash (32 bytes)
 	case 0:
@@ -60,17 +67,21 @@ func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*Merkle
 		}
 
 		merkleRoot = blockHeader.HashMerkleRootStr()
+		blockHash = mpb.target
 
 	// if bit 2 of flags is set, target should contain a merkle root (32 bytes)
 	case 4:
 		// the `target` field contains a merkle root
+		if o.targets {
+			return response, ErrUntrustedProofTarget
+		}
 		merkleRoot = mpb.target
 
 	// if bit 1 of flags is set, target should contain a block header (80 bytes)
 	case 2:
 		// The `target` field contains a block header
 		var err error
-		merkleRoot, err = bc.ExtractMerkleRootFromBlockHeader(mpb.target)
+		merkleRoot, blockHash, err = v.headerMerkleRoot(ctx, mpb.target, o)
 		if err != nil {
 			return response, err
 		}
@@ -101,12 +112,16 @@ func (v *verifier) VerifyMerkleProof(ctx context.Context, proof []byte) (*Merkle
 		TxID:         txid,
 		Valid:        valid,
 		IsLastInTree: isLastInTree,
+		BlockHash:    blockHash,
 	}, err
 }
 
 // VerifyMerkleProofJSON verifies a Merkle Proof in standard JSON format.
 func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerkleProof) (bool, bool, error) {
+	return v.verifyMerkleProofJSON(ctx, proof, v.opts)
+}
 
+func (v *verifier) verifyMerkleProofJSON(ctx context.Context, proof *bc.MerkleProof, o *verifyOptions) (bool, bool, error) {
 	txid, err := txidFromTxOrID(proof.TxOrID)
 	if err != nil {
 		return false, false, err
@@ -129,13 +144,16 @@ func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerklePr
 	} else if proof.TargetType == h && len(proof.Target) == 160 {
 		// The `target` field contains a block header
 		var err error
-		merkleRoot, err = bc.ExtractMerkleRootFromBlockHeader(proof.Target)
+		merkleRoot, _, err = v.headerMerkleRoot(ctx, proof.Target, o)
 		if err != nil {
 			return false, false, err
 		}
 
 	} else if proof.TargetType == "merkleRoot" && len(proof.Target) == 64 {
 		// the `target` field contains a merkle root
+		if o.targets {
+			return false, false, ErrUntrustedProofTarget
+		}
 		merkleRoot = proof.Target
 
 	} else {
@@ -161,6 +179,35 @@ func (v *verifier) VerifyMerkleProofJSON(ctx context.Context, proof *bc.MerklePr
 	return verifyProof(txid, merkleRoot, proof.Index, proof.Nodes)
 }
 
+// headerMerkleRoot returns the merkle root and hash of a block header a proof targets. When
+// proof targets are verified the header must have valid proof of work and be on the longest chain.
+func (v *verifier) headerMerkleRoot(ctx context.Context, header string, o *verifyOptions) (string, string, error) {
+	bh, err := bc.NewBlockHeaderFromStr(header)
+	if err != nil {
+		return "", "", err
+	}
+	hash := bh.Hash()
+	if !o.targets {
+		return bh.HashMerkleRootStr(), hash.String(), nil
+	}
+
+	if !bh.Valid() {
+		return "", "", ErrInvalidProofTarget
+	}
+	if v.bhc == nil {
+		return "", "", errors.New("a block header chain is required to verify proof targets")
+	}
+	chainHeader, err := v.bhc.BlockHeader(ctx, hash.String())
+	if err != nil {
+		return "", "", err
+	}
+	if chainHeader.HashMerkleRootStr() != bh.HashMerkleRootStr() {
+		return "", "", fmt.Errorf("%w: block %s has a different merkle root on the chain", ErrProofTargetMismatch, hash)
+	}
+
+	return bh.HashMerkleRootStr(), hash.String(), nil
+}
+
 func verifyProof(c, merkleRoot string, index uint64, nodes []string) (bool, bool, error) {
 	isLastInTree := true
 
@@ -229,7 +276,11 @@ func txidFromTxOrID(txOrID string) (string, error) {
 
 	// The `txOrId` field contains a full transaction
 	if len(txOrID) > 64 {
-		tx, err := bt.NewTxFromString(txOrID)
+		b, err := hex.DecodeString(txOrID)
+		if err != nil {
+			return "", err
+		}
+		tx, err := newTxFromBytes(b)
 		if err != nil {
 			return "", err
 		}
@@ -248,21 +299,24 @@ type merkleProofBinary struct {
 	nodes  []string
 }
 
-func parseBinaryMerkleProof(proof []byte) (*merkleProofBinary, error) {
+func parseBinaryMerkleProof(proof []byte, l Limits) (*merkleProofBinary, error) {
 	mpb := &merkleProofBinary{}
-
-	var offset int
+	r := bc.NewDecoder(proof, ErrMalformedProof)
 
 	// flags is first byte
-	mpb.flags = proof[offset]
-	offset++
+	flags, err := r.Byte("flags")
+	if err != nil {
+		return nil, err
+	}
+	mpb.flags = flags
 
 	// index is the next varint after the 1st byte
-	index, size := bt.NewVarIntFromBytes(proof[offset:])
-	mpb.index = uint64(index)
-	offset += size
+	mpb.index, err = r.VarInt("index")
+	if err != nil {
+		return nil, err
+	}
 
-	var txLength bt.VarInt
+	var txLength uint64
 	// if bit 1 of flags is NOT set, txOrId should contain txid (= 32 bytes)
 	if mpb.flags&1 == 0 {
 		txLength = 32
@@ -271,49 +325,72 @@ func parseBinaryMerkleProof(proof []byte) (*merkleProofBinary, error) {
 	// if bit 1 of flags is set, txOrId should contain tx hex (> 32 bytes)
 	if mpb.flags&1 == 1 {
 		// txLength is the next varint after the 1st byte + index size
-		txLength, size = bt.NewVarIntFromBytes(proof[offset:])
-		offset += size
+		txLength, err = r.VarInt("tx length")
+		if err != nil {
+			return nil, err
+		}
 		if txLength <= 32 {
 			return nil, errors.New("invalid tx length (should be greater than 32 bytes)")
 		}
 	}
 
 	// txOrID is the next txLength bytes after 1st byte + index size (+ txLength size)
-	mpb.txOrID = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+int(txLength)]))
-	offset += int(txLength)
+	txOrID, err := r.Bytes("txOrId", txLength)
+	if err != nil {
+		return nil, err
+	}
+	mpb.txOrID = hex.EncodeToString(bt.ReverseBytes(txOrID))
 
+	var targetLength uint64
 	switch mpb.flags & targetTypeFlags {
 	// if bits 1 and 2 of flags are NOT set, target should contain a block hash (32 bytes)
 	// if bit 2 of flags is set, target should contain a merkle root (32 bytes)
 	case 0, 4:
-		mpb.target = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+32]))
-		offset += 32
+		targetLength = 32
 
 	// if bit 1 of flags is set, target should contain a block header (80 bytes)
 	case 2:
-		mpb.target = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+80]))
-		offset += 80
+		targetLength = 80
 
 	default:
 		return nil, ErrInvalidMerkleFlags
 	}
+	target, err := r.Bytes("target", targetLength)
+	if err != nil {
+		return nil, err
+	}
+	mpb.target = hex.EncodeToString(bt.ReverseBytes(target))
 
-	nodeCount, size := bt.NewVarIntFromBytes(proof[offset:])
-	offset += size
+	nodeCount, err := r.VarInt("node count")
+	if err != nil {
+		return nil, err
+	}
+	if err := l.proofNodes(nodeCount); err != nil {
+		return nil, err
+	}
+	// each node is at least a byte, so the count is checked before any are read.
+	if nodeCount > uint64(r.Remaining()) {
+		return nil, r.Truncated("nodes", nodeCount)
+	}
 
 	if mpb.index >= 1<<nodeCount {
 		return nil, ErrInvalidProof
 	}
 
 	for i := 0; i < int(nodeCount); i++ {
-		t := proof[offset]
-		offset++
+		t, err := r.Byte("node type")
+		if err != nil {
+			return nil, err
+		}
 
 		var n string
 		switch t {
 		case 0:
-			n = hex.EncodeToString(bt.ReverseBytes(proof[offset : offset+32]))
-			offset += 32
+			node, err := r.Bytes("node", 32)
+			if err != nil {
+				return nil, err
+			}
+			n = hex.EncodeToString(bt.ReverseBytes(node))
 		case 1:
 			n = "*"
 
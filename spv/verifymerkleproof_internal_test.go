@@ -25,7 +25,7 @@ func TestParseBinaryMerkleProof(t *testing.T) {
 
 	proof, _ := proofJSON.Bytes()
 
-	mpb, err := parseBinaryMerkleProof(proof)
+	mpb, err := parseBinaryMerkleProof(proof, DefaultLimits())
 
 	require.NoError(t, err)
 	require.Equal(t, proofJSON.Index, mpb.index)
@@ -2,6 +2,7 @@ package spv_test
 
 import (
 	"context"
+	"encoding/hex"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/libsv/go-bc"
 	"github.com/libsv/go-bc/spv"
+	"github.com/libsv/go-bc/testing/data"
 )
 
 type mockBlockHeaderChain struct{}
@@ -52,3 +54,116 @@ func TestVerifyMerkleProof(t *testing.T) {
 		assert.True(t, mpv.Valid)
 	})
 }
+
+// hashChain is a bc.BlockHeaderChain holding the headers provided.
+type hashChain map[string]*bc.BlockHeader
+
+func (c hashChain) BlockHeader(_ context.Context, blockHash string) (*bc.BlockHeader, error) {
+	bh, ok := c[blockHash]
+	if !ok {
+		return nil, bc.ErrHeaderNotFound
+	}
+	return bh, nil
+}
+
+func TestVerifyMerkleProof_Targets(t *testing.T) {
+	t.Parallel()
+
+	b, err := data.HeadersData.Load("mainnet_0_4032.bin")
+	require.NoError(t, err)
+	genesis, err := bc.NewBlockHeaderFromBytes(b[:80])
+	require.NoError(t, err)
+	block1, err := bc.NewBlockHeaderFromBytes(b[80:160])
+	require.NoError(t, err)
+	chain := hashChain{genesis.Hash().String(): genesis, block1.Hash().String(): block1}
+
+	// block 1 holds only its coinbase, so its txid is the merkle root.
+	fabricated := *block1
+	fabricated.HashMerkleRoot = mustDecode(t, "ffeff11c25cde7c06d407490d81ef4d0db64aad6ab3d14393530701561a465ef")
+
+	tests := map[string]struct {
+		chain  bc.BlockHeaderChain
+		opts   []spv.VerifyOpt
+		header *bc.BlockHeader
+		root   bool
+		expErr error
+	}{
+		"header on the chain is valid": {
+			chain:  chain,
+			header: block1,
+		},
+		"header not on the chain errors": {
+			chain:  hashChain{genesis.Hash().String(): genesis},
+			header: block1,
+			expErr: bc.ErrHeaderNotFound,
+		},
+		"fabricated header errors": {
+			chain:  chain,
+			header: &fabricated,
+			expErr: spv.ErrInvalidProofTarget,
+		},
+		"header whose merkle root differs on the chain errors": {
+			chain:  hashChain{block1.Hash().String(): &fabricated},
+			header: block1,
+			expErr: spv.ErrProofTargetMismatch,
+		},
+		"fabricated header is trusted when targets are not verified": {
+			chain:  chain,
+			opts:   []spv.VerifyOpt{spv.NoVerifyProofTargets()},
+			header: &fabricated,
+		},
+		"merkle root errors": {
+			chain:  chain,
+			header: block1,
+			root:   true,
+			expErr: spv.ErrUntrustedProofTarget,
+		},
+		"merkle root is trusted when targets are not verified": {
+			chain:  chain,
+			opts:   []spv.VerifyOpt{spv.NoVerifyProofTargets()},
+			header: block1,
+			root:   true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			proof := &bc.MerkleProof{
+				TxOrID:     test.header.HashMerkleRootStr(),
+				Target:     test.header.String(),
+				TargetType: "header",
+			}
+			if test.root {
+				proof.Target = test.header.HashMerkleRootStr()
+				proof.TargetType = "merkleRoot"
+			}
+			v, err := spv.NewMerkleProofVerifier(test.chain, test.opts...)
+			require.NoError(t, err)
+
+			valid, _, err := v.VerifyMerkleProofJSON(context.Background(), proof)
+			require.ErrorIs(t, err, test.expErr)
+			require.Equal(t, test.expErr == nil, valid)
+
+			pb, err := proof.Bytes()
+			require.NoError(t, err)
+			mpv, err := v.VerifyMerkleProof(context.Background(), pb)
+			require.ErrorIs(t, err, test.expErr)
+			if test.expErr == nil {
+				require.True(t, mpv.Valid)
+				expHash := test.header.Hash()
+				if test.root {
+					require.Empty(t, mpv.BlockHash)
+				} else {
+					require.Equal(t, expHash.String(), mpv.BlockHash)
+				}
+			}
+		})
+	}
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
@@ -2,115 +2,383 @@ package spv
 
 import (
 	"context"
+	"sync"
 
 	"github.com/pkg/errors"
+
+	"github.com/libsv/go-bc"
 )
 
 // VerifyPayment is a method for parsing a binary payment transaction and its corresponding ancestry in binary.
-// It will return the paymentTx struct if all validations pass.
+// It will return nil if all validations pass, otherwise the first failure, a *TxError, in the order
+// transactions are reported by VerifyPaymentReport. The error of ctx is returned if it is done
+// before verification finishes.
 func (v *verifier) VerifyPayment(ctx context.Context, p *Payment, opts ...VerifyOpt) error {
+	r, err := v.verifyPayment(ctx, p, false, opts)
+	if err != nil {
+		return err
+	}
+	return r.Err()
+}
+
+// VerifyPaymentReport verifies a payment as VerifyPayment does, but checks every transaction
+// in its ancestry rather than stopping at the first failure, and returns the result of each
+// check. An error is only returned if the payment cannot be parsed.
+func (v *verifier) VerifyPaymentReport(ctx context.Context, p *Payment, opts ...VerifyOpt) (*VerificationReport, error) {
+	return v.verifyPayment(ctx, p, true, opts)
+}
+
+func (v *verifier) verifyPayment(ctx context.Context, p *Payment, report bool, opts []VerifyOpt) (*VerificationReport, error) {
 	o := v.opts.clone()
 	for _, opt := range opts {
 		opt(o)
 	}
 	if o.proofs && v == nil {
-		return errors.New("Merkle Proof Verifier is required when proofs is set")
+		return nil, errors.New("Merkle Proof Verifier is required when proofs is set")
+	}
+	if p == nil || p.PaymentTx == nil {
+		return nil, ErrNilInitialPayment
 	}
 
-	aa, err := parseAncestry(p.Ancestry)
+	g, err := paymentGraph(p, o.limits)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var paymentTxID [32]byte
-	copy(paymentTxID[:], p.PaymentTx.TxIDBytes())
-	aa[paymentTxID] = &ancestry{
+	// the payment is verified by its ancestry, so any proof it is given is not used.
+	paymentTxID := txGraphID(p.PaymentTx)
+	if _, ok := g.nodes[paymentTxID]; !ok {
+		g.ids = append(g.ids, paymentTxID)
+	}
+	g.nodes[paymentTxID] = &TxNode{
 		Tx: p.PaymentTx,
 	}
+	if _, err := g.depth(paymentTxID, o.limits); err != nil {
+		return nil, err
+	}
+
+	ids, err := verifyOrder(g, paymentTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &VerificationReport{TxID: p.PaymentTx.TxID()}
+	reports := make(map[[32]byte]*TxReport, len(g.nodes))
+	for _, id := range ids {
+		tr := newTxReport(g.nodes[id].Tx.TxID())
+		reports[id] = tr
+		r.Txs = append(r.Txs, tr)
+	}
+
+	if o.lockTime {
+		v.verifyLockTimes(ctx, g, reports)
+	}
 	if o.fees {
-		if o.feeQuote == nil {
-			return ErrNoFeeQuoteSupplied
-		}
-		for i, input := range p.PaymentTx.Inputs {
-			var inputID [32]byte
-			copy(inputID[:], input.PreviousTxID())
-			parent, ok := aa[inputID]
-			if !ok {
-				return errors.Wrapf(ErrNoFeeQuoteSupplied, "missing tx for input %d", i)
+		verifyFees(p, g, o, reports[paymentTxID])
+	}
+	if err := v.verifyTxs(ctx, ids, g, o, reports, report); err != nil {
+		return nil, err
+	}
+	if o.confirmations != nil {
+		for _, tr := range r.Txs {
+			if tr.Confirmations > 0 {
+				o.confirmations[tr.TxID] = tr.Confirmations
 			}
+		}
+	}
 
-			out := parent.Tx.OutputIdx(int(input.PreviousTxOutIndex))
-			if out == nil {
-				return ErrMissingOutput
-			}
+	return r, nil
+}
 
-			input.PreviousTxSatoshis = out.Satoshis
-		}
-		ok, err := p.PaymentTx.IsFeePaidEnough(o.feeQuote)
-		if err != nil {
-			return err
+// verifyOrder returns the ids of the txs in g in the order they are verified and reported,
+// the payment then its ancestors in topological order, so a tx comes before its ancestors and
+// the first to fail is that nearest the payment. Any txs which are not ancestors of the
+// payment, or are ancestors of a tx with a proof, follow in the same order.
+func verifyOrder(g *TxGraph, paymentTxID [32]byte) ([][32]byte, error) {
+	ids, err := g.order([][32]byte{paymentTxID})
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == g.Len() {
+		return ids, nil
+	}
+	ordered := make(map[[32]byte]bool, g.Len())
+	for _, id := range ids {
+		ordered[id] = true
+	}
+	var others [][32]byte
+	for _, id := range g.ids {
+		if !ordered[id] {
+			others = append(others, id)
 		}
-		if !ok {
-			return ErrFeePaidNotEnough
+	}
+	if others, err = g.order(others); err != nil {
+		return nil, err
+	}
+	for _, id := range others {
+		if !ordered[id] {
+			ordered[id] = true
+			ids = append(ids, id)
 		}
 	}
-	for _, a := range aa {
-		inputsToCheck := make(map[[32]byte]*extendedInput)
-		if len(a.Tx.Inputs) == 0 {
-			return ErrNoTxInputsToVerify
+	return ids, nil
+}
+
+// paymentGraph returns the graph of the ancestry of the payment, a copy of its Graph if it has
+// one, otherwise parsed from its Ancestry. The payment can then be added without changing it.
+func paymentGraph(p *Payment, l Limits) (*TxGraph, error) {
+	if p.Graph == nil {
+		return parseAncestry(p.Ancestry, l)
+	}
+	if err := l.txs(p.Graph.Len()); err != nil {
+		return nil, err
+	}
+	for _, n := range p.Graph.nodes {
+		if err := l.mapiResponses(len(n.MapiResponses)); err != nil {
+			return nil, err
 		}
-		for idx, input := range a.Tx.Inputs {
-			var inputID [32]byte
-			copy(inputID[:], input.PreviousTxID())
-			inputsToCheck[inputID] = &extendedInput{
-				input: input,
-				vin:   idx,
+	}
+	return p.Graph.clone(), nil
+}
+
+// verifyTxs verifies the transactions, in the order of ids, across the configured number of
+// workers. Unless a report is being made, once a transaction fails those after it are not
+// verified, or are cancelled, while those before it are still verified. The first failure in
+// the order of ids is then the same however the work is scheduled.
+func (v *verifier) verifyTxs(ctx context.Context, ids [][32]byte, g *TxGraph, o *verifyOptions,
+	reports map[[32]byte]*TxReport, report bool) error {
+	workers := o.workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(ids) {
+		workers = len(ids)
+	}
+
+	var mu sync.Mutex
+	var next int
+	cancels := make([]context.CancelFunc, len(ids))
+	// failed is the index of the first transaction to fail, the lock time and fee checks may
+	// already have failed a transaction.
+	failed := len(ids)
+	if !report {
+		for i, id := range ids {
+			if len(reports[id].Errs) > 0 {
+				failed = i
+				break
 			}
 		}
-		// if we have a proof, check it.
-		if o.proofs {
-			if a.Proof == nil {
-				for inputID := range inputsToCheck {
-					// check if we have that ancestry, if not validation fail.
-					if aa[inputID] == nil {
-						return ErrProofOrInputMissing
-					}
-				}
-			} else {
-				// check proof.
-				response, err := v.VerifyMerkleProof(ctx, a.Proof)
-				if response == nil {
-					return ErrInvalidProof
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				if i >= len(ids) || i > failed || ctx.Err() != nil {
+					mu.Unlock()
+					return
 				}
-				if response.TxID != "" && response.TxID != a.Tx.TxID() {
-					return ErrTxIDMismatch
+				next++
+				txCtx, cancel := context.WithCancel(ctx)
+				cancels[i] = cancel
+				mu.Unlock()
+
+				tr := reports[ids[i]]
+				v.verifyTx(txCtx, g.nodes[ids[i]], g, o, tr, report)
+				cancel()
+				if report || len(tr.Errs) == 0 {
+					continue
 				}
-				if err != nil || !response.Valid {
-					return ErrInvalidProof
+
+				mu.Lock()
+				if i < failed {
+					failed = i
+					for _, cancel := range cancels[i+1:] {
+						if cancel != nil {
+							cancel()
+						}
+					}
 				}
+				mu.Unlock()
 			}
+		}()
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// verifyFees checks the payment transaction pays enough fees, recording the result in its report.
+func verifyFees(p *Payment, g *TxGraph, o *verifyOptions, tr *TxReport) {
+	tr.Fees = StatusFailed
+	if o.feeQuote == nil {
+		tr.fail(-1, ErrNoFeeQuoteSupplied, nil)
+		return
+	}
+	for i, input := range p.PaymentTx.Inputs {
+		var inputID [32]byte
+		copy(inputID[:], input.PreviousTxID())
+		parent, ok := g.nodes[inputID]
+		if !ok {
+			tr.fail(i, ErrCannotCalculateFeePaid, errors.New("missing parent tx"))
+			return
 		}
-		if o.script {
-			// otherwise check the inputs.
-			for inputID, extendedInput := range inputsToCheck {
-				input := extendedInput.input
-				// check if we have that ancestry, if not validation fail.
-				if aa[inputID] == nil {
-					if a.Proof == nil && o.proofs {
-						return ErrProofOrInputMissing
-					}
-					continue
-				}
-				if len(aa[inputID].Tx.Outputs) <= int(input.PreviousTxOutIndex) {
-					return ErrInputRefsOutOfBoundsOutput
-				}
-				lockingScript := aa[inputID].Tx.Outputs[input.PreviousTxOutIndex].LockingScript
-				unlockingScript := input.UnlockingScript
-				if !verifyInputOutputPair(a.Tx, lockingScript, unlockingScript) {
-					return ErrPaymentNotVerified
+
+		out := parent.Tx.OutputIdx(int(input.PreviousTxOutIndex))
+		if out == nil {
+			tr.fail(i, ErrMissingOutput, nil)
+			return
+		}
+
+		input.PreviousTxSatoshis = out.Satoshis
+	}
+	ok, err := p.PaymentTx.IsFeePaidEnough(o.feeQuote)
+	if err != nil {
+		tr.fail(-1, ErrFeePaidNotEnough, err)
+		return
+	}
+	if !ok {
+		tr.fail(-1, ErrFeePaidNotEnough, nil)
+		return
+	}
+	tr.Fees = StatusPassed
+}
+
+// verifyTx checks the proof, or the inputs, and the scripts of a transaction, recording the
+// results in its report.
+func (v *verifier) verifyTx(ctx context.Context, a *TxNode, g *TxGraph, o *verifyOptions, tr *TxReport, report bool) {
+	if len(a.Tx.Inputs) == 0 {
+		tr.fail(-1, ErrNoTxInputsToVerify, nil)
+		return
+	}
+	tr.Inputs = make([]InputReport, len(a.Tx.Inputs))
+	for idx, input := range a.Tx.Inputs {
+		tr.Inputs[idx] = InputReport{
+			Vin:                idx,
+			PreviousTxID:       input.PreviousTxIDStr(),
+			PreviousTxOutIndex: input.PreviousTxOutIndex,
+			Script:             StatusSkipped,
+		}
+	}
+	parentOf := func(idx int) *TxNode {
+		var inputID [32]byte
+		copy(inputID[:], a.Tx.Inputs[idx].PreviousTxID())
+		return g.nodes[inputID]
+	}
+
+	// if we have a proof, check it.
+	switch {
+	case a.Proof == nil:
+		tr.Proof = StatusNone
+		if o.proofs {
+			// check we have each parent, if not validation fails.
+			for idx := range a.Tx.Inputs {
+				if parentOf(idx) == nil {
+					tr.fail(idx, ErrProofOrInputMissing, nil)
 				}
 			}
 		}
+	case o.proofs:
+		v.verifyTxProof(ctx, a, o, tr, report)
+	}
+
+	if o.script {
+		for idx, input := range a.Tx.Inputs {
+			parent := parentOf(idx)
+			// a missing parent of a tx without a proof has already failed.
+			if parent == nil {
+				continue
+			}
+			if len(parent.Tx.Outputs) <= int(input.PreviousTxOutIndex) {
+				tr.Inputs[idx].Script = StatusFailed
+				tr.fail(idx, ErrInputRefsOutOfBoundsOutput, nil)
+				continue
+			}
+			// the unlocking script is not run against the locking script it spends, as there is
+			// no script interpreter yet.
+			tr.Inputs[idx].Script = StatusNotImplemented
+		}
+	}
+
+	verifyMapiCallbacks(a, tr)
+}
+
+// verifyTxProof checks the merkle proof of a transaction, and the confirmations of the block
+// it is in, recording the results in its report.
+func (v *verifier) verifyTxProof(ctx context.Context, a *TxNode, o *verifyOptions, tr *TxReport, report bool) {
+	tr.Proof = StatusFailed
+	response, err := v.verifyMerkleProof(ctx, a.Proof, o)
+	switch {
+	case response == nil:
+		tr.fail(-1, ErrInvalidProof, err)
+		return
+	case response.TxID != "" && response.TxID != tr.TxID:
+		tr.fail(-1, ErrTxIDMismatch, errors.Errorf("proof is of tx %s", response.TxID))
+		return
+	case err != nil:
+		tr.fail(-1, ErrInvalidProof, err)
+		return
+	case !response.Valid:
+		tr.fail(-1, ErrInvalidProof, nil)
+		return
+	}
+	tr.Proof = StatusPassed
+	tr.BlockHash = response.BlockHash
+
+	v.verifyConfirmations(ctx, o, tr, report)
+}
+
+// verifyConfirmations checks, and records, the confirmations of an anchor when minimum
+// confirmations are set or reported. They are also recorded for a report when the chain
+// is height indexed.
+func (v *verifier) verifyConfirmations(ctx context.Context, o *verifyOptions, tr *TxReport, report bool) {
+	required := o.minConfirmations > 0 || o.confirmations != nil
+	if !required {
+		if _, ok := v.bhc.(bc.HeightIndexedBlockHeaderChain); !ok || !report || tr.BlockHash == "" {
+			return
+		}
+	}
+	if tr.BlockHash == "" {
+		tr.fail(-1, ErrUntrustedProofTarget, errors.New("cannot find the confirmations of a proof targeting a merkle root"))
+		return
+	}
+
+	confirmations, err := bc.Confirmations(ctx, v.bhc, tr.BlockHash)
+	if err != nil {
+		if required {
+			tr.fail(-1, ErrNotEnoughConfirmations, err)
+		}
+		return
+	}
+	tr.Confirmations = confirmations
+	if confirmations < o.minConfirmations {
+		tr.fail(-1, ErrNotEnoughConfirmations, errors.Errorf("%d confirmations, %d required",
+			confirmations, o.minConfirmations))
+	}
+}
+
+// verifyMapiCallbacks checks the MAPI callbacks of a transaction are for it, and for the block
+// its proof targets, recording the result in its report.
+func verifyMapiCallbacks(a *TxNode, tr *TxReport) {
+	if len(a.MapiResponses) == 0 {
+		return
+	}
+	tr.MapiCallbacks = a.MapiResponses
+	tr.Mapi = StatusPassed
+	for _, cb := range a.MapiResponses {
+		switch {
+		case cb.CallbackTxID != "" && cb.CallbackTxID != tr.TxID:
+			tr.MapiErr = errors.Wrapf(ErrMapiCallbackMismatch, "callback is of tx %s", cb.CallbackTxID)
+		case cb.BlockHash != "" && tr.BlockHash != "" && cb.BlockHash != tr.BlockHash:
+			tr.MapiErr = errors.Wrapf(ErrMapiCallbackMismatch, "callback is of block %s, proof of block %s", cb.BlockHash, tr.BlockHash)
+		default:
+			continue
+		}
+		tr.Mapi = StatusFailed
+		return
 	}
-	return nil
 }
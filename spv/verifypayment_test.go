@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"math/rand"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -321,3 +324,236 @@ func TestVerifyAncestryBinary(t *testing.T) {
 		})
 	}
 }
+
+// heightChain is a height indexed chain which holds every header in the test data at height,
+// below its tip at tip.
+type heightChain struct {
+	mockBlockHeaderClient
+	height uint64
+	tip    uint64
+}
+
+func (c *heightChain) ChainTip(context.Context) (uint64, string, error) {
+	return c.tip, "tip", nil
+}
+
+func (c *heightChain) BlockHeaderByHeight(context.Context, uint64) (*bc.BlockHeader, error) {
+	return nil, bc.ErrHeaderNotFound
+}
+
+func (c *heightChain) BlockHeight(ctx context.Context, blockHash string) (uint64, error) {
+	if _, err := c.BlockHeader(ctx, blockHash); err != nil {
+		return 0, bc.ErrHeaderNotFound
+	}
+	return c.height, nil
+}
+
+func TestVerifyPayment_MinConfirmations(t *testing.T) {
+	testData := struct {
+		Envelope *spv.AncestryJSON `json:"data"`
+	}{}
+	bb, err := data.SpvVerifyData.Load("valid.json")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testData))
+	ancestry, err := testData.Envelope.Bytes()
+	require.NoError(t, err)
+	tx, err := bt.NewTxFromString(testData.Envelope.RawTx)
+	require.NoError(t, err)
+
+	mch := mockBlockHeaderClient{
+		blockHeaderFunc: func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+			bb, err := data.BlockHeaderData.Load(hash)
+			if err != nil {
+				return nil, err
+			}
+			return bc.NewBlockHeaderFromStr(string(bb[:160]))
+		},
+	}
+
+	tests := map[string]struct {
+		bhc              bc.BlockHeaderChain
+		opts             []spv.VerifyOpt
+		expConfirmations uint64
+		expErr           error
+	}{
+		"enough confirmations passes": {
+			bhc:              &heightChain{mockBlockHeaderClient: mch, height: 100, tip: 105},
+			opts:             []spv.VerifyOpt{spv.MinConfirmations(6)},
+			expConfirmations: 6,
+		},
+		"anchor at the tip has one confirmation": {
+			bhc:              &heightChain{mockBlockHeaderClient: mch, height: 100, tip: 100},
+			opts:             []spv.VerifyOpt{spv.MinConfirmations(1)},
+			expConfirmations: 1,
+		},
+		"too few confirmations fails": {
+			bhc:              &heightChain{mockBlockHeaderClient: mch, height: 100, tip: 104},
+			opts:             []spv.VerifyOpt{spv.MinConfirmations(6)},
+			expConfirmations: 5,
+			expErr:           spv.ErrNotEnoughConfirmations,
+		},
+		"confirmations are reported without a minimum": {
+			bhc:              &heightChain{mockBlockHeaderClient: mch, height: 100, tip: 199},
+			expConfirmations: 100,
+		},
+		"confirmations without a height index fails": {
+			bhc:    &mch,
+			opts:   []spv.VerifyOpt{spv.MinConfirmations(6)},
+			expErr: bc.ErrNoHeightIndex,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			v, err := spv.NewPaymentVerifier(test.bhc)
+			require.NoError(t, err)
+
+			confirmations := map[string]uint64{}
+			opts := append(test.opts, spv.ReportConfirmations(confirmations))
+			err = v.VerifyPayment(context.Background(), &spv.Payment{
+				PaymentTx: tx,
+				Ancestry:  ancestry,
+			}, opts...)
+			require.ErrorIs(t, err, test.expErr)
+			if test.expConfirmations == 0 {
+				require.Empty(t, confirmations)
+				return
+			}
+			require.NotEmpty(t, confirmations)
+			for _, c := range confirmations {
+				require.Equal(t, test.expConfirmations, c)
+			}
+		})
+	}
+}
+
+func TestVerifyPayment_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	headers := func(_ context.Context, hash string) (*bc.BlockHeader, error) {
+		bb, err := data.BlockHeaderData.Load(hash)
+		if err != nil {
+			return nil, err
+		}
+		return bc.NewBlockHeaderFromStr(string(bb[:160]))
+	}
+
+	t.Run("proofs are verified at once up to the limit", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		var mu sync.Mutex
+		var inFlight, most int
+		v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{
+			blockHeaderFunc: func(ctx context.Context, hash string) (*bc.BlockHeader, error) {
+				mu.Lock()
+				if inFlight++; inFlight > most {
+					most = inFlight
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				return headers(ctx, hash)
+			},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, v.VerifyPayment(ctx, p, spv.Concurrency(2)))
+		require.Equal(t, 2, most)
+
+		most = 0
+		require.NoError(t, v.VerifyPayment(ctx, p, spv.Concurrency(1)))
+		require.Equal(t, 1, most)
+	})
+
+	t.Run("the first failure is returned however the work is scheduled", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{
+			blockHeaderFunc: func(ctx context.Context, hash string) (*bc.BlockHeader, error) {
+				time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+				return nil, errors.Wrapf(bc.ErrHeaderNotFound, "block %s", hash)
+			},
+		})
+		require.NoError(t, err)
+
+		r, err := v.VerifyPaymentReport(ctx, p, spv.Concurrency(1))
+		require.NoError(t, err)
+		require.Error(t, r.Err())
+		for i := 0; i < 20; i++ {
+			err := v.VerifyPayment(ctx, p, spv.Concurrency(8))
+			require.EqualError(t, err, r.Err().Error())
+		}
+	})
+
+	t.Run("a failure cancels the proofs still being verified", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		var first string
+		blocking := false
+		v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{
+			blockHeaderFunc: func(ctx context.Context, hash string) (*bc.BlockHeader, error) {
+				if first == "" {
+					first = hash
+				}
+				if !blocking || hash == first {
+					return nil, bc.ErrHeaderNotFound
+				}
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		})
+		require.NoError(t, err)
+		// verified one at a time, the first proof looked up is the first to fail.
+		r, err := v.VerifyPaymentReport(ctx, p, spv.Concurrency(1))
+		require.NoError(t, err)
+
+		// the proofs after it wait until they are cancelled.
+		blocking = true
+		err = v.VerifyPayment(ctx, p, spv.Concurrency(8))
+		require.ErrorIs(t, err, bc.ErrHeaderNotFound)
+		require.EqualError(t, err, r.Err().Error())
+	})
+
+	t.Run("the deadline of the context is respected", func(t *testing.T) {
+		p := loadAncestry(t, "valid.json", nil)
+		v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{
+			blockHeaderFunc: func(ctx context.Context, _ string) (*bc.BlockHeader, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		err = v.VerifyPayment(ctx, p)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		_, err = v.VerifyPaymentReport(ctx, p)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("a deep ancestry is verified", func(t *testing.T) {
+		testDataJSON := struct {
+			PaymentTx string `json:"paymentTx"`
+			Ancestry  string `json:"ancestors"`
+		}{}
+		bb, err := data.SpvBinaryData.Load("valid_1000_nested.json")
+		require.NoError(t, err)
+		require.NoError(t, json.NewDecoder(bytes.NewBuffer(bb)).Decode(&testDataJSON))
+		tx, err := bt.NewTxFromString(testDataJSON.PaymentTx)
+		require.NoError(t, err)
+		ancestry, err := hex.DecodeString(testDataJSON.Ancestry)
+		require.NoError(t, err)
+		p := &spv.Payment{PaymentTx: tx, Ancestry: ancestry}
+
+		v, err := spv.NewPaymentVerifier(&mockBlockHeaderClient{blockHeaderFunc: headers})
+		require.NoError(t, err)
+		exp, err := v.VerifyPaymentReport(ctx, p, spv.Concurrency(1))
+		require.NoError(t, err)
+		require.True(t, exp.Valid())
+		require.Greater(t, len(exp.Txs), 1000)
+		for _, n := range []int{4, 16} {
+			r, err := v.VerifyPaymentReport(ctx, p, spv.Concurrency(n))
+			require.NoError(t, err)
+			require.Equal(t, exp, r)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package bc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+var (
+	// ErrNegativeTarget is returned when compact bits have the sign bit set on a non zero mantissa.
+	ErrNegativeTarget = errors.New("target is negative")
+
+	// ErrTargetOverflow is returned when a target does not fit in 256 bits.
+	ErrTargetOverflow = errors.New("target overflows 256 bits")
+
+	// ErrInvalidDifficulty is returned when a difficulty is not a positive, finite number.
+	ErrInvalidDifficulty = errors.New("difficulty must be a positive finite number")
+
+	// maxTarget is the largest target which fits in 256 bits.
+	maxTarget = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+	// diff1Target is the target of a difficulty 1 block, the bits 0x1d00ffff.
+	diff1Target = new(big.Int).Lsh(big.NewInt(0xffff), 208)
+)
+
+// Target is a proof of work target, a block hash must be below the target of its block
+// for the proof of work to be valid.
+//
+// It converts exactly between the compact bits of a block header, the 256 bit target,
+// the difficulty and the work the target represents. The zero value is a zero target.
+type Target struct {
+	n *big.Int
+}
+
+// NewTargetFromCompact returns the target represented by compact bits, as a uint32.
+//
+// As in the node, ErrNegativeTarget is returned if the sign bit is set on a non zero
+// mantissa and ErrTargetOverflow if the target does not fit in 256 bits.
+func NewTargetFromCompact(compact uint32) (Target, error) {
+	size := compact >> 24
+	word := compact & 0x007fffff
+
+	if word != 0 && compact&0x00800000 != 0 {
+		return Target{}, ErrNegativeTarget
+	}
+	if word != 0 && (size > 34 || (word > 0xff && size > 33) || (word > 0xffff && size > 32)) {
+		return Target{}, ErrTargetOverflow
+	}
+
+	var n *big.Int
+	if size <= 3 {
+		n = big.NewInt(int64(word >> (8 * (3 - size))))
+	} else {
+		n = big.NewInt(int64(word))
+		n.Lsh(n, uint(8*(size-3)))
+	}
+
+	return Target{n: n}, nil
+}
+
+// NewTargetFromBits returns the target represented by the 4 byte bits of a block header.
+func NewTargetFromBits(bits []byte) (Target, error) {
+	if len(bits) != 4 {
+		return Target{}, fmt.Errorf("bits should be 4 bytes long, got %d", len(bits))
+	}
+	return NewTargetFromCompact(binary.BigEndian.Uint32(bits))
+}
+
+// NewTargetFromInt returns a target with the value n.
+func NewTargetFromInt(n *big.Int) (Target, error) {
+	if n.Sign() < 0 {
+		return Target{}, ErrNegativeTarget
+	}
+	if n.Cmp(maxTarget) > 0 {
+		return Target{}, ErrTargetOverflow
+	}
+	return Target{n: new(big.Int).Set(n)}, nil
+}
+
+// NewTargetFromDifficulty returns the target for a difficulty, where difficulty 1 is the
+// target of the bits 0x1d00ffff. The target is rounded to the nearest integer.
+func NewTargetFromDifficulty(difficulty float64) (Target, error) {
+	if difficulty <= 0 || math.IsInf(difficulty, 0) || math.IsNaN(difficulty) {
+		return Target{}, ErrInvalidDifficulty
+	}
+
+	d := new(big.Rat).SetFloat64(difficulty)
+	t := new(big.Rat).SetInt(diff1Target)
+	t.Quo(t, d)
+	// round to the nearest integer, (num + denom/2) / denom.
+	n := new(big.Int).Rsh(t.Denom(), 1)
+	n.Add(n, t.Num())
+	n.Quo(n, t.Denom())
+
+	return NewTargetFromInt(n)
+}
+
+// Int returns the target as a big.Int.
+func (t Target) Int() *big.Int {
+	if t.n == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(t.n)
+}
+
+// Compact returns the target in compact form. As in the node, precision below the three
+// most significant bytes is lost.
+func (t Target) Compact() uint32 {
+	n := t.Int()
+	size := uint32(len(n.Bytes()))
+
+	var compact uint32
+	if size <= 3 {
+		compact = uint32(n.Uint64() << (8 * (3 - size)))
+	} else {
+		compact = uint32(n.Rsh(n, uint(8*(size-3))).Uint64())
+	}
+
+	// the mantissa is signed, so shift it right a byte when its sign bit would be set.
+	if compact&0x00800000 != 0 {
+		compact >>= 8
+		size++
+	}
+
+	return compact | size<<24
+}
+
+// Bits returns the target in compact form as the 4 bytes of a block header's bits.
+func (t Target) Bits() []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, t.Compact())
+	return b
+}
+
+// Difficulty returns how many times harder the target is to meet than the target of
+// difficulty 1, the bits 0x1d00ffff. It is rounded to the nearest float64 and a zero
+// target has an infinite difficulty.
+func (t Target) Difficulty() float64 {
+	if t.n == nil || t.n.Sign() == 0 {
+		return math.Inf(1)
+	}
+	d, _ := new(big.Rat).SetFrac(diff1Target, t.n).Float64()
+	return d
+}
+
+// Work returns the expected number of hashes needed to find a block hash at or below
+// the target, 2^256 / (target + 1).
+func (t Target) Work() ChainWork {
+	w := new(big.Int).Lsh(big.NewInt(1), 256)
+	return ChainWork{n: w.Quo(w, t.Int().Add(t.Int(), big.NewInt(1)))}
+}
+
+// Cmp compares two targets, returning -1 if t is lower than o, 0 if they are equal and
+// +1 if t is higher.
+func (t Target) Cmp(o Target) int {
+	return t.Int().Cmp(o.Int())
+}
+
+// String returns the target as 64 hex characters.
+func (t Target) String() string {
+	b := make([]byte, 32)
+	t.Int().FillBytes(b)
+	return hex.EncodeToString(b)
+}
+
+// ChainWork is the expected number of hashes needed to produce a block, or chain of
+// blocks. The chain with the most work is the longest chain. The zero value is no work.
+type ChainWork struct {
+	n *big.Int
+}
+
+// NewChainWorkFromString returns the chain work from its hex form, as reported by the
+// chainwork field of the node's getblockheader.
+func NewChainWorkFromString(s string) (ChainWork, error) {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok || n.Sign() < 0 {
+		return ChainWork{}, fmt.Errorf("invalid chain work %q", s)
+	}
+	return ChainWork{n: n}, nil
+}
+
+// Int returns the work as a big.Int.
+func (w ChainWork) Int() *big.Int {
+	if w.n == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(w.n)
+}
+
+// Add returns the sum of w and o.
+func (w ChainWork) Add(o ChainWork) ChainWork {
+	n := w.Int()
+	return ChainWork{n: n.Add(n, o.Int())}
+}
+
+// Sub returns w less o, it is used to find the work done between two blocks on a chain.
+func (w ChainWork) Sub(o ChainWork) ChainWork {
+	n := w.Int()
+	return ChainWork{n: n.Sub(n, o.Int())}
+}
+
+// Cmp compares the work, returning -1 if w is less than o, 0 if they are equal and
+// +1 if w is more.
+func (w ChainWork) Cmp(o ChainWork) int {
+	return w.Int().Cmp(o.Int())
+}
+
+// String returns the work as at least 64 hex characters, as the node does.
+func (w ChainWork) String() string {
+	return fmt.Sprintf("%064x", w.Int())
+}
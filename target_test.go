@@ -0,0 +1,216 @@
+package bc_test
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+func TestNewTargetFromCompact(t *testing.T) {
+	tests := map[string]struct {
+		compact    uint32
+		exp        string
+		expCompact uint32
+		expErr     error
+	}{
+		"genesis bits": {
+			compact:    0x1d00ffff,
+			exp:        "00000000ffff0000000000000000000000000000000000000000000000000000",
+			expCompact: 0x1d00ffff,
+		},
+		"regtest bits": {
+			compact:    0x207fffff,
+			exp:        "7fffff0000000000000000000000000000000000000000000000000000000000",
+			expCompact: 0x207fffff,
+		},
+		"mantissa shifted out": {
+			compact: 0x01003456,
+			exp:     "0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		"single byte loses precision": {
+			compact:    0x01123456,
+			exp:        "0000000000000000000000000000000000000000000000000000000000000012",
+			expCompact: 0x01120000,
+		},
+		"sign bit in mantissa is shifted": {
+			compact:    0x02008000,
+			exp:        "0000000000000000000000000000000000000000000000000000000000000080",
+			expCompact: 0x02008000,
+		},
+		"padded mantissa": {
+			compact:    0x05009234,
+			exp:        "0000000000000000000000000000000000000000000000000000000092340000",
+			expCompact: 0x05009234,
+		},
+		"full mantissa": {
+			compact:    0x04123456,
+			exp:        "0000000000000000000000000000000000000000000000000000000012345600",
+			expCompact: 0x04123456,
+		},
+		"largest exponent without overflow": {
+			compact:    0x20123456,
+			exp:        "1234560000000000000000000000000000000000000000000000000000000000",
+			expCompact: 0x20123456,
+		},
+		"zero mantissa with the sign bit is zero": {
+			compact: 0x01800000,
+			exp:     "0000000000000000000000000000000000000000000000000000000000000000",
+		},
+		"negative": {
+			compact: 0x04923456,
+			expErr:  bc.ErrNegativeTarget,
+		},
+		"negative single byte": {
+			compact: 0x01fedcba,
+			expErr:  bc.ErrNegativeTarget,
+		},
+		"overflow": {
+			compact: 0xff123456,
+			expErr:  bc.ErrTargetOverflow,
+		},
+		"two byte mantissa overflow": {
+			compact: 0x22000100,
+			expErr:  bc.ErrTargetOverflow,
+		},
+		"three byte mantissa overflow": {
+			compact: 0x21010000,
+			expErr:  bc.ErrTargetOverflow,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, err := bc.NewTargetFromCompact(test.compact)
+			if test.expErr != nil {
+				require.ErrorIs(t, err, test.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.exp, target.String())
+			require.Equal(t, test.expCompact, target.Compact())
+		})
+	}
+}
+
+func TestTarget_Difficulty(t *testing.T) {
+	tests := map[string]struct {
+		bits          []byte
+		expDifficulty float64
+	}{
+		"difficulty one": {
+			bits:          []byte{0x1d, 0x00, 0xff, 0xff},
+			expDifficulty: 1,
+		},
+		"mainnet difficulty": {
+			bits:          []byte{0x17, 0x45, 0xfb, 0x53},
+			expDifficulty: 4022059196164.954,
+		},
+		"regtest difficulty": {
+			bits:          []byte{0x20, 0x7f, 0xff, 0xff},
+			expDifficulty: 4.6565423739069247e-10,
+		},
+		"zero target": {
+			bits:          []byte{0x00, 0x00, 0x00, 0x00},
+			expDifficulty: math.Inf(1),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			target, err := bc.NewTargetFromBits(test.bits)
+			require.NoError(t, err)
+			require.Equal(t, test.expDifficulty, target.Difficulty())
+
+			if math.IsInf(test.expDifficulty, 0) {
+				return
+			}
+			// the difficulty is rounded, so converting it back gives a target with the same difficulty.
+			fromDiff, err := bc.NewTargetFromDifficulty(test.expDifficulty)
+			require.NoError(t, err)
+			require.Equal(t, test.expDifficulty, fromDiff.Difficulty())
+		})
+	}
+}
+
+func TestNewTargetFromDifficulty(t *testing.T) {
+	target, err := bc.NewTargetFromDifficulty(1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0x1d00ffff), target.Compact())
+
+	target, err = bc.NewTargetFromDifficulty(4022059196164.954)
+	require.NoError(t, err)
+	require.Equal(t, uint32(0x1745fb53), target.Compact())
+}
+
+func TestNewTargetFromDifficulty_Invalid(t *testing.T) {
+	for name, d := range map[string]float64{
+		"zero":     0,
+		"negative": -1,
+		"nan":      math.NaN(),
+		"infinite": math.Inf(1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := bc.NewTargetFromDifficulty(d)
+			require.ErrorIs(t, err, bc.ErrInvalidDifficulty)
+		})
+	}
+
+	_, err := bc.NewTargetFromDifficulty(1e-80)
+	require.ErrorIs(t, err, bc.ErrTargetOverflow)
+}
+
+func TestNewTargetFromInt(t *testing.T) {
+	_, err := bc.NewTargetFromInt(big.NewInt(-1))
+	require.ErrorIs(t, err, bc.ErrNegativeTarget)
+
+	_, err = bc.NewTargetFromInt(new(big.Int).Lsh(big.NewInt(1), 256))
+	require.ErrorIs(t, err, bc.ErrTargetOverflow)
+
+	n := big.NewInt(0x12345600)
+	target, err := bc.NewTargetFromInt(n)
+	require.NoError(t, err)
+	n.SetInt64(0)
+	require.Equal(t, uint32(0x04123456), target.Compact())
+}
+
+func TestChainWork(t *testing.T) {
+	hh := mainnetHeaders(t, 4033)
+
+	var work bc.ChainWork
+	for _, bh := range hh {
+		target, err := bc.NewTargetFromBits(bh.Bits)
+		require.NoError(t, err)
+		work = work.Add(target.Work())
+	}
+	// the chainwork the node reports for block 4032.
+	require.Equal(t, "00000000000000000000000000000000000000000000000000000fc10fc10fc1", work.String())
+
+	genesis, err := bc.NewChainWorkFromString("0000000000000000000000000000000000000000000000000000000100010001")
+	require.NoError(t, err)
+	require.Equal(t, 1, work.Cmp(genesis))
+	require.Equal(t, -1, genesis.Cmp(work))
+	require.Equal(t, 0, genesis.Cmp(genesis))
+	require.Equal(t, "00000000000000000000000000000000000000000000000000000fc00fc00fc0", work.Sub(genesis).String())
+
+	_, err = bc.NewChainWorkFromString("not hex")
+	require.Error(t, err)
+}
+
+func TestBlockHeader_ValidInvalidBits(t *testing.T) {
+	bh := mainnetHeaders(t, 1)[0]
+	for name, bits := range map[string][]byte{
+		"negative bits":    {0x1d, 0x80, 0xff, 0xff},
+		"overflowing bits": {0xff, 0x12, 0x34, 0x56},
+		"zero bits":        {0x00, 0x00, 0x00, 0x00},
+	} {
+		t.Run(name, func(t *testing.T) {
+			invalid := *bh
+			invalid.Bits = bits
+			require.False(t, invalid.Valid())
+		})
+	}
+}
@@ -27,6 +27,12 @@ var spvVerifyData embed.FS
 //go:embed bhc/*
 var blockHeaderData embed.FS
 
+// The mainnet headers the retarget tests check are fetched from the node at $MAINNET_RPC.
+//go:generate go run ./fetchheaders -rpc=$MAINNET_RPC -user=$MAINNET_RPC_USER -password=$MAINNET_RPC_PASSWORD mainnet_0_4032.bin mainnet_30240_32300.bin mainnet_478540_478600.bin mainnet_501984_504040.bin
+
+//go:embed headers/*
+var headersData embed.FS
+
 // SpvCreateData data for creating spv envelopes.
 var SpvCreateData = dataDirTests{
 	prefix: "spv/create",
@@ -57,6 +63,12 @@ var BlockHeaderData = dataDirTests{
 	fs:     blockHeaderData,
 }
 
+// HeadersData raw 80 byte mainnet block headers, concatenated in height order.
+var HeadersData = dataDirTests{
+	prefix: "headers",
+	fs:     headersData,
+}
+
 // Load the data of a file.
 func (d *dataDirTests) Load(file string) ([]byte, error) {
 	return d.fs.ReadFile(path.Join(d.prefix, file))
@@ -0,0 +1,57 @@
+// Command fetchheaders writes the mainnet header test data from a node. Each file given is
+// named mainnet_<from>_<to>.bin, and is written with the raw 80 byte headers from the height
+// from to the height to, inclusive, concatenated in height order. Files which exist are kept.
+//
+//	go run ./fetchheaders -rpc http://localhost:8332 -user user -password password mainnet_0_4032.bin
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/libsv/go-bc/rpc"
+)
+
+func main() {
+	url := flag.String("rpc", "", "rpc url of a mainnet node")
+	user := flag.String("user", "", "rpc user")
+	password := flag.String("password", "", "rpc password")
+	dir := flag.String("dir", "headers", "directory the files are written to")
+	flag.Parse()
+
+	c, err := rpc.NewClient(*url, rpc.WithBasicAuth(*user, *password))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, file := range flag.Args() {
+		if err := fetch(context.Background(), c, filepath.Join(*dir, file)); err != nil {
+			log.Fatalf("%s: %v", file, err)
+		}
+	}
+}
+
+// fetch writes the headers named by the file at path, unless it exists.
+func fetch(ctx context.Context, c *rpc.Client, path string) error {
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	var from, to uint64
+	if _, err := fmt.Sscanf(filepath.Base(path), "mainnet_%d_%d.bin", &from, &to); err != nil {
+		return fmt.Errorf("file is not named mainnet_<from>_<to>.bin: %w", err)
+	}
+	hh, err := c.BlockHeadersByHeight(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 0, len(hh)*80)
+	for _, bh := range hh {
+		b = append(b, bh.Bytes()...)
+	}
+	return os.WriteFile(path, b, 0o644)
+}
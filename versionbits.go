@@ -0,0 +1,197 @@
+package bc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// VersionBitsTopMask masks the top 3 bits of a block version, which must equal
+	// VersionBitsTopBits for the version to signal version bits deployments.
+	VersionBitsTopMask uint32 = 0xe0000000
+
+	// VersionBitsTopBits is the value of the top 3 bits of a block version which signals
+	// version bits deployments.
+	VersionBitsTopBits uint32 = 0x20000000
+
+	// VersionBitsNumBits is the number of bits of a block version available for deployments.
+	VersionBitsNumBits = 29
+)
+
+// The names of the version bits deployments defined by the network parameters.
+const (
+	DeploymentTestDummy = "testdummy"
+	DeploymentCSV       = "csv"
+)
+
+// A Deployment is a BIP9 soft fork deployment signalled by miners setting a bit of the
+// block version.
+//
+// Signalling starts in the first retarget window whose previous block has a median time
+// past at or after StartTime, and the deployment fails if it has not locked in before the
+// median time past reaches Timeout.
+type Deployment struct {
+	Name      string
+	Bit       uint8
+	StartTime time.Time
+	Timeout   time.Time
+}
+
+// ThresholdState is the state of a version bits deployment.
+type ThresholdState int
+
+// The states of a version bits deployment.
+const (
+	// ThresholdDefined is the state of a deployment before its start time.
+	ThresholdDefined ThresholdState = iota
+	// ThresholdStarted is the state of a deployment which miners are signalling for.
+	ThresholdStarted
+	// ThresholdLockedIn is the state of a deployment for the window after the threshold was
+	// reached, it is active from the window after.
+	ThresholdLockedIn
+	// ThresholdActive is the state of a deployment whose rules are enforced.
+	ThresholdActive
+	// ThresholdFailed is the state of a deployment which timed out before locking in.
+	ThresholdFailed
+)
+
+// String returns the name of the state, as reported by the node.
+func (s ThresholdState) String() string {
+	switch s {
+	case ThresholdDefined:
+		return "defined"
+	case ThresholdStarted:
+		return "started"
+	case ThresholdLockedIn:
+		return "locked_in"
+	case ThresholdActive:
+		return "active"
+	case ThresholdFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// UsesVersionBits returns true if the version of the header signals version bits deployments.
+func (bh *BlockHeader) UsesVersionBits() bool {
+	return bh.Version&VersionBitsTopMask == VersionBitsTopBits
+}
+
+// SignalsBit returns true if the header signals for the deployment using bit.
+func (bh *BlockHeader) SignalsBit(bit uint8) bool {
+	return bit < VersionBitsNumBits && bh.UsesVersionBits() && bh.Version&(1<<bit) != 0
+}
+
+// SignalledBits returns the deployment bits the header signals for, in ascending order.
+func (bh *BlockHeader) SignalledBits() []uint8 {
+	var bits []uint8
+	for bit := uint8(0); bit < VersionBitsNumBits; bit++ {
+		if bh.SignalsBit(bit) {
+			bits = append(bits, bit)
+		}
+	}
+	return bits
+}
+
+// DeploymentState returns the state of a deployment for the block at height, which is the
+// same for every block in a retarget window as it is decided by the blocks before the window.
+//
+// The chain must hold the headers back to the window the deployment started in.
+func DeploymentState(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	d *Deployment, height uint64) (ThresholdState, error) {
+	window := params.MinerConfirmationWindow
+	if window == 0 {
+		return ThresholdDefined, fmt.Errorf("network %s has no miner confirmation window", params.Name)
+	}
+
+	// find the windows since the deployment started, from the last block of each.
+	var ends []uint64
+	for end := height - height%window; end >= window; end -= window {
+		mtp, err := MedianTimePastAtHeight(ctx, chain, end-1)
+		if err != nil {
+			return ThresholdDefined, err
+		}
+		if mtp.Before(d.StartTime) {
+			break
+		}
+		ends = append(ends, end-1)
+	}
+
+	state := ThresholdDefined
+	for i := len(ends) - 1; i >= 0; i-- {
+		end := ends[i]
+		switch state {
+		case ThresholdDefined, ThresholdStarted:
+			mtp, err := MedianTimePastAtHeight(ctx, chain, end)
+			if err != nil {
+				return ThresholdDefined, err
+			}
+			if !mtp.Before(d.Timeout) {
+				state = ThresholdFailed
+				break
+			}
+			if state == ThresholdDefined {
+				state = ThresholdStarted
+				break
+			}
+
+			stats, err := deploymentStats(ctx, chain, params, d, end)
+			if err != nil {
+				return ThresholdDefined, err
+			}
+			if stats.Count >= stats.Threshold {
+				state = ThresholdLockedIn
+			}
+		case ThresholdLockedIn:
+			state = ThresholdActive
+		}
+	}
+
+	return state, nil
+}
+
+// DeploymentStats is the signalling for a deployment in a retarget window.
+type DeploymentStats struct {
+	// Period is the number of blocks in the window and Threshold the number of them which
+	// must signal for the deployment to lock in.
+	Period    uint64
+	Threshold uint64
+	// Elapsed is the number of blocks of the window so far and Count is the number of them
+	// which signalled.
+	Elapsed uint64
+	Count   uint64
+	// Possible is false when too few blocks remain in the window for the threshold to be reached.
+	Possible bool
+}
+
+// DeploymentSignalling returns the signalling for a deployment in the retarget window containing
+// the block at height, counting the blocks of the window up to and including height.
+func DeploymentSignalling(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	d *Deployment, height uint64) (*DeploymentStats, error) {
+	if params.MinerConfirmationWindow == 0 {
+		return nil, fmt.Errorf("network %s has no miner confirmation window", params.Name)
+	}
+	return deploymentStats(ctx, chain, params, d, height)
+}
+
+func deploymentStats(ctx context.Context, chain HeightIndexedBlockHeaderChain, params *NetworkParams,
+	d *Deployment, height uint64) (*DeploymentStats, error) {
+	stats := &DeploymentStats{
+		Period:    params.MinerConfirmationWindow,
+		Threshold: params.RuleChangeActivationThreshold,
+	}
+	for h := height - height%stats.Period; h <= height; h++ {
+		bh, err := chain.BlockHeaderByHeight(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		stats.Elapsed++
+		if bh.SignalsBit(d.Bit) {
+			stats.Count++
+		}
+	}
+	stats.Possible = stats.Elapsed-stats.Count <= stats.Period-stats.Threshold
+
+	return stats, nil
+}
@@ -0,0 +1,217 @@
+package bc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/libsv/go-bc"
+)
+
+// signallingChain builds n headers, 10 minutes apart, where the headers for which signal
+// returns true signal for bit 1.
+func signallingChain(n int, signal func(height int) bool) []*bc.BlockHeader {
+	hh := syntheticChain(n, 0x207fffff, spaced(600))
+	for i, bh := range hh {
+		bh.Version = bc.VersionBitsTopBits
+		if signal(i) {
+			bh.Version |= 1 << 1
+		}
+	}
+	return hh
+}
+
+func TestBlockHeader_VersionBits(t *testing.T) {
+	tests := map[string]struct {
+		version     uint32
+		versionBits bool
+		bits        []uint8
+	}{
+		"legacy version": {
+			version: 4,
+		},
+		"no bits signalled": {
+			version:     0x20000000,
+			versionBits: true,
+		},
+		"bits signalled": {
+			version:     0x20000005,
+			versionBits: true,
+			bits:        []uint8{0, 2},
+		},
+		"every bit signalled": {
+			version:     0x3fffffff,
+			versionBits: true,
+			bits: []uint8{
+				0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28,
+			},
+		},
+		"wrong top bits": {
+			version: 0x60000005,
+		},
+		"bip65 style version": {
+			version: 0x7fffffff,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			bh := &bc.BlockHeader{Version: test.version}
+			require.Equal(t, test.versionBits, bh.UsesVersionBits())
+			require.Equal(t, test.bits, bh.SignalledBits())
+			require.False(t, bh.SignalsBit(29))
+		})
+	}
+}
+
+func TestDeploymentState(t *testing.T) {
+	ctx := context.Background()
+	params := bc.RegTest
+	start := spaced(600)(200)
+
+	// signalling starts in the window from 288, as the median time past of block 287 is
+	// after the start time, and the threshold is 108 of 144 blocks.
+	tests := map[string]struct {
+		signal    func(height int) bool
+		noTopBits bool
+		timeout   uint32
+		heights   map[uint64]bc.ThresholdState
+	}{
+		"activates": {
+			signal:  func(int) bool { return true },
+			timeout: spaced(600)(5000),
+			heights: map[uint64]bc.ThresholdState{
+				0:    bc.ThresholdDefined,
+				143:  bc.ThresholdDefined,
+				287:  bc.ThresholdDefined,
+				288:  bc.ThresholdStarted,
+				431:  bc.ThresholdStarted,
+				432:  bc.ThresholdLockedIn,
+				575:  bc.ThresholdLockedIn,
+				576:  bc.ThresholdActive,
+				1000: bc.ThresholdActive,
+			},
+		},
+		"signalling before the start is ignored": {
+			signal:  func(height int) bool { return height < 288 },
+			timeout: spaced(600)(5000),
+			heights: map[uint64]bc.ThresholdState{
+				288:  bc.ThresholdStarted,
+				432:  bc.ThresholdStarted,
+				1000: bc.ThresholdStarted,
+			},
+		},
+		"threshold reached exactly": {
+			signal:  func(height int) bool { return height >= 288+36 && height < 432 },
+			timeout: spaced(600)(5000),
+			heights: map[uint64]bc.ThresholdState{
+				432: bc.ThresholdLockedIn,
+				576: bc.ThresholdActive,
+			},
+		},
+		"one short of the threshold": {
+			signal:  func(height int) bool { return height >= 288+37 && height < 432 },
+			timeout: spaced(600)(5000),
+			heights: map[uint64]bc.ThresholdState{
+				432: bc.ThresholdStarted,
+				576: bc.ThresholdStarted,
+			},
+		},
+		"blocks without the top bits do not signal": {
+			signal:    func(int) bool { return true },
+			noTopBits: true,
+			timeout:   spaced(600)(5000),
+			heights: map[uint64]bc.ThresholdState{
+				432: bc.ThresholdStarted,
+			},
+		},
+		"times out before locking in": {
+			signal:  func(int) bool { return true },
+			timeout: spaced(600)(400),
+			heights: map[uint64]bc.ThresholdState{
+				288: bc.ThresholdStarted,
+				432: bc.ThresholdFailed,
+				576: bc.ThresholdFailed,
+			},
+		},
+		"times out before starting": {
+			signal:  func(int) bool { return true },
+			timeout: spaced(600)(250),
+			heights: map[uint64]bc.ThresholdState{
+				287: bc.ThresholdDefined,
+				288: bc.ThresholdFailed,
+				432: bc.ThresholdFailed,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			chain := indexedChainOf(signallingChain(1001, test.signal))
+			if test.noTopBits {
+				for _, bh := range chain.headers {
+					bh.Version &^= bc.VersionBitsTopMask
+				}
+			}
+			d := &bc.Deployment{
+				Name:      "test",
+				Bit:       1,
+				StartTime: time.Unix(int64(start), 0),
+				Timeout:   time.Unix(int64(test.timeout), 0),
+			}
+			for height, exp := range test.heights {
+				state, err := bc.DeploymentState(ctx, chain, params, d, height)
+				require.NoError(t, err)
+				require.Equal(t, exp.String(), state.String(), "height %d", height)
+			}
+		})
+	}
+}
+
+func TestDeploymentSignalling(t *testing.T) {
+	ctx := context.Background()
+	chain := indexedChainOf(signallingChain(500, func(height int) bool { return height < 300 }))
+	d := &bc.Deployment{Name: "test", Bit: 1}
+
+	tests := map[string]struct {
+		height uint64
+		exp    bc.DeploymentStats
+	}{
+		"all signalling": {
+			height: 299,
+			exp:    bc.DeploymentStats{Period: 144, Threshold: 108, Elapsed: 12, Count: 12, Possible: true},
+		},
+		"still possible": {
+			height: 335,
+			exp:    bc.DeploymentStats{Period: 144, Threshold: 108, Elapsed: 48, Count: 12, Possible: true},
+		},
+		"no longer possible": {
+			height: 336,
+			exp:    bc.DeploymentStats{Period: 144, Threshold: 108, Elapsed: 49, Count: 12, Possible: false},
+		},
+		"first block of a window": {
+			height: 432,
+			exp:    bc.DeploymentStats{Period: 144, Threshold: 108, Elapsed: 1, Count: 0, Possible: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stats, err := bc.DeploymentSignalling(ctx, chain, bc.RegTest, d, test.height)
+			require.NoError(t, err)
+			require.Equal(t, test.exp, *stats)
+		})
+	}
+}
+
+func TestNetworkParams_Deployment(t *testing.T) {
+	d := bc.MainNet.Deployment(bc.DeploymentCSV)
+	require.NotNil(t, d)
+	require.Equal(t, uint8(0), d.Bit)
+	require.Equal(t, int64(1462060800), d.StartTime.Unix())
+
+	require.Nil(t, bc.MainNet.Deployment("unknown"))
+	require.Nil(t, bc.STN.Deployment(bc.DeploymentCSV))
+}